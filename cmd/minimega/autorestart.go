@@ -0,0 +1,153 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+const (
+	// autorestartTick is how often the autorestarter scans for errored VMs.
+	autorestartTick = 5 * time.Second
+
+	// autorestart policy defaults
+	autorestartMax     = 3
+	autorestartBackoff = 30 * time.Second
+)
+
+// autorestarter restarts VMs that land in the error state, with a bounded
+// number of attempts per VM and exponential backoff between attempts. One per
+// namespace, see `vm autorestart`.
+type autorestarter struct {
+	mu sync.Mutex // guards below
+
+	enabled bool
+	max     int           // max restart attempts per VM
+	backoff time.Duration // base delay between attempts, doubles each attempt
+
+	attempts map[int]int       // restart attempts so far, per VM ID
+	next     map[int]time.Time // earliest time of the next attempt, per VM ID
+
+	// kills the sweep goroutine, nil when not running
+	done chan bool
+}
+
+func newAutorestarter() *autorestarter {
+	return &autorestarter{
+		max:      autorestartMax,
+		backoff:  autorestartBackoff,
+		attempts: make(map[int]int),
+		next:     make(map[int]time.Time),
+	}
+}
+
+// Destroy stops the autorestarter. Called when the namespace is destroyed.
+func (s *autorestarter) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stop()
+}
+
+// stop kills the sweep goroutine. Assumes the caller holds the lock.
+func (s *autorestarter) stop() {
+	s.enabled = false
+
+	if s.done != nil {
+		close(s.done)
+		s.done = nil
+	}
+}
+
+// SetAutorestart updates the autorestart policy for the namespace, resetting
+// any per-VM attempt counts.
+func (ns *Namespace) SetAutorestart(enabled bool, max int, backoff time.Duration) {
+	s := ns.autorestart
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stop()
+
+	s.max = max
+	s.backoff = backoff
+	s.attempts = make(map[int]int)
+	s.next = make(map[int]time.Time)
+
+	if !enabled {
+		return
+	}
+
+	s.enabled = true
+	s.done = make(chan bool)
+
+	go ns.runAutorestart(s.done)
+}
+
+func (ns *Namespace) runAutorestart(done chan bool) {
+	ticker := time.NewTicker(autorestartTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			ns.autorestartSweep(now)
+		case <-done:
+			return
+		}
+	}
+}
+
+// autorestartSweep restarts errored VMs that are due for an attempt and
+// haven't exhausted the policy's maximum.
+func (ns *Namespace) autorestartSweep(now time.Time) {
+	s := ns.autorestart
+
+	s.mu.Lock()
+
+	// VMs to restart this sweep, restarted after dropping the lock since
+	// relaunching can take a while
+	var restart []VM
+
+	ns.VMs.Apply(Wildcard, func(vm VM, _ bool) (bool, error) {
+		id := vm.GetID()
+
+		switch vm.GetState() {
+		case VM_ERROR:
+			if s.attempts[id] >= s.max {
+				return false, nil
+			}
+
+			if t, ok := s.next[id]; ok && now.Before(t) {
+				return false, nil
+			}
+
+			// exponential backoff: base, 2*base, 4*base, ...
+			s.next[id] = now.Add(s.backoff << uint(s.attempts[id]))
+			s.attempts[id] += 1
+
+			restart = append(restart, vm)
+		case VM_RUNNING:
+			// VM recovered, future errors start with a clean slate
+			delete(s.attempts, id)
+			delete(s.next, id)
+		}
+
+		return true, nil
+	})
+
+	s.mu.Unlock()
+
+	for _, vm := range restart {
+		log.Info("autorestart: restarting errored VM %v (%v)", vm.GetName(), vm.GetID())
+
+		if err := vm.Start(); err != nil {
+			log.Error("autorestart: %v: %v", vm.GetName(), err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var autorestartCLIHandlers = []minicli.Handler{
+	{ // vm autorestart
+		HelpShort: "automatically restart VMs that hit the error state",
+		HelpLong: `
+Automatically restart VMs in the current namespace that land in the error
+state, so that transient launch failures during massive launches self-heal
+instead of requiring a human to "vm start" each errored VM.
+
+The policy is configured with optional max and backoff parameters:
+
+	vm autorestart on-error
+	vm autorestart on-error max=3 backoff=30s
+
+Each VM is restarted at most max times (default 3). The first restart happens
+within a few seconds of the VM erroring, subsequent attempts wait backoff
+(default 30s), doubling after each failure. A VM that reaches the running
+state has its attempt count reset.
+
+To disable automatic restarts:
+
+	vm autorestart off
+
+Calling vm autorestart with no arguments shows the current policy. Note that
+updating the policy resets all per-VM attempt counts.`,
+		Patterns: []string{
+			"vm autorestart",
+			"vm autorestart <on-error,> [policy]...",
+			"vm autorestart <off,>",
+		},
+		Call: wrapBroadcastCLI(cliVMAutorestart),
+	},
+	{ // clear vm autorestart
+		HelpShort: "disable automatic VM restarts",
+		HelpLong: `
+Disable automatic restarts of errored VMs and reset the policy to its
+defaults.`,
+		Patterns: []string{
+			"clear vm autorestart",
+		},
+		Call: wrapBroadcastCLI(cliVMAutorestartClear),
+	},
+}
+
+func cliVMAutorestart(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["on-error"] {
+		max := autorestartMax
+		backoff := autorestartBackoff
+
+		for _, param := range c.ListArgs["policy"] {
+			parts := strings.SplitN(param, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("malformed policy parameter: `%v`", param)
+			}
+
+			var err error
+
+			switch parts[0] {
+			case "max":
+				max, err = strconv.Atoi(parts[1])
+				if err == nil && max < 1 {
+					err = fmt.Errorf("must be at least 1")
+				}
+			case "backoff":
+				backoff, err = time.ParseDuration(parts[1])
+			default:
+				err = fmt.Errorf("unknown parameter")
+			}
+
+			if err != nil {
+				return fmt.Errorf("%v: %v", param, err)
+			}
+		}
+
+		ns.SetAutorestart(true, max, backoff)
+		return nil
+	}
+
+	if c.BoolArgs["off"] {
+		ns.SetAutorestart(false, autorestartMax, autorestartBackoff)
+		return nil
+	}
+
+	s := ns.autorestart
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.enabled {
+		resp.Response = "disabled"
+		return nil
+	}
+
+	resp.Response = fmt.Sprintf("on-error max=%v backoff=%v", s.max, s.backoff)
+	return nil
+}
+
+func cliVMAutorestartClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	ns.SetAutorestart(false, autorestartMax, autorestartBackoff)
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// autoTunnel tracks the overlay mesh that auto-tunneling built for one
+// bridge: the tunnel key, the hosts that are already meshed, and the tunnel
+// taps created on each host so they can be torn down when the namespace is
+// cleared. See `ns auto-tunnel`.
+type autoTunnel struct {
+	// Key shared by all tunnels on the bridge
+	Key uint32
+
+	// hosts that are already meshed together
+	hosts map[string]bool
+
+	// tunnel taps created so far, per host
+	taps map[string][]string
+}
+
+// autoTunnelHosts returns, per bridge, the hosts that have or are about to
+// have VMs with an interface on it.
+func (n *Namespace) autoTunnelHosts(assignment map[string][]*QueuedVMs) map[string]map[string]bool {
+	res := map[string]map[string]bool{}
+
+	add := func(host, b string) {
+		if b == "" {
+			b = DefaultBridge
+		}
+
+		if res[b] == nil {
+			res[b] = map[string]bool{}
+		}
+
+		res[b][host] = true
+	}
+
+	for host, queue := range assignment {
+		for _, q := range queue {
+			for _, nic := range q.VMConfig.Networks {
+				add(host, nic.Bridge)
+			}
+		}
+	}
+
+	for _, vm := range globalVMs(n) {
+		for _, nic := range vm.GetNetworks() {
+			add(vm.GetHost(), nic.Bridge)
+		}
+	}
+
+	return res
+}
+
+// autoTunnel creates GRE tunnels between the hosts that the assignment
+// spreads a bridge across, skipping host pairs that are already meshed from a
+// previous schedule. Tunnels carry every VLAN on the bridge so VMs reach each
+// other across hosts without a shared trunk or any manual `ns bridge` setup.
+func (n *Namespace) autoTunnel(assignment map[string][]*QueuedVMs) error {
+	for b, hosts := range n.autoTunnelHosts(assignment) {
+		t := n.autoTunnels[b]
+		if t == nil {
+			t = &autoTunnel{
+				Key:   rand.Uint32(),
+				hosts: map[string]bool{},
+				taps:  map[string][]string{},
+			}
+		}
+
+		var added []string
+		for host := range hosts {
+			if !t.hosts[host] {
+				added = append(added, host)
+			}
+		}
+
+		// nothing to do until the bridge actually spans hosts
+		if len(added) == 0 || len(t.hosts)+len(added) < 2 {
+			continue
+		}
+
+		sort.Strings(added)
+
+		all := append([]string{}, added...)
+		for host := range t.hosts {
+			all = append(all, host)
+		}
+
+		ips, err := hostIPs(all)
+		if err != nil {
+			return err
+		}
+
+		// enable rstp on the new hosts before creating any tunnels because
+		// loops are bad
+		cmds := []*minicli.Command{}
+		for _, host := range added {
+			cmds = append(cmds, hostCommandf(host, "bridge config %q rstp_enable=true", b))
+		}
+
+		// LOCK: see note in cliNamespaceBridge.
+		if err := consume(runCommands(cmds...)); err != nil {
+			return err
+		}
+
+		// tunnel each new host to every other involved host, in both
+		// directions
+		cmds = nil
+		for _, host := range added {
+			for _, host2 := range all {
+				if host == host2 || (!t.hosts[host2] && host > host2) {
+					continue
+				}
+
+				cmds = append(cmds, hostCommandf(host, "bridge tunnel gre %q %v %v", b, ips[host2], t.Key))
+				cmds = append(cmds, hostCommandf(host2, "bridge tunnel gre %q %v %v", b, ips[host], t.Key))
+			}
+		}
+
+		// collect the created tap names so that `clear namespace` can tear
+		// the tunnels down
+		for resps := range runCommands(cmds...) {
+			for _, resp := range resps {
+				if resp.Error != "" {
+					err = errors.New(resp.Error)
+				} else if resp.Response != "" {
+					t.taps[resp.Host] = append(t.taps[resp.Host], resp.Response)
+				}
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		log.Info("auto-tunneled bridge %v across %v", b, all)
+
+		for _, host := range added {
+			t.hosts[host] = true
+		}
+
+		n.autoTunnels[b] = t
+	}
+
+	return nil
+}
+
+// clearAutoTunnels removes the tunnels that auto-tunneling created. Must be
+// called from a CLI handler since it runs commands.
+func (n *Namespace) clearAutoTunnels() error {
+	cmds := []*minicli.Command{}
+
+	for b, t := range n.autoTunnels {
+		for host, taps := range t.taps {
+			for _, tap := range taps {
+				cmds = append(cmds, hostCommandf(host, "bridge notunnel %q %v", b, tap))
+			}
+		}
+	}
+
+	if err := consume(runCommands(cmds...)); err != nil {
+		return err
+	}
+
+	n.autoTunnels = map[string]*autoTunnel{}
+
+	return nil
+}
+
+// hostCommandf compiles a command, wrapping it in `mesh send` unless it
+// targets the local host.
+func hostCommandf(host, format string, args ...interface{}) *minicli.Command {
+	cmd := fmt.Sprintf(format, args...)
+
+	if host == hostname {
+		return minicli.MustCompile(cmd)
+	}
+
+	return minicli.MustCompilef("mesh send %q %v", host, cmd)
+}
+
+// hostIPs resolves each host to a non-loopback IP.
+func hostIPs(hosts []string) (map[string]string, error) {
+	res := map[string]string{}
+
+	for _, host := range hosts {
+		addrs, err := net.LookupIP(host)
+		if err != nil {
+			return nil, fmt.Errorf("failure looking up %v: %v", host, err)
+		}
+
+		for _, addr := range addrs {
+			if !addr.IsLoopback() {
+				res[host] = addr.String()
+				break
+			}
+		}
+
+		if _, ok := res[host]; !ok {
+			return nil, fmt.Errorf("host %v has no non-loopback IP", host)
+		}
+	}
+
+	return res, nil
+}
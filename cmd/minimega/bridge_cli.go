@@ -141,16 +141,44 @@ to add interface bar to bridge foo:
 
 	bridge trunk foo bar
 
+To connect an experiment to a lab physical network, trunk the physical uplink
+and, optionally, limit the trunk to specific VLANs so only the intended
+segments leave the host:
+
+	bridge trunk add foo eth1 DMZ 200
+
+minimega tracks the trunk and refuses interfaces that are already in use on
+any managed bridge, and tears the trunk down with the bridge. To remove a
+trunk, use 'bridge trunk remove' (or the equivalent 'bridge notrunk'):
+
+	bridge trunk remove foo eth1
+
 To create a vxlan or GRE tunnel to another bridge, use 'bridge tunnel'. For
 example, to create a vxlan tunnel to another bridge with IP 10.0.0.1:
 
 	bridge tunnel vxlan mega_bridge 10.0.0.1
 
+To switch a bridge to the userspace (DPDK) datapath for experiments that need
+more inter-VM throughput than the kernel datapath can sustain, use 'bridge
+dpdk' before launching VMs on it:
+
+	bridge dpdk fast_bridge
+
+KVM VMs launched on a DPDK bridge get vhost-user interfaces instead of kernel
+taps, so packets move between the vswitch and the VM over shared memory. OVS
+must be built with DPDK support and initialized with
+other_config:dpdk-init=true. The datapath must be selected while the bridge
+has no interfaces, containers cannot attach to a DPDK bridge, and vhost-user
+interfaces cannot be hot-plugged.
+
 Note: bridge is not a namespace-aware command.`,
 		Patterns: []string{
 			"bridge",
 			"bridge <config,> <bridge> <config>",
+			"bridge <dpdk,> <bridge>",
 			"bridge <trunk,> <bridge> <interface>",
+			"bridge <trunk,> <add,> <bridge> <interface> [vlans]...",
+			"bridge <trunk,> <remove,> <bridge> <interface>",
 			"bridge <notrunk,> <bridge> <interface>",
 			"bridge <tunnel,> <vxlan,gre> <bridge> <remote ip> [key]",
 			"bridge <notunnel,> <bridge> <interface>",
@@ -199,7 +227,7 @@ func cliTapCreate(ns *Namespace, c *minicli.Command, resp *minicli.Response) err
 		log.Debug("obtaining dhcp on tap %v", tap)
 
 		var out string
-		out, err = processWrapper("dhclient", tap)
+		out, err = privProcessWrapper("dhclient", tap)
 		if err != nil {
 			err = fmt.Errorf("dhcp error %v: `%v`", err, out)
 		}
@@ -209,7 +237,7 @@ func cliTapCreate(ns *Namespace, c *minicli.Command, resp *minicli.Response) err
 		log.Debug("setting ip on tap %v: %v", tap, ip)
 
 		var out string
-		out, err = processWrapper("ip", "addr", "add", "dev", tap, ip)
+		out, err = privProcessWrapper("ip", "addr", "add", "dev", tap, ip)
 		if err != nil {
 			err = fmt.Errorf("ip error %v: `%v`", err, out)
 		}
@@ -352,7 +380,22 @@ func cliBridge(ns *Namespace, c *minicli.Command, resp *minicli.Response) error
 	}
 
 	if c.BoolArgs["trunk"] {
-		return br.AddTrunk(iface)
+		if c.BoolArgs["remove"] {
+			return br.RemoveTrunk(iface)
+		}
+
+		var vlans []int
+
+		for _, alias := range c.ListArgs["vlans"] {
+			vlan, err := lookupVLAN(ns.Name, alias)
+			if err != nil {
+				return err
+			}
+
+			vlans = append(vlans, vlan)
+		}
+
+		return bridges.AddTrunk(br.Name, iface, vlans)
 	} else if c.BoolArgs["notrunk"] {
 		return br.RemoveTrunk(iface)
 	} else if c.BoolArgs["tunnel"] {
@@ -361,11 +404,19 @@ func cliBridge(ns *Namespace, c *minicli.Command, resp *minicli.Response) error
 			t = bridge.TunnelGRE
 		}
 
-		return br.AddTunnel(t, remoteIP, c.StringArgs["key"])
+		tap, err := br.AddTunnel(t, remoteIP, c.StringArgs["key"])
+		if err != nil {
+			return err
+		}
+
+		resp.Response = tap
+		return nil
 	} else if c.BoolArgs["notunnel"] {
 		return br.RemoveTunnel(iface)
 	} else if c.BoolArgs["config"] {
 		return br.Config(c.StringArgs["config"])
+	} else if c.BoolArgs["dpdk"] {
+		return br.SetDPDK()
 	} else if c.BoolArgs["destroy"] {
 		return bridges.DestroyBridge(c.StringArgs["bridge"])
 	}
@@ -6,6 +6,7 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/sandia-minimega/minimega/v2/internal/bridge"
 	"github.com/sandia-minimega/minimega/v2/internal/gonetflow"
@@ -47,6 +48,25 @@ type pcapBridgeCapture struct {
 	pcapCapture // embed
 }
 
+type replayCapture struct {
+	bridge.ReplayConfig // embed
+
+	// Bridge that is doing the replay
+	Bridge string
+
+	// ID returned by bridge
+	ID int
+
+	// VLAN alias that the replay is injected onto
+	VLAN string
+
+	// Tap created on the bridge for the replay
+	Tap string
+
+	// Path of the PCAP being replayed
+	Path string
+}
+
 type netflowCapture struct {
 	netflowConfig // embed
 
@@ -64,6 +84,7 @@ type captures struct {
 
 	bridge.CaptureConfig // embed config for new pcap captures
 	netflowConfig        // embed config for new netflow captures
+	bridge.ReplayConfig  // embed config for new replays
 }
 
 // Active timeout for connections in seconds. Due to a limitation with Open
@@ -74,6 +95,22 @@ func (c *pcapCapture) Type() string {
 	return "pcap"
 }
 
+// Stats returns the packets written and dropped counts for display, empty
+// when the underlying capture is gone.
+func (c *pcapCapture) Stats() (string, string) {
+	br, err := getBridge(c.Bridge)
+	if err != nil {
+		return "", ""
+	}
+
+	written, dropped, err := br.CaptureStats(c.ID)
+	if err != nil {
+		return "", ""
+	}
+
+	return strconv.FormatUint(written, 10), strconv.FormatUint(dropped, 10)
+}
+
 func (c *pcapCapture) Stop() error {
 	br, err := getBridge(c.Bridge)
 	if err != nil {
@@ -83,6 +120,19 @@ func (c *pcapCapture) Stop() error {
 	return br.StopCapture(c.ID)
 }
 
+func (c *replayCapture) Type() string {
+	return "replay"
+}
+
+func (c *replayCapture) Stop() error {
+	br, err := getBridge(c.Bridge)
+	if err != nil {
+		return err
+	}
+
+	return br.StopReplay(c.ID)
+}
+
 func (c *netflowCapture) Type() string {
 	return "netflow"
 }
@@ -111,9 +161,21 @@ func (c *netflowCapture) Stop() error {
 	return nil
 }
 
+// pcapConfig returns the config for a new pcap capture, overriding the preset
+// BPF filter with the given one when it is non-empty.
+func (c *captures) pcapConfig(filter string) bridge.CaptureConfig {
+	config := c.CaptureConfig
+	if filter != "" {
+		config.Filter = filter
+	}
+
+	return config
+}
+
 // CaptureVM starts a new capture for a specified interface on a VM, writing
-// the packets to the specified file in PCAP format.
-func (c *captures) CaptureVM(vm VM, iface int, fname string) error {
+// the packets to the specified file in PCAP format. If filter is non-empty,
+// it is used as the BPF filter for this capture instead of the preset one.
+func (c *captures) CaptureVM(vm VM, iface int, fname, filter string) error {
 	nic, err := vm.GetNetwork(iface)
 	if err != nil {
 		return err
@@ -127,14 +189,16 @@ func (c *captures) CaptureVM(vm VM, iface int, fname string) error {
 		return err
 	}
 
-	id, err := br.CaptureTap(tap, fname, c.CaptureConfig)
+	config := c.pcapConfig(filter)
+
+	id, err := br.CaptureTap(tap, fname, config)
 	if err != nil {
 		return err
 	}
 
 	return c.addCapture(&pcapVMCapture{
 		pcapCapture: pcapCapture{
-			CaptureConfig: c.CaptureConfig,
+			CaptureConfig: config,
 			Bridge:        bridge,
 			Path:          fname,
 			ID:            id,
@@ -145,21 +209,25 @@ func (c *captures) CaptureVM(vm VM, iface int, fname string) error {
 }
 
 // CaptureBridge starts a new capture for all the traffic on the specified
-// bridge, writing all packets to the specified filename in PCAP format.
-func (c *captures) CaptureBridge(bridge, fname string) error {
+// bridge, writing all packets to the specified filename in PCAP format. If
+// filter is non-empty, it is used as the BPF filter for this capture instead
+// of the preset one.
+func (c *captures) CaptureBridge(bridge, fname, filter string) error {
 	br, err := getBridge(bridge)
 	if err != nil {
 		return err
 	}
 
-	id, err := br.Capture(fname, c.CaptureConfig)
+	config := c.pcapConfig(filter)
+
+	id, err := br.Capture(fname, config)
 	if err != nil {
 		return err
 	}
 
 	return c.addCapture(&pcapBridgeCapture{
 		pcapCapture: pcapCapture{
-			CaptureConfig: c.CaptureConfig,
+			CaptureConfig: config,
 			Bridge:        bridge,
 			Path:          fname,
 			ID:            id,
@@ -167,6 +235,33 @@ func (c *captures) CaptureBridge(bridge, fname string) error {
 	})
 }
 
+// Replay starts injecting the packets from the specified file onto the given
+// VLAN via a host tap on the bridge. The recorded inter-packet timing is
+// scaled by rate.
+func (c *captures) Replay(b, alias string, vlan int, fname string, rate float64) error {
+	br, err := getBridge(b)
+	if err != nil {
+		return err
+	}
+
+	config := c.ReplayConfig
+	config.Rate = rate
+
+	id, tap, err := br.Replay(fname, vlan, config)
+	if err != nil {
+		return err
+	}
+
+	return c.addCapture(&replayCapture{
+		ReplayConfig: config,
+		Bridge:       b,
+		ID:           id,
+		VLAN:         alias,
+		Tap:          tap,
+		Path:         fname,
+	})
+}
+
 // CaptureNetflowFile starts a new netflow recorder for all the traffic on the
 // specified bridge, writing the netflow records to the specified filename.
 func (c *captures) CaptureNetflowFile(bridge, fname string) error {
@@ -241,6 +336,18 @@ func (c *captures) StopVM(s string) error {
 	return err
 }
 
+// StopReplay stops replays onto VLAN (wildcard supported).
+func (c *captures) StopReplay(s string) error {
+	return c.stop(func(v capture) bool {
+		switch v := v.(type) {
+		case *replayCapture:
+			return v.VLAN == s || s == Wildcard
+		}
+
+		return false
+	})
+}
+
 // StopBridge stops capture for bridge (wildcard supported).
 func (c *captures) StopBridge(s, typ string) error {
 	return c.stop(func(v capture) bool {
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/sandia-minimega/minimega/v2/internal/gonetflow"
 	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
@@ -26,15 +27,19 @@ var captureCLIHandlers = []minicli.Handler{
 		Patterns: []string{
 			"capture <pcap,> <snaplen,> [size]",
 			"capture <pcap,> <filter,> [bpf]",
+			"capture <pcap,> <rotate-size,> [megabytes]",
+			"capture <pcap,> <rotate-age,> [duration]",
+			"capture <pcap,> <ring,> [files]",
 			"capture <netflow,> <mode,> [raw,ascii]",
 			"capture <netflow,> <gzip,> [true,false]",
+			"capture <replay,> <loop,> [true,false]",
 		},
 		Call: wrapBroadcastCLI(cliCaptureConfig),
 	},
 	{ // capture for VM
 		HelpShort: "capture experiment data for a VM",
 		Patterns: []string{
-			"capture <pcap,> vm <vm name> <interface index> <filename>",
+			"capture <pcap,> vm <vm name> <interface index> <filename> [filter]",
 			"capture <pcap,> <delete,> vm <vm name>",
 		},
 		Call:    wrapVMTargetCLI(cliCaptureVM),
@@ -62,6 +67,31 @@ for new PCAP captures, use:
 	capture pcap snaplen <size>
 	capture pcap filter <bpf>
 
+A BPF filter may also be given when starting an individual capture, overriding
+the preset filter for just that capture. This keeps captures in busy
+experiments down to the relevant traffic. Quote the expression so that it
+parses as a single argument (see "man pcap-filter" for the syntax):
+
+	# capture only SMB traffic for VM foo
+	capture pcap vm foo 0 smb.pcap "tcp port 445"
+
+Long captures can rotate their output file by size or age so that a single
+PCAP never grows without bound. Rotated files keep the capture's filename
+with a numeric suffix (foo.pcap.0, foo.pcap.1, ...). To keep a capture from
+ever filling the disk, enable ring mode, which caps how many rotated files
+are kept by deleting the oldest:
+
+	# rotate at 100 MB, keeping the 10 most recent files
+	capture pcap rotate-size 100
+	capture pcap ring 10
+
+	# rotate hourly
+	capture pcap rotate-age 1h
+
+The packets and drops columns in the capture output report how many packets
+each PCAP capture has written and how many the kernel dropped because the
+writer couldn't keep up.
+
 Examples:
 
 	# Capture netflow for mega_bridge to foo.netflow
@@ -101,7 +131,7 @@ minimega instance:
 	capture <netflow,> <bridge,> <bridge> <tcp,udp> <hostname:port>
 	capture <netflow,> <delete,> bridge <name>
 	capture <netflow,> <timeout,> [timeout in seconds]
-	capture <pcap,> bridge <bridge> <filename>
+	capture <pcap,> bridge <bridge> <filename> [filter]
 	capture <pcap,> <delete,> bridge <name>
 
 `,
@@ -110,7 +140,7 @@ minimega instance:
 			"capture <netflow,> <bridge,> <bridge> <tcp,udp> <hostname:port>",
 			"capture <netflow,> <delete,> bridge <bridge>",
 			"capture <netflow,> <timeout,> [timeout in seconds]",
-			"capture <pcap,> bridge <bridge> <filename>",
+			"capture <pcap,> bridge <bridge> <filename> [filter]",
 			"capture <pcap,> <delete,> bridge <bridge>",
 		},
 		Call: wrapSimpleCLI(cliCapture),
@@ -121,6 +151,50 @@ minimega instance:
 			return nil
 		}),
 	},
+	{ // capture replay
+		HelpShort: "replay a PCAP onto a VLAN",
+		HelpLong: `
+Replay a previously recorded PCAP onto an experiment segment. The packets are
+injected via a host tap on the default bridge so that they mix with live VM
+traffic on the specified VLAN.
+
+By default, the recorded inter-packet timing is preserved so that the traffic
+plays back in real time. The optional rate is a multiplier applied to the
+recorded timing -- 2.0 replays at double speed, 0.5 at half speed, and 0
+injects packets as fast as possible:
+
+	# replay foo.pcap onto VLAN foo in real time
+	capture replay foo.pcap vlan foo
+
+	# replay foo.pcap onto VLAN foo at 10x speed
+	capture replay foo.pcap vlan foo 10
+
+To restart the replay from the beginning of the PCAP when it reaches the end,
+enable looping before starting the replay:
+
+	capture replay loop true
+
+To stop a replay, use the delete command, replacing <vlan> with "all" to stop
+all replays:
+
+	capture replay delete <vlan>
+
+Similar to the bridge-based captures, replays are tied to a host tap on the
+local bridge so the replay commands only run on the local minimega instance.`,
+		Patterns: []string{
+			"capture <replay,> <filename> vlan <vlan> [rate]",
+			"capture <replay,> <delete,> <vlan>",
+		},
+		Call: wrapSimpleCLI(cliCaptureReplay),
+		Suggest: wrapSuggest(func(ns *Namespace, val, prefix string) []string {
+			if val == "filename" {
+				return cliFileSuggest(prefix)
+			} else if val == "vlan" {
+				return cliVLANSuggest(ns, prefix)
+			}
+			return nil
+		}),
+	},
 	{ // clear capture
 		HelpShort: "reset capture state",
 		HelpLong: `
@@ -168,6 +242,45 @@ func cliCaptureConfig(ns *Namespace, c *minicli.Command, resp *minicli.Response)
 
 		resp.Response = ns.captures.Filter
 		return nil
+	} else if c.BoolArgs["rotate-size"] {
+		if v, ok := c.StringArgs["megabytes"]; ok {
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || i < 0 {
+				return fmt.Errorf("invalid size: `%v`", v)
+			}
+
+			ns.captures.RotateSize = i << 20
+			return nil
+		}
+
+		resp.Response = strconv.FormatInt(ns.captures.RotateSize>>20, 10)
+		return nil
+	} else if c.BoolArgs["rotate-age"] {
+		if v, ok := c.StringArgs["duration"]; ok {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid duration: `%v`", v)
+			}
+
+			ns.captures.RotateAge = d
+			return nil
+		}
+
+		resp.Response = ns.captures.RotateAge.String()
+		return nil
+	} else if c.BoolArgs["ring"] {
+		if v, ok := c.StringArgs["files"]; ok {
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 0 {
+				return fmt.Errorf("invalid file count: `%v`", v)
+			}
+
+			ns.captures.RingFiles = i
+			return nil
+		}
+
+		resp.Response = strconv.Itoa(ns.captures.RingFiles)
+		return nil
 	} else if c.BoolArgs["mode"] {
 		if c.BoolArgs["raw"] {
 			ns.captures.Mode = gonetflow.RAW
@@ -187,6 +300,14 @@ func cliCaptureConfig(ns *Namespace, c *minicli.Command, resp *minicli.Response)
 
 		resp.Response = strconv.FormatBool(ns.captures.Compress)
 		return nil
+	} else if c.BoolArgs["loop"] {
+		if c.BoolArgs["true"] || c.BoolArgs["false"] {
+			ns.captures.Loop = c.BoolArgs["true"]
+			return nil
+		}
+
+		resp.Response = strconv.FormatBool(ns.captures.Loop)
+		return nil
 	}
 
 	return unreachable()
@@ -199,7 +320,10 @@ func cliCaptureList(ns *Namespace, c *minicli.Command, resp *minicli.Response) e
 		"interface",
 		"mode",
 		"compress",
+		"filter",
 		"path",
+		"packets",
+		"drops",
 	}
 
 	resp.Tabular = [][]string{}
@@ -209,19 +333,27 @@ func cliCaptureList(ns *Namespace, c *minicli.Command, resp *minicli.Response) e
 
 		switch v := v.(type) {
 		case *pcapVMCapture:
+			packets, drops := v.Stats()
 			row = []string{
 				v.Bridge,
 				v.Type(),
 				fmt.Sprintf("%v:%v", v.VM.GetName(), v.Interface),
 				"", "",
+				v.Filter,
 				v.Path,
+				packets,
+				drops,
 			}
 		case *pcapBridgeCapture:
+			packets, drops := v.Stats()
 			row = []string{
 				v.Bridge,
 				v.Type(),
 				"", "", "",
+				v.Filter,
 				v.Path,
+				packets,
+				drops,
 			}
 		case *netflowCapture:
 			row = []string{
@@ -230,7 +362,19 @@ func cliCaptureList(ns *Namespace, c *minicli.Command, resp *minicli.Response) e
 				"",
 				v.Mode.String(),
 				strconv.FormatBool(v.Compress),
+				"",
 				v.Path,
+				"", "",
+			}
+		case *replayCapture:
+			row = []string{
+				v.Bridge,
+				v.Type(),
+				fmt.Sprintf("%v:%v", v.VLAN, v.Tap),
+				"", "",
+				"",
+				v.Path,
+				"", "",
 			}
 		}
 
@@ -267,7 +411,42 @@ func cliCaptureVM(ns *Namespace, c *minicli.Command, resp *minicli.Response) err
 		fname = filepath.Join(*f_iomBase, fname)
 	}
 
-	return ns.captures.CaptureVM(vm, num, fname)
+	return ns.captures.CaptureVM(vm, num, fname, c.StringArgs["filter"])
+}
+
+// cliCaptureReplay manages the CLI for starting and stopping replays.
+func cliCaptureReplay(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	alias := c.StringArgs["vlan"]
+
+	if c.BoolArgs["delete"] {
+		return ns.captures.StopReplay(alias)
+	}
+
+	fname := c.StringArgs["filename"]
+
+	// Ensure that relative paths are always relative to /files/
+	if !filepath.IsAbs(fname) {
+		fname = filepath.Join(*f_iomBase, fname)
+	}
+
+	// default to real time unless the user specified a rate
+	rate := 1.0
+
+	if v, ok := c.StringArgs["rate"]; ok {
+		var err error
+
+		rate, err = strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 {
+			return fmt.Errorf("invalid rate: `%v`", v)
+		}
+	}
+
+	vlan, err := lookupVLAN(ns.Name, alias)
+	if err != nil {
+		return err
+	}
+
+	return ns.captures.Replay(DefaultBridge, alias, vlan, fname, rate)
 }
 
 func cliCaptureClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
@@ -283,7 +462,7 @@ func cliCapturePcap(ns *Namespace, c *minicli.Command, resp *minicli.Response) e
 		return ns.captures.StopBridge(b, "pcap")
 	}
 
-	return ns.captures.CaptureBridge(b, fname)
+	return ns.captures.CaptureBridge(b, fname, c.StringArgs["filter"])
 }
 
 // cliCaptureNetflow manages the CLI for starting and stopping captures to netflow.
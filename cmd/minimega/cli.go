@@ -41,30 +41,47 @@ type wrappedSuggestFunc func(*Namespace, string, string) []string
 func cliSetup() {
 	minicli.Preprocessor = cliPreprocessor
 
+	registerHandlers("autorestart", autorestartCLIHandlers)
 	registerHandlers("bridge", bridgeCLIHandlers)
 	registerHandlers("capture", captureCLIHandlers)
 	registerHandlers("cc", ccCLIHandlers)
 	registerHandlers("deploy", deployCLIHandlers)
+	registerHandlers("detonate", detonateCLIHandlers)
 	registerHandlers("disk", diskCLIHandlers)
+	registerHandlers("dns", dnsCLIHandlers)
 	registerHandlers("dnsmasq", dnsmasqCLIHandlers)
 	registerHandlers("dot", dotCLIHandlers)
 	registerHandlers("external", externalCLIHandlers)
+	registerHandlers("gc", gcCLIHandlers)
 	registerHandlers("history", historyCLIHandlers)
+	registerHandlers("hook", hookCLIHandlers)
 	registerHandlers("host", hostCLIHandlers)
+	registerHandlers("images", imagesCLIHandlers)
+	registerHandlers("inventory", inventoryCLIHandlers)
 	registerHandlers("io", ioCLIHandlers)
 	registerHandlers("log", logCLIHandlers)
 	registerHandlers("meshage", meshageCLIHandlers)
+	registerHandlers("metadata", metadataCLIHandlers)
 	registerHandlers("misc", miscCLIHandlers)
 	registerHandlers("namespace", namespaceCLIHandlers)
+	registerHandlers("netmatrix", netmatrixCLIHandlers)
+	registerHandlers("netprobe", netprobeCLIHandlers)
+	registerHandlers("network", netDiagCLIHandlers)
 	registerHandlers("nuke", nukeCLIHandlers)
 	registerHandlers("optimize", optimizeCLIHandlers)
+	registerHandlers("ports", portsCLIHandlers)
+	registerHandlers("posture", postureCLIHandlers)
+	registerHandlers("power", powerCLIHandlers)
 	registerHandlers("qos", qosCLIHandlers)
+	registerHandlers("rbac", rbacCLIHandlers)
 	registerHandlers("router", routerCLIHandlers)
 	registerHandlers("shell", shellCLIHandlers)
+	registerHandlers("sinkhole", sinkholeCLIHandlers)
+	registerHandlers("traffic", trafficCLIHandlers)
 	registerHandlers("vlans", vlansCLIHandlers)
 	registerHandlers("vm", vmCLIHandlers)
-	registerHandlers("vmconfig", vmconfigCLIHandlers)
-	registerHandlers("vmconfiger", vmconfigerCLIHandlers)
+	registerHandlers("vmconfig", recordVMConfigHistory(vmconfigCLIHandlers))
+	registerHandlers("vmconfiger", recordVMConfigHistory(vmconfigerCLIHandlers))
 	registerHandlers("vnc", vncCLIHandlers)
 	registerHandlers("plumb", plumbCLIHandlers)
 
@@ -78,12 +95,46 @@ func cmdProcessor() {
 			defer close(cmd.out)
 
 			for _, c := range cmd.in {
+				if err := checkNamespaceReadOnly(c); err != nil {
+					cmd.out <- errResp(err)
+					continue
+				}
+
 				forward(minicli.ProcessCommand(c), cmd.out)
 			}
 		}(cmd)
 	}
 }
 
+// checkNamespaceReadOnly rejects commands that would modify a read-only
+// namespace, see `ns read-only`. Nested commands pass through here again
+// after the namespace handler has switched namespaces, so checking the
+// active namespace covers `namespace <name> (command)` as well.
+func checkNamespaceReadOnly(c *minicli.Command) error {
+	if c == nil || c.Nop || c.IsReadOnly() {
+		return nil
+	}
+
+	ns := GetNamespace()
+	if !ns.ReadOnly {
+		return nil
+	}
+
+	// find the innermost command, the one that runs in the namespace
+	leaf := c
+	for leaf.Subcommand != nil {
+		leaf = leaf.Subcommand
+	}
+
+	// always allow the toggle itself so that the namespace can be made
+	// writable again
+	if hasCommand(leaf, "ns") && leaf.BoolArgs["read-only"] {
+		return nil
+	}
+
+	return fmt.Errorf("namespace %v is read-only", ns.Name)
+}
+
 // registerHandlers registers all the provided handlers with minicli, panicking
 // if any of the handlers fail to register.
 func registerHandlers(name string, handlers []minicli.Handler) {
@@ -475,6 +526,8 @@ func cliLocal(input *liner.State) {
 			log.Warn("namespace changed between prompt and execution")
 		}
 
+		recordHistory("local", cmd)
+
 		for resp := range runCommands(cmd) {
 			// print the responses
 			minipager.DefaultPager.Page(resp.String())
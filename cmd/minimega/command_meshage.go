@@ -37,6 +37,8 @@ func meshageHandler() {
 				c, c2 = c.Subcommand, c2.Subcommand
 			}
 
+			recordHistory("mesh", cmd)
+
 			resps := []minicli.Responses{}
 			for resp := range runCommands(cmd) {
 				resps = append(resps, resp)
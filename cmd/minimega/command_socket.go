@@ -48,6 +48,9 @@ func commandSocketRemove() {
 func commandSocketHandle(c net.Conn) {
 	defer c.Close()
 
+	// who is on the other end, for rbac policies
+	username := peerUser(c)
+
 	enc := json.NewEncoder(c)
 	dec := json.NewDecoder(c)
 	done := make(chan struct{})
@@ -145,6 +148,28 @@ func commandSocketHandle(c net.Conn) {
 			cmd.SetRecord(false)
 		}
 
+		// reject mutating commands from read-only clients
+		if r.ReadOnly && !cmd.IsReadOnly() {
+			resp := &minicli.Response{
+				Host:  hostname,
+				Error: "command not allowed over read-only connection",
+			}
+			err = sendLocalResp(enc, minicli.Responses{resp}, false)
+			continue
+		}
+
+		// enforce the per-user policy, if one is set for the peer
+		if err2 := rbacCheck(username, cmd); err2 != nil {
+			resp := &minicli.Response{
+				Host:  hostname,
+				Error: err2.Error(),
+			}
+			err = sendLocalResp(enc, minicli.Responses{resp}, false)
+			continue
+		}
+
+		recordHistory("socket", cmd)
+
 		go func() {
 			for {
 				status := make(chan string)
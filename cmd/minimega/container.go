@@ -221,6 +221,22 @@ type ContainerConfig struct {
 	// Note: this configuration only applies to containers and must be specified.
 	FilesystemPath string
 
+	// Add additional read-only layers on top of the filesystem for launching
+	// a container. Layers are overlaid in the order they are specified, so
+	// later layers shadow files in earlier layers and in the base
+	// filesystem. The layers are composed with overlayfs, so layering
+	// requires snapshot mode; each VM still gets its own writable overlay in
+	// the instance path.
+	//
+	// For example, to share a base rootfs between VMs and add a webserver
+	// install on top:
+	//
+	// 	vm config filesystem /containerfs/base
+	// 	vm config layers /containerfs/apache
+	//
+	// Note: this configuration only applies to containers.
+	Layers []string
+
 	// Set a hostname for a container before launching the init program. If not
 	// set, the hostname will be the VM name. The hostname can also be set by
 	// the init program or other root process in the container.
@@ -783,6 +799,8 @@ func (vm *ContainerVM) Conflicts(vm2 VM) error {
 		return vm.ConflictsContainer(vm2)
 	case *KvmVM:
 		return vm.BaseVM.conflicts(vm2.BaseVM)
+	case *FirecrackerVM:
+		return vm.BaseVM.conflicts(vm2.BaseVM)
 	}
 
 	return errors.New("unknown VM type")
@@ -813,6 +831,7 @@ func (vm *ContainerConfig) String() string {
 	w.Init(&o, 5, 0, 1, ' ', 0)
 	fmt.Fprintln(&o, "Container configuration:")
 	fmt.Fprintf(w, "Filesystem Path:\t%v\n", vm.FilesystemPath)
+	fmt.Fprintf(w, "Layers:\t%v\n", vm.Layers)
 	fmt.Fprintf(w, "Hostname:\t%v\n", vm.Hostname)
 	fmt.Fprintf(w, "Init:\t%v\n", vm.Init)
 	fmt.Fprintf(w, "Pre-init:\t%v\n", vm.Preinit)
@@ -847,6 +866,10 @@ func (vm *ContainerVM) launch() error {
 			if err := vm.overlayMount(); err != nil {
 				return vm.setErrorf("overlayMount: %v", err)
 			}
+		} else if len(vm.Layers) > 0 {
+			// layers are composed with overlayfs which we only set up in
+			// snapshot mode
+			return vm.setErrorf("container layers require snapshot mode")
 		} else {
 			vm.effectivePath = vm.FilesystemPath
 		}
@@ -1071,6 +1094,7 @@ func (vm *ContainerVM) launch() error {
 		}
 		if vm.ptyTCPListener != nil {
 			vm.ptyTCPListener.Close()
+			reservedPorts.Release(vm.ConsolePort)
 		}
 
 		vm.unlinkNetns()
@@ -1145,6 +1169,11 @@ func (vm *ContainerVM) launchNetwork() error {
 			return fmt.Errorf("get bridge: %v", err)
 		}
 
+		if br.DPDK() {
+			// containers use veth pairs which need the kernel datapath
+			return fmt.Errorf("containers do not support DPDK bridge: %v", nic.Bridge)
+		}
+
 		nic.Tap, err = br.CreateContainerTap(nic.Tap, vm.netns, nic.MAC, nic.VLAN, i)
 		if err != nil {
 			return fmt.Errorf("create tap: %v", err)
@@ -1253,6 +1282,12 @@ func (vm *ContainerVM) overlayMount() error {
 		return err
 	}
 
+	for _, layer := range vm.Layers {
+		if _, err := os.Stat(layer); os.IsNotExist(err) {
+			return err
+		}
+	}
+
 	vm.effectivePath = vm.path("fs")
 	workPath := vm.path("fs_work")
 
@@ -1265,6 +1300,14 @@ func (vm *ContainerVM) overlayMount() error {
 		return err
 	}
 
+	// overlayfs stacks lowerdir left to right with the leftmost on top, so
+	// list the layers in reverse order followed by the base filesystem
+	lowers := []string{}
+	for i := len(vm.Layers) - 1; i >= 0; i-- {
+		lowers = append(lowers, vm.Layers[i])
+	}
+	lowers = append(lowers, vm.FilesystemPath)
+
 	// create the overlay mountpoint
 	args := []string{
 		"mount",
@@ -1272,7 +1315,7 @@ func (vm *ContainerVM) overlayMount() error {
 		"overlay",
 		fmt.Sprintf("megamount_%v", vm.ID),
 		"-o",
-		fmt.Sprintf("lowerdir=%v,upperdir=%v,workdir=%v", vm.FilesystemPath, vm.effectivePath, workPath),
+		fmt.Sprintf("lowerdir=%v,upperdir=%v,workdir=%v", strings.Join(lowers, ":"), vm.effectivePath, workPath),
 		vm.effectivePath,
 	}
 	log.Debug("mounting overlay: %v", args)
@@ -1347,9 +1390,9 @@ func (vm *ContainerVM) console(pseudotty *os.File) {
 
 	go serve(l)
 
-	l, err = net.Listen("tcp", ":0")
+	l, err = reservedPorts.Listen(fmt.Sprintf("console %v", vm.Name))
 	if err != nil {
-		log.Error("failed to open tcp socket for container console")
+		log.Error("failed to open tcp socket for container console: %v", err)
 		return
 	}
 	vm.ptyTCPListener = l
@@ -131,25 +131,34 @@ func deployCopy(hosts []string, user, remotePath string) []error {
 
 	var errs []error
 
+	for _, host := range hosts {
+		if err := deployCopyHost(host, user, remotePath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// deployCopyHost scps the running minimega binary to remotePath on host.
+func deployCopyHost(host, user, remotePath string) error {
 	minimegaBinary := fmt.Sprintf("/proc/%v/exe", os.Getpid())
 	log.Debug("minimega binary: %v", minimegaBinary)
 
-	for _, host := range hosts {
-		command := []string{"scp", "-B", "-o", "StrictHostKeyChecking=no", minimegaBinary}
-		if user != "" {
-			command = append(command, fmt.Sprintf("%v@%v:%v", user, host, remotePath))
-		} else {
-			command = append(command, fmt.Sprintf("%v:%v", host, remotePath))
-		}
-		log.Debug("scp command: %v", command)
+	command := []string{"scp", "-B", "-o", "StrictHostKeyChecking=no", minimegaBinary}
+	if user != "" {
+		command = append(command, fmt.Sprintf("%v@%v:%v", user, host, remotePath))
+	} else {
+		command = append(command, fmt.Sprintf("%v:%v", host, remotePath))
+	}
+	log.Debug("scp command: %v", command)
 
-		out, err := processWrapper(command...)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("%v: %v", err, out))
-		}
+	out, err := processWrapper(command...)
+	if err != nil {
+		return fmt.Errorf("%v: %v", err, out)
 	}
 
-	return errs
+	return nil
 }
 
 func deployRun(hosts []string, user, remotePath string, sudo bool) []error {
@@ -157,6 +166,18 @@ func deployRun(hosts []string, user, remotePath string, sudo bool) []error {
 
 	var errs []error
 
+	for _, host := range hosts {
+		if err := deployRunHost(host, user, remotePath, sudo); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// deployRunHost launches the previously copied minimega binary on host with
+// the same flags as this instance (or the "deploy flags" override).
+func deployRunHost(host, user, remotePath string, sudo bool) error {
 	// minimega command
 	flags := deployGetFlags()
 	log.Debug("minimega flags: %v", flags)
@@ -177,23 +198,21 @@ func deployRun(hosts []string, user, remotePath string, sudo bool) []error {
 		minimegaCommand = fmt.Sprintf("nohup %v %v > %v 2>%v &", remotePath, flags, stdout, stderr)
 	}
 
-	for _, host := range hosts {
-		command := []string{"ssh", "-o", "StrictHostKeyChecking=no"}
-		if user != "" {
-			command = append(command, fmt.Sprintf("%v@%v", user, host))
-		} else {
-			command = append(command, fmt.Sprintf("%v", host))
-		}
-		command = append(command, minimegaCommand)
-		log.Debug("ssh command: %v", command)
+	command := []string{"ssh", "-o", "StrictHostKeyChecking=no"}
+	if user != "" {
+		command = append(command, fmt.Sprintf("%v@%v", user, host))
+	} else {
+		command = append(command, fmt.Sprintf("%v", host))
+	}
+	command = append(command, minimegaCommand)
+	log.Debug("ssh command: %v", command)
 
-		out, err := processWrapper(command...)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("%v: %v", err, out))
-		}
+	out, err := processWrapper(command...)
+	if err != nil {
+		return fmt.Errorf("%v: %v", err, out)
 	}
 
-	return errs
+	return nil
 }
 
 func deployGetFlags() string {
@@ -0,0 +1,182 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sandia-minimega/minimega/v2/internal/ron"
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// detonation packages the snapshot-before-detonation loop: snapshot a set of
+// VMs (disk and memory, via QEMU's savevm), run a cc command in them,
+// collect artifacts for a fixed duration, and restore the snapshots. The cc
+// responses are the artifacts and survive the restore, so samples can be
+// detonated repeatedly from a pristine state. See `detonate`.
+type detonation struct {
+	// Name identifies the detonation, doubling as the snapshot tag and the
+	// cc prefix for the detonated command
+	Name string
+
+	// VMs being detonated, by name
+	VMs []string
+
+	// Command run in the VMs via cc
+	Command []string
+
+	// Duration to let the command run before restoring
+	Duration time.Duration
+
+	mu sync.Mutex // guards below
+
+	state   string
+	started time.Time
+
+	// closed by `clear detonate` to restore early
+	abort chan bool
+}
+
+// State reports the detonation's current phase, one of "snapshotting",
+// "detonating", "restoring", "completed", or an error.
+func (d *detonation) State() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.state
+}
+
+func (d *detonation) setState(state string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.state = state
+}
+
+// StartDetonation snapshots the targeted VMs and runs the command in them,
+// restoring the snapshots after the duration elapses. The loop runs in the
+// background; progress is reported by `detonate`.
+func (ns *Namespace) StartDetonation(name, target string, command []string, duration time.Duration) error {
+	if _, ok := ns.detonations[name]; ok {
+		return fmt.Errorf("detonation already exists: %v", name)
+	}
+
+	// collect the targeted VMs, they must be local KVM VMs since the
+	// snapshots are taken over QMP
+	var vms []*KvmVM
+
+	ns.VMs.Apply(target, func(vm VM, _ bool) (bool, error) {
+		if vm.GetState() != VM_RUNNING {
+			return false, fmt.Errorf("vm %v is not running", vm.GetName())
+		}
+
+		kvm, ok := vm.(*KvmVM)
+		if !ok {
+			return false, fmt.Errorf("vm %v is not a KVM VM", vm.GetName())
+		}
+
+		vms = append(vms, kvm)
+		return true, nil
+	})
+
+	if len(vms) == 0 {
+		return vmNotFound(target)
+	}
+
+	d := &detonation{
+		Name:     name,
+		Command:  command,
+		Duration: duration,
+		state:    "snapshotting",
+		started:  time.Now(),
+		abort:    make(chan bool),
+	}
+
+	for _, vm := range vms {
+		d.VMs = append(d.VMs, vm.GetName())
+	}
+
+	ns.detonations[name] = d
+
+	go ns.runDetonation(d, vms)
+
+	return nil
+}
+
+// runDetonation drives the detonation loop for the already-snapshotted VMs.
+func (ns *Namespace) runDetonation(d *detonation, vms []*KvmVM) {
+	// snapshot first so that the command only ever runs against state we
+	// can roll back
+	for _, vm := range vms {
+		if err := vm.SaveSnapshot(d.Name); err != nil {
+			d.setState(fmt.Sprintf("snapshot %v: %v", vm.GetName(), err))
+			return
+		}
+	}
+
+	d.setState("detonating")
+
+	for _, vm := range vms {
+		ns.ccServer.NewCommand(&ron.Command{
+			Command: d.Command,
+			Prefix:  d.Name,
+			Filter:  &ron.Filter{UUID: vm.GetUUID()},
+		})
+	}
+
+	select {
+	case <-time.After(d.Duration):
+	case <-d.abort:
+		log.Info("detonation %v aborted, restoring", d.Name)
+	}
+
+	d.setState("restoring")
+
+	// delete the posted commands so that the VMs don't re-run them when
+	// their cc clients reconnect post-restore; collected responses survive
+	if err := ns.ccServer.DeleteCommands(d.Name); err != nil {
+		log.Error("detonation %v: %v", d.Name, err)
+	}
+
+	state := "completed"
+
+	for _, vm := range vms {
+		if err := vm.RestoreSnapshot(d.Name); err != nil {
+			state = fmt.Sprintf("restore %v: %v", vm.GetName(), err)
+			continue
+		}
+
+		if err := vm.DeleteSnapshot(d.Name); err != nil {
+			log.Error("detonation %v: delete snapshot on %v: %v", d.Name, vm.GetName(), err)
+		}
+	}
+
+	d.setState(state)
+}
+
+// ClearDetonations forgets detonations, aborting (and restoring) any that
+// are still running. An empty name or the wildcard clears all detonations.
+func (ns *Namespace) ClearDetonations(name string) error {
+	for _, d := range ns.detonations {
+		if name != "" && name != Wildcard && d.Name != name {
+			continue
+		}
+
+		d.mu.Lock()
+		select {
+		case <-d.abort:
+			// already aborted
+		default:
+			close(d.abort)
+		}
+		d.mu.Unlock()
+
+		delete(ns.detonations, d.Name)
+	}
+
+	return nil
+}
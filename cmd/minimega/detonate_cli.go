@@ -0,0 +1,96 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var detonateCLIHandlers = []minicli.Handler{
+	{ // detonate
+		HelpShort: "snapshot VMs, run a command, and restore",
+		HelpLong: `
+Package the malware-detonation loop natively: snapshot a set of VMs (disk and
+memory, via QEMU's savevm, so the VMs need writable qcow2 disks), run a
+command in them via cc, let it run for the given duration, and then restore
+the snapshots so every detonation starts from the same pristine state:
+
+	detonate sample1 worker[1-4] 5m /tmp/sample.exe
+
+The cc responses collected during the window are the artifacts; they are
+filed under the detonation's name (as the cc prefix) and survive the
+restore. The loop runs in the background; running detonate with no arguments
+shows each detonation's phase. The VMs must be running, local, and have cc
+agents installed.
+
+To abort a running detonation, restoring the snapshots immediately, or to
+forget completed ones:
+
+	clear detonate sample1
+	clear detonate`,
+		Patterns: []string{
+			"detonate",
+			"detonate <name> <vm target> <duration> <command>...",
+		},
+		Call:    wrapBroadcastCLI(cliDetonate),
+		Suggest: wrapVMSuggest(VM_RUNNING, true),
+	},
+	{ // clear detonate
+		HelpShort: "abort or forget detonations",
+		HelpLong: `
+Abort one or all running detonations, restoring their snapshots immediately,
+and forget completed ones. See "help detonate" for more information.`,
+		Patterns: []string{
+			"clear detonate [name]",
+		},
+		Call: wrapBroadcastCLI(cliDetonateClear),
+	},
+}
+
+func cliDetonate(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if name := c.StringArgs["name"]; name != "" {
+		if !validName.MatchString(name) {
+			return validNameErr
+		}
+
+		duration, err := time.ParseDuration(c.StringArgs["duration"])
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid duration: `%v`", c.StringArgs["duration"])
+		}
+
+		return ns.StartDetonation(name, c.StringArgs["vm"], c.ListArgs["command"], duration)
+	}
+
+	resp.Header = []string{"name", "vms", "command", "duration", "state"}
+
+	names := []string{}
+	for name := range ns.detonations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		d := ns.detonations[name]
+
+		resp.Tabular = append(resp.Tabular, []string{
+			d.Name,
+			strings.Join(d.VMs, ","),
+			strings.Join(d.Command, " "),
+			d.Duration.String(),
+			d.State(),
+		})
+	}
+
+	return nil
+}
+
+func cliDetonateClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	return ns.ClearDetonations(c.StringArgs["name"])
+}
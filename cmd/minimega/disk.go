@@ -148,9 +148,20 @@ func checkDiskInUse(path string) (bool, error) {
 	return strings.Contains(string(locks), strconv.FormatUint(stat.Ino, 10)), nil
 }
 
-// diskCreate creates a new disk image, dst, of given size/format.
-func diskCreate(format, dst, size string) error {
-	out, err := processWrapper("qemu-img", "create", "-f", format, dst, size)
+// diskCreate creates a new disk image, dst, of given size/format. If keyFile
+// is non-empty, the image is created with inline LUKS encryption using that
+// key (qcow2 only).
+func diskCreate(format, dst, size, keyFile string) error {
+	args := []string{"qemu-img", "create", "-f", format}
+
+	if keyFile != "" {
+		args = append(args, "--object", fmt.Sprintf("secret,id=sec0,file=%v", keyFile))
+		args = append(args, "-o", "encrypt.format=luks,encrypt.key-secret=sec0")
+	}
+
+	args = append(args, dst, size)
+
+	out, err := processWrapper(args...)
 	if err != nil {
 		log.Error("diskCreate: %v", out)
 		return err
@@ -158,8 +169,10 @@ func diskCreate(format, dst, size string) error {
 	return nil
 }
 
-// diskSnapshot creates a new image, dst, using src as the backing image.
-func diskSnapshot(src, dst string) error {
+// diskSnapshot creates a new image, dst, using src as the backing image. If
+// keyFile is non-empty, the new image is LUKS-encrypted with that key so that
+// writes to the overlay stay encrypted at rest like the backing image.
+func diskSnapshot(src, dst, keyFile string) error {
 	if !strings.HasPrefix(src, *f_iomBase) {
 		log.Warn("minimega expects backing images to be in the files directory")
 	}
@@ -174,7 +187,20 @@ func diskSnapshot(src, dst string) error {
 		return fmt.Errorf("[image %s] error getting src relative to dst: %v", src, err)
 	}
 
-	out, err := processWrapper("qemu-img", "create", "-f", "qcow2", "-b", relSrc, "-F", info.Format, dst)
+	args := []string{"qemu-img", "create", "-f", "qcow2"}
+
+	if keyFile != "" {
+		args = append(args, "--object", fmt.Sprintf("secret,id=sec0,file=%v", keyFile))
+		args = append(args, "-o", "encrypt.format=luks,encrypt.key-secret=sec0")
+
+		// opening an encrypted backing image requires its key, so skip the
+		// probe and pass the size we already have
+		args = append(args, "-u", "-b", relSrc, "-F", info.Format, dst, strconv.FormatInt(info.VirtualSize, 10))
+	} else {
+		args = append(args, "-b", relSrc, "-F", info.Format, dst)
+	}
+
+	out, err := processWrapper(args...)
 
 	if err != nil {
 		return fmt.Errorf("[image %s] %v: %v", src, out, err)
@@ -27,9 +27,15 @@ Example of creating a new disk:
 	disk create qcow2 foo.qcow2 100G
 
 The size argument is the size in bytes, or using optional suffixes "k"
-(kilobyte), "M" (megabyte), "G" (gigabyte), "T" (terabyte).		
+(kilobyte), "M" (megabyte), "G" (gigabyte), "T" (terabyte).
+
+A qcow2 disk may be created with inline LUKS encryption by passing a key file
+holding the passphrase; attach it with the matching luks= diskspec option, see
+"vm config disks":
+
+	disk create qcow2 secure.qcow2 100G /etc/minimega/keys/secure.key
 		`,
-		Patterns: []string{"disk create <qcow2,raw> <image name> <size>"},
+		Patterns: []string{"disk create <qcow2,raw> <image name> <size> [key file]"},
 		Call:     wrapSimpleCLI(cliDiskCreate),
 	},
 	{
@@ -200,12 +206,17 @@ func cliDiskInject(ns *Namespace, c *minicli.Command, resp *minicli.Response) er
 func cliDiskCreate(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	image := getImage(c)
 	size := c.StringArgs["size"]
+	key := c.StringArgs["key"]
 	format := "raw"
 	if _, ok := c.BoolArgs["qcow2"]; ok {
 		format = "qcow2"
 	}
 
-	return diskCreate(format, image, size)
+	if key != "" && format != "qcow2" {
+		return errors.New("LUKS encryption requires a qcow2 image")
+	}
+
+	return diskCreate(format, image, size, key)
 }
 
 func cliDiskSnapshot(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
@@ -226,7 +237,7 @@ func cliDiskSnapshot(ns *Namespace, c *minicli.Command, resp *minicli.Response)
 
 	log.Debug("destination image: %v", dst)
 
-	return diskSnapshot(image, dst)
+	return diskSnapshot(image, dst, "")
 }
 
 func cliDiskInfo(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
@@ -20,6 +20,11 @@ type DiskConfig struct {
 	Interface    string
 	Cache        string
 
+	// KeyFile is the path to a file holding the LUKS passphrase for an
+	// encrypted disk, passed to QEMU as a secret object. Empty for
+	// unencrypted disks.
+	KeyFile string
+
 	// Raw string that we used when creating this disk config will be
 	// reparsed if we ever clone the VM that has this config.
 	Raw string
@@ -33,6 +38,21 @@ func ParseDiskConfig(spec string, snapshot bool) (*DiskConfig, error) {
 	// example: /data/minimega/images/linux.qcow2,virtio,writeback
 	f := strings.Split(spec, ",")
 
+	// pull out key=value options (currently just luks=) so that the
+	// positional fields below are unaffected by where they appear
+	var key string
+
+	fields := []string{}
+	for _, v := range f {
+		if strings.HasPrefix(v, "luks=") {
+			key = strings.TrimPrefix(v, "luks=")
+			continue
+		}
+
+		fields = append(fields, v)
+	}
+	f = fields
+
 	// path, interface, cache
 	var p, i, c string
 
@@ -61,7 +81,7 @@ func ParseDiskConfig(spec string, snapshot bool) (*DiskConfig, error) {
 		return nil, errors.New("malformed diskspec")
 	}
 
-	log.Info(`got path="%v", interface="%v", cache="%v"`, p, i, c)
+	log.Info(`got path="%v", interface="%v", cache="%v", luks="%v"`, p, i, c, key)
 
 	p = checkPath(p)
 
@@ -69,6 +89,7 @@ func ParseDiskConfig(spec string, snapshot bool) (*DiskConfig, error) {
 		Path:      p,
 		Cache:     c,
 		Interface: i,
+		KeyFile:   key,
 	}, nil
 }
 
@@ -87,6 +108,10 @@ func (c DiskConfig) String() string {
 		parts = append(parts, c.Cache)
 	}
 
+	if c.KeyFile != "" {
+		parts = append(parts, "luks="+c.KeyFile)
+	}
+
 	return strings.Join(parts, ",")
 }
 
@@ -0,0 +1,187 @@
+// Copyright 2015-2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// DNSSuffix is the domain that the embedded resolver is authoritative
+	// for. Names have the form <vmname>.<namespace>.mm.
+	DNSSuffix = "mm."
+
+	// TTL for all records served by the embedded resolver. Short since the
+	// snooped IPs can change as the experiment runs.
+	dnsTTL = 60
+)
+
+// dnsServer is the embedded resolver that serves `<vmname>.<namespace>.mm`
+// from the snooped VM IPs. Unlike dnsmasq, it requires no host configuration
+// and follows VMs as their discovered IPs change. The listen address is
+// typically a host tap IP so that guests can reach it, and can be handed to
+// guests via `dnsmasq configure <ID> options option:dns-server,<addr>`.
+type dnsServer struct {
+	Addr string // Address the server is listening on (host:port)
+
+	udp *dns.Server
+	tcp *dns.Server
+}
+
+var (
+	dnsEmbedded     *dnsServer
+	dnsEmbeddedLock sync.Mutex
+)
+
+// dnsStart starts the embedded DNS server on the specified address.
+func dnsStart(addr string) error {
+	dnsEmbeddedLock.Lock()
+	defer dnsEmbeddedLock.Unlock()
+
+	if dnsEmbedded != nil {
+		return fmt.Errorf("embedded DNS server already running on %v", dnsEmbedded.Addr)
+	}
+
+	if !strings.Contains(addr, ":") {
+		addr += ":53"
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc(DNSSuffix, handleDNSRequest)
+
+	d := &dnsServer{
+		Addr: addr,
+		udp:  &dns.Server{Addr: addr, Net: "udp", Handler: mux},
+		tcp:  &dns.Server{Addr: addr, Net: "tcp", Handler: mux},
+	}
+
+	for _, server := range []*dns.Server{d.udp, d.tcp} {
+		go func(server *dns.Server) {
+			if err := server.ListenAndServe(); err != nil {
+				log.Error("embedded DNS server (%v): %v", server.Net, err)
+			}
+		}(server)
+	}
+
+	dnsEmbedded = d
+
+	return nil
+}
+
+// dnsStop stops the embedded DNS server, if it is running.
+func dnsStop() error {
+	dnsEmbeddedLock.Lock()
+	defer dnsEmbeddedLock.Unlock()
+
+	if dnsEmbedded == nil {
+		return nil
+	}
+
+	for _, server := range []*dns.Server{dnsEmbedded.udp, dnsEmbedded.tcp} {
+		if err := server.Shutdown(); err != nil {
+			log.Error("embedded DNS server (%v): %v", server.Net, err)
+		}
+	}
+
+	dnsEmbedded = nil
+
+	return nil
+}
+
+// handleDNSRequest answers queries for `<vmname>.<namespace>.mm` with the
+// VM's discovered IPs.
+func handleDNSRequest(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	for _, q := range r.Question {
+		log.Debug("embedded DNS query: %v", q.Name)
+
+		v4, v6 := dnsLookup(q.Name)
+
+		if len(v4) == 0 && len(v6) == 0 {
+			m.Rcode = dns.RcodeNameError
+			continue
+		}
+
+		hdr := dns.RR_Header{
+			Name:  q.Name,
+			Class: dns.ClassINET,
+			Ttl:   dnsTTL,
+		}
+
+		switch q.Qtype {
+		case dns.TypeA:
+			hdr.Rrtype = dns.TypeA
+			for _, ip := range v4 {
+				m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: ip})
+			}
+		case dns.TypeAAAA:
+			hdr.Rrtype = dns.TypeAAAA
+			for _, ip := range v6 {
+				m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: ip})
+			}
+		}
+	}
+
+	w.WriteMsg(m)
+}
+
+// dnsLookup resolves a query name of the form `<vmname>.<namespace>.mm.` to
+// the VM's discovered IPs. VMs on the local host are resolved directly, VMs
+// elsewhere in the namespace are resolved via the mesh.
+func dnsLookup(name string) (v4, v6 []net.IP) {
+	labels := strings.Split(strings.TrimSuffix(dns.Fqdn(name), "."+DNSSuffix), ".")
+	if len(labels) != 2 {
+		return nil, nil
+	}
+
+	vmName, nsName := labels[0], labels[1]
+
+	namespaceLock.Lock()
+	ns, ok := namespaces[nsName]
+	namespaceLock.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	// local VMs first -- cheap
+	if vm := ns.FindVM(vmName); vm != nil {
+		vm.UpdateNetworks()
+		return vmIPs(vm)
+	}
+
+	// fall back to searching the whole namespace
+	for _, vm := range globalVMs(ns) {
+		if vm.GetName() == vmName {
+			return vmIPs(vm)
+		}
+	}
+
+	return nil, nil
+}
+
+// vmIPs collects the snooped IPs for all of a VM's interfaces.
+func vmIPs(vm VM) (v4, v6 []net.IP) {
+	for _, nic := range vm.GetNetworks() {
+		if ip := net.ParseIP(nic.IP4); ip != nil {
+			v4 = append(v4, ip)
+		}
+		if ip := net.ParseIP(nic.IP6); ip != nil {
+			v6 = append(v6, ip)
+		}
+	}
+
+	return
+}
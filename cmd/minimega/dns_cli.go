@@ -0,0 +1,73 @@
+// Copyright 2015-2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var dnsCLIHandlers = []minicli.Handler{
+	{ // dns
+		HelpShort: "start an embedded DNS server for VM names",
+		HelpLong: `
+Start an embedded DNS server that resolves <vmname>.<namespace>.mm to the
+VM's discovered IP so that experiment services can reference each other by
+name. For example, with a VM named server in namespace foo:
+
+	dig @10.0.0.1 server.foo.mm
+
+The listen address is typically the IP of a host tap so that guests can reach
+the resolver:
+
+	dns listen 10.0.0.1
+
+A port may be specified as part of the listen address, otherwise port 53 is
+used. The resolver can be handed to guests via the built-in DHCP server:
+
+	dnsmasq configure 0 options option:dns-server,10.0.0.1
+
+IPs are discovered by snooping traffic on the VM taps, the same mechanism
+that populates the ip column in "vm info", so a VM is resolvable once it has
+sent traffic. The server answers queries for VMs in all namespaces and across
+the mesh. To stop the server, use "clear dns".`,
+		Patterns: []string{
+			"dns",
+			"dns <listen,> <address>",
+		},
+		Call: wrapSimpleCLI(cliDNS),
+	},
+	{ // clear dns
+		HelpShort: "stop the embedded DNS server",
+		HelpLong: `
+Stops the embedded DNS server. See "help dns" for more information.`,
+		Patterns: []string{
+			"clear dns",
+		},
+		Call: wrapSimpleCLI(cliDNSClear),
+	},
+}
+
+func cliDNS(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["listen"] {
+		return dnsStart(c.StringArgs["address"])
+	}
+
+	// no sub command, show the status
+	resp.Header = []string{"address"}
+	resp.Tabular = [][]string{}
+
+	dnsEmbeddedLock.Lock()
+	defer dnsEmbeddedLock.Unlock()
+
+	if dnsEmbedded != nil {
+		resp.Tabular = append(resp.Tabular, []string{dnsEmbedded.Addr})
+	}
+
+	return nil
+}
+
+func cliDNSClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	return dnsStop()
+}
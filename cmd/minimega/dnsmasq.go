@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
@@ -37,6 +38,10 @@ type dnsmasqServer struct {
 var (
 	dnsmasqServers map[int]*dnsmasqServer
 	dnsmasqID      = NewCounter()
+
+	// dnsmasqStaticLock serializes static registrations, which can arrive
+	// concurrently from VM launch goroutines
+	dnsmasqStaticLock sync.Mutex
 )
 
 var dnsmasqCLIHandlers = []minicli.Handler{
@@ -275,6 +280,63 @@ func (d *dnsmasqServer) writeDHCPhosts() {
 	ioutil.WriteFile(filepath.Join(d.DHCPdir, "dhcp"), []byte(contents), 0755)
 }
 
+// RegisterStatics adds DHCP and DNS entries for any interfaces that carry a
+// static address plan, see ParseNetConfig.
+func (d *dnsmasqServer) RegisterStatics(nets []NetConfig) {
+	var dhcp, dns bool
+
+	for _, n := range nets {
+		if n.IP4Static == "" && n.IP6Static == "" {
+			continue
+		}
+
+		// dnsmasq expects IPv6 addresses in dhcp-host entries to be wrapped
+		// in square brackets
+		addrs := []string{}
+		if n.IP4Static != "" {
+			addrs = append(addrs, trimMask(n.IP4Static))
+		}
+		if n.IP6Static != "" {
+			addrs = append(addrs, "["+trimMask(n.IP6Static)+"]")
+		}
+
+		if n.MAC != "" {
+			d.DHCPhosts[n.MAC] = strings.Join(addrs, ",")
+			dhcp = true
+		}
+
+		if n.Hostname != "" {
+			if n.IP4Static != "" {
+				d.Hostnames[trimMask(n.IP4Static)] = n.Hostname
+				dns = true
+			}
+			if n.IP6Static != "" {
+				d.Hostnames[trimMask(n.IP6Static)] = n.Hostname
+				dns = true
+			}
+		}
+	}
+
+	if dhcp {
+		d.writeDHCPhosts()
+	}
+	if dns {
+		d.writeHostFile()
+	}
+}
+
+// dnsmasqRegisterStatics pushes static addresses and hostnames from a VM's
+// network configs to all running dnsmasq servers so that the guest picks them
+// up via DHCP and DNS.
+func dnsmasqRegisterStatics(nets []NetConfig) {
+	dnsmasqStaticLock.Lock()
+	defer dnsmasqStaticLock.Unlock()
+
+	for _, d := range dnsmasqServers {
+		d.RegisterStatics(nets)
+	}
+}
+
 func (d *dnsmasqServer) writeDHCPopts() {
 	// Generate the contents
 	var contents string
@@ -398,11 +460,23 @@ func cliDnsmasq(ns *Namespace, c *minicli.Command, resp *minicli.Response) error
 		// We don't need to differentiate between the two start commands
 		// because dnsmasqStart expects the zero string value when values
 		// are not specified.
-		return dnsmasqStart(
+		err := dnsmasqStart(
 			c.StringArgs["listen"],
 			c.StringArgs["low"],
 			c.StringArgs["high"],
 			c.StringArgs["config"])
+		if err != nil {
+			return err
+		}
+
+		// push static address plans from VMs that have already launched to
+		// the new server
+		ns.VMs.Apply(Wildcard, func(vm VM, _ bool) (bool, error) {
+			dnsmasqRegisterStatics(vm.GetNetworks())
+			return false, nil
+		})
+
+		return nil
 	}
 
 	// Must be "list"
@@ -46,6 +46,7 @@ var externalDependencies = map[string]bool{
 	"blockdev":  true, // used in disk.go
 	"ovs-vsctl": true, // used in external.go
 	"taskset":   true, // used in optimize.go
+	"ethtool":   true, // used in netdiag.go
 	"tar":       true, // used in cli.go
 }
 
@@ -287,6 +288,21 @@ func lsModule(s string) bool {
 	return strings.Contains(out, s)
 }
 
+// helper, if set via the -helper flag, is prepended to privileged commands
+// so that minimega can run without root. See privProcessWrapper.
+var helper []string
+
+// privProcessWrapper is processWrapper for commands that require root, such
+// as configuring interfaces. The helper prefix, if any, is prepended so that
+// the command can be delegated when minimega itself is unprivileged.
+func privProcessWrapper(args ...string) (string, error) {
+	if len(helper) != 0 {
+		args = append(append([]string{}, helper...), args...)
+	}
+
+	return processWrapper(args...)
+}
+
 // processWrapper executes the given arg list and returns a combined
 // stdout/stderr and any errors. processWrapper blocks until the process exits.
 func processWrapper(args ...string) (string, error) {
@@ -0,0 +1,565 @@
+// Copyright 2015-2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sandia-minimega/minimega/v2/internal/bridge"
+	"github.com/sandia-minimega/minimega/v2/internal/ron"
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// DefaultFirecrackerAppend is the kernel command line suggested by the
+// firecracker documentation for fast boots with a minimal device model.
+const DefaultFirecrackerAppend = "console=ttyS0 reboot=k panic=1 pci=off"
+
+// FirecrackerConfig contains all the configs possible for a firecracker VM.
+// When a new field is added, make sure to regenerate the CLI handlers via `go
+// generate`.
+type FirecrackerConfig struct {
+	// Set the firecracker binary name to invoke. Relative paths are ok.
+	//
+	// Note: this configuration only applies to firecracker-based VMs.
+	//
+	// Default: "firecracker"
+	FirecrackerPath string
+
+	// Attach an uncompressed kernel image (vmlinux) to a firecracker VM.
+	// Firecracker always boots directly from a kernel -- there is no BIOS.
+	//
+	// Note: this configuration only applies to firecracker-based VMs.
+	FirecrackerKernelPath string
+
+	// Attach a root filesystem image (e.g. raw ext4) to a firecracker VM.
+	// Filesystems launched in snapshot mode are copied to the VM's instance
+	// directory so a single image may safely be used for many VMs.
+	//
+	// Note: this configuration only applies to firecracker-based VMs.
+	FirecrackerRootfsPath string
+
+	// Set the kernel command line for a firecracker VM.
+	//
+	// Note: this configuration only applies to firecracker-based VMs.
+	//
+	// Default: "console=ttyS0 reboot=k panic=1 pci=off"
+	FirecrackerAppend string
+}
+
+// Copy makes a deep copy and returns reference to the new struct.
+func (old FirecrackerConfig) Copy() FirecrackerConfig {
+	// Copy all fields
+	res := old
+
+	return res
+}
+
+type FirecrackerVM struct {
+	*BaseVM           // embed
+	FirecrackerConfig // embed
+}
+
+// firecrackerConfigJSON mirrors the subset of the firecracker configuration
+// file format that we use. See the firecracker docs for the full schema.
+type firecrackerConfigJSON struct {
+	BootSource struct {
+		KernelImagePath string `json:"kernel_image_path"`
+		BootArgs        string `json:"boot_args"`
+	} `json:"boot-source"`
+	Drives []struct {
+		DriveID      string `json:"drive_id"`
+		PathOnHost   string `json:"path_on_host"`
+		IsRootDevice bool   `json:"is_root_device"`
+		IsReadOnly   bool   `json:"is_read_only"`
+	} `json:"drives"`
+	MachineConfig struct {
+		VcpuCount  uint64 `json:"vcpu_count"`
+		MemSizeMib uint64 `json:"mem_size_mib"`
+	} `json:"machine-config"`
+	NetworkInterfaces []struct {
+		IfaceID     string `json:"iface_id"`
+		GuestMAC    string `json:"guest_mac"`
+		HostDevName string `json:"host_dev_name"`
+	} `json:"network-interfaces"`
+}
+
+func NewFirecracker(name, namespace string, config VMConfig) (*FirecrackerVM, error) {
+	vm := new(FirecrackerVM)
+
+	vm.BaseVM = NewBaseVM(name, namespace, config)
+	vm.Type = FIRECRACKER
+
+	vm.FirecrackerConfig = config.FirecrackerConfig.Copy() // deep-copy configured fields
+
+	return vm, nil
+}
+
+func (vm *FirecrackerVM) Copy() VM {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	vm2 := new(FirecrackerVM)
+
+	// Make shallow copies of all fields
+	*vm2 = *vm
+
+	// Make deep copies
+	vm2.BaseVM = vm.BaseVM.copy()
+	vm2.FirecrackerConfig = vm.FirecrackerConfig.Copy()
+
+	return vm2
+}
+
+// Launch a new firecracker VM.
+func (vm *FirecrackerVM) Launch() error {
+	defer vm.lock.Unlock()
+	return vm.launch()
+}
+
+// Recover an existing firecracker VM. This resets the ID and PID for the VM
+// and unlocks the VM (since VMs come pre-locked when created via NewBaseVM).
+func (vm *FirecrackerVM) Recover(id string, pid int) error {
+	vm.ID, _ = strconv.Atoi(id)
+	vm.Pid = pid
+	vm.instancePath = filepath.Join(*f_base, id)
+
+	vm.lock.Unlock()
+	return nil
+}
+
+// Flush cleans up all resources allocated to the VM which includes all the
+// network taps.
+func (vm *FirecrackerVM) Flush() error {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	for _, net := range vm.Networks {
+		// Handle already disconnected taps differently since they aren't
+		// assigned to any bridges.
+		if net.VLAN == DisconnectedVLAN {
+			if err := bridge.DestroyTap(net.Tap); err != nil {
+				log.Error("leaked tap %v: %v", net.Tap, err)
+			}
+
+			continue
+		}
+
+		br, err := getBridge(net.Bridge)
+		if err != nil {
+			return err
+		}
+
+		if err := br.DestroyTap(net.Tap); err != nil {
+			log.Error("leaked tap %v: %v", net.Tap, err)
+		}
+	}
+
+	return vm.BaseVM.Flush()
+}
+
+func (vm *FirecrackerVM) Config() *BaseConfig {
+	return &vm.BaseConfig
+}
+
+func (vm *FirecrackerVM) Start() (err error) {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	if vm.State&VM_RUNNING != 0 {
+		return nil
+	}
+
+	if vm.State == VM_QUIT || vm.State == VM_ERROR {
+		log.Info("relaunching VM: %v", vm.ID)
+
+		// Create a new channel since we closed the other one to indicate that
+		// the VM should quit.
+		vm.kill = make(chan bool)
+
+		// Launch handles setting the VM to error state
+		if err := vm.launch(); err != nil {
+			return err
+		}
+	}
+
+	log.Info("starting VM: %v", vm.ID)
+	if err := vm.start(); err != nil {
+		return vm.setErrorf("unable to start: %v", err)
+	}
+
+	vm.setState(VM_RUNNING)
+
+	return nil
+}
+
+func (vm *FirecrackerVM) Stop() error {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	if vm.State != VM_RUNNING {
+		return vmNotRunning(strconv.Itoa(vm.ID))
+	}
+
+	// firecracker's minimal device model has no way to pause and resume the
+	// guest so we cannot support `vm stop`.
+	return errors.New("firecracker VMs do not support pausing")
+}
+
+func (vm *FirecrackerVM) String() string {
+	return fmt.Sprintf("%s:%d:firecracker", hostname, vm.ID)
+}
+
+func (vm *FirecrackerVM) Info(field string) (string, error) {
+	// If the field is handled by BaseVM, return it
+	if v, err := vm.BaseVM.Info(field); err == nil {
+		return v, nil
+	}
+
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	switch field {
+	case "pid":
+		return strconv.Itoa(vm.Pid), nil
+	}
+
+	return vm.FirecrackerConfig.Info(field)
+}
+
+func (vm *FirecrackerVM) Conflicts(vm2 VM) error {
+	switch vm2 := vm2.(type) {
+	case *FirecrackerVM:
+		return vm.ConflictsFirecracker(vm2)
+	case *KvmVM:
+		return vm.BaseVM.conflicts(vm2.BaseVM)
+	case *ContainerVM:
+		return vm.BaseVM.conflicts(vm2.BaseVM)
+	}
+
+	return errors.New("unknown VM type")
+}
+
+// ConflictsFirecracker tests whether vm and vm2 share a root filesystem and
+// returns an error if one of them is not running in snapshot mode. Also
+// checks whether the BaseVMs conflict.
+func (vm *FirecrackerVM) ConflictsFirecracker(vm2 *FirecrackerVM) error {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	if vm.FirecrackerRootfsPath == vm2.FirecrackerRootfsPath && (!vm.Snapshot || !vm2.Snapshot) {
+		return fmt.Errorf("rootfs conflict with vm %v: %v", vm.Name, vm.FirecrackerRootfsPath)
+	}
+
+	return vm.BaseVM.conflicts(vm2.BaseVM)
+}
+
+func (vm *FirecrackerVM) Screenshot(size int) ([]byte, error) {
+	return nil, errors.New("cannot take screenshot of firecracker VM")
+}
+
+func (vm *FirecrackerConfig) String() string {
+	// create output
+	var o bytes.Buffer
+	w := new(tabwriter.Writer)
+	w.Init(&o, 5, 0, 1, ' ', 0)
+	fmt.Fprintln(&o, "Firecracker configuration:")
+	fmt.Fprintf(w, "Firecracker Path:\t%v\n", vm.FirecrackerPath)
+	fmt.Fprintf(w, "Kernel Path:\t%v\n", vm.FirecrackerKernelPath)
+	fmt.Fprintf(w, "Rootfs Path:\t%v\n", vm.FirecrackerRootfsPath)
+	fmt.Fprintf(w, "Kernel Append:\t%v\n", vm.FirecrackerAppend)
+	w.Flush()
+	fmt.Fprintln(&o)
+	return o.String()
+}
+
+// launch is the low-level launch function for firecracker VMs. Unlike QEMU,
+// firecracker starts running the guest as soon as the process starts so
+// launch only stages the VM -- the process is exec'd by start. The caller
+// should hold the VM's lock.
+func (vm *FirecrackerVM) launch() error {
+	log.Info("launching vm: %v", vm.ID)
+
+	if vm.FirecrackerKernelPath == "" {
+		return vm.setErrorf("unable to launch VM: no kernel specified")
+	}
+
+	if vm.FirecrackerRootfsPath == "" {
+		return vm.setErrorf("unable to launch VM: no rootfs specified")
+	}
+
+	// If this is the first time launching the VM, do the final configuration
+	// check and create directories for it.
+	if vm.State == VM_BUILDING {
+		// create a directory for the VM at the instance path
+		if err := os.MkdirAll(vm.instancePath, os.FileMode(0700)); err != nil {
+			return vm.setErrorf("unable to create VM dir: %v", err)
+		}
+
+		// firecracker has no backing file support for raw images so snapshot
+		// mode copies the rootfs into the instance path
+		if vm.Snapshot {
+			dst := vm.path("rootfs")
+			if err := copyFileContents(vm.FirecrackerRootfsPath, dst); err != nil {
+				return vm.setErrorf("unable to snapshot %v: %v", vm.FirecrackerRootfsPath, err)
+			}
+
+			vm.FirecrackerRootfsPath = dst
+		}
+
+		if err := vm.createInstancePathAlias(); err != nil {
+			return vm.setErrorf("createInstancePathAlias: %v", err)
+		}
+	}
+
+	mustWrite(vm.path("name"), vm.Name)
+
+	if err := vm.createTaps(); err != nil {
+		return err
+	}
+
+	config, err := vm.firecrackerConfig()
+	if err != nil {
+		return vm.setErrorf("unable to build firecracker config: %v", err)
+	}
+
+	if err := ioutil.WriteFile(vm.path("firecracker.json"), config, 0644); err != nil {
+		return vm.setErrorf("unable to write firecracker config: %v", err)
+	}
+
+	return nil
+}
+
+// start execs the firecracker process for a previously staged VM. The caller
+// should hold the VM's lock.
+func (vm *FirecrackerVM) start() error {
+	// if the FirecrackerPath is not absolute, try a lookup based on $PATH
+	fc := vm.FirecrackerPath
+	if !filepath.IsAbs(fc) {
+		v, err := process(fc)
+		if err != nil {
+			return err
+		}
+		fc = v
+	}
+
+	// remove any stale API socket from a previous run
+	os.Remove(vm.path("firecracker.sock"))
+
+	args := []string{
+		"--api-sock", vm.path("firecracker.sock"),
+		"--config-file", vm.path("firecracker.json"),
+	}
+
+	var sOut bytes.Buffer
+	var sErr bytes.Buffer
+
+	cmd := &exec.Cmd{
+		Path:   fc,
+		Args:   append([]string{fc}, args...),
+		Stdout: &sOut,
+		Stderr: &sErr,
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("unable to start firecracker: %v %v", err, sErr.String())
+	}
+
+	vm.Pid = cmd.Process.Pid
+	log.Debug("vm %v has pid %v", vm.ID, vm.Pid)
+
+	// Channel to signal when the process has exited
+	var waitChan = make(chan bool)
+
+	vm.waitForExit(cmd.Process, waitChan)
+	vm.waitToKill(cmd.Process, waitChan)
+
+	return nil
+}
+
+// firecrackerConfig builds the firecracker configuration file for the VM from
+// its config, including a network interface for each created tap.
+func (vm *FirecrackerVM) firecrackerConfig() ([]byte, error) {
+	var config firecrackerConfigJSON
+
+	config.BootSource.KernelImagePath = vm.FirecrackerKernelPath
+	config.BootSource.BootArgs = vm.FirecrackerAppend
+
+	config.Drives = append(config.Drives, struct {
+		DriveID      string `json:"drive_id"`
+		PathOnHost   string `json:"path_on_host"`
+		IsRootDevice bool   `json:"is_root_device"`
+		IsReadOnly   bool   `json:"is_read_only"`
+	}{
+		DriveID:      "rootfs",
+		PathOnHost:   vm.FirecrackerRootfsPath,
+		IsRootDevice: true,
+	})
+
+	config.MachineConfig.VcpuCount = vm.VCPUs
+	config.MachineConfig.MemSizeMib = vm.Memory
+
+	for i, nic := range vm.Networks {
+		config.NetworkInterfaces = append(config.NetworkInterfaces, struct {
+			IfaceID     string `json:"iface_id"`
+			GuestMAC    string `json:"guest_mac"`
+			HostDevName string `json:"host_dev_name"`
+		}{
+			IfaceID:     fmt.Sprintf("eth%v", i),
+			GuestMAC:    nic.MAC,
+			HostDevName: nic.Tap,
+		})
+	}
+
+	return json.MarshalIndent(&config, "", "  ")
+}
+
+// createTaps does the work of adding any taps if we are associated with
+// any networks
+func (vm *FirecrackerVM) createTaps() error {
+	for i := range vm.Networks {
+		nic := &vm.Networks[i]
+		if nic.Tap != "" {
+			// tap has already been created, don't need to do again
+			continue
+		}
+
+		br, err := getBridge(nic.Bridge)
+		if err != nil {
+			return vm.setErrorf("unable to get bridge %v: %v", nic.Bridge, err)
+		}
+
+		tap, err := br.CreateTap("", nic.MAC, nic.VLAN)
+		if err != nil {
+			return vm.setErrorf("unable to create tap %v: %v", i, err)
+		}
+
+		if nic.QinQ {
+			if err := br.SetTapQinQ(tap, nic.VLAN); err != nil {
+				return vm.setErrorf("unable to create tap %v: %v", i, err)
+			}
+		}
+
+		nic.Tap = tap
+	}
+
+	if len(vm.Networks) > 0 {
+		if err := vm.writeTaps(); err != nil {
+			return vm.setErrorf("unable to write taps: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (vm *FirecrackerVM) waitForExit(p *os.Process, wait chan bool) {
+	// Create goroutine to wait for process to exit
+	go func() {
+		defer close(wait)
+		_, err := p.Wait()
+
+		if err != nil && err.Error() == "waitid: no child processes" {
+			for {
+				time.Sleep(1 * time.Second)
+
+				if err = p.Signal(syscall.Signal(0)); err != nil {
+					break
+				}
+			}
+		}
+
+		vm.lock.Lock()
+		defer vm.lock.Unlock()
+
+		// Check if the process quit for some reason other than being killed
+		if err != nil && err.Error() != "signal: killed" {
+			vm.setErrorf("firecracker killed: %v", err)
+		} else if vm.State != VM_ERROR {
+			// Set to QUIT unless we've already been put into the error state
+			vm.setState(VM_QUIT)
+		}
+	}()
+}
+
+func (vm *FirecrackerVM) waitToKill(p *os.Process, wait chan bool) {
+	// Create goroutine to wait to kill the VM
+	go func() {
+		defer vm.cond.Signal()
+
+		select {
+		case <-wait:
+			log.Info("VM %v exited", vm.ID)
+		case <-vm.kill:
+			log.Info("Killing VM %v", vm.ID)
+			p.Kill()
+			<-wait
+		}
+	}()
+}
+
+func (vm *FirecrackerVM) Connect(cc *ron.Server, reconnect bool) error {
+	// firecracker's device model has no virtio-serial so the cc backchannel
+	// is only reachable over the experiment network -- run miniccc with
+	// -parent in the guest instead.
+	return nil
+}
+
+func (vm *FirecrackerVM) Disconnect(cc *ron.Server) error {
+	return nil
+}
+
+func (vm *FirecrackerVM) ProcStats() (map[int]*ProcStats, error) {
+	p, err := GetProcStats(vm.Pid)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[int]*ProcStats{vm.Pid: p}, nil
+}
+
+func (vm *FirecrackerVM) WriteConfig(w io.Writer) error {
+	if err := vm.BaseConfig.WriteConfig(w); err != nil {
+		return err
+	}
+
+	return vm.FirecrackerConfig.WriteConfig(w)
+}
+
+// copyFileContents copies src to dst, preserving the mode of src.
+func copyFileContents(src, dst string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
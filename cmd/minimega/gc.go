@@ -0,0 +1,264 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sandia-minimega/minimega/v2/internal/bridge"
+	"github.com/sandia-minimega/minimega/v2/internal/vlans"
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+var (
+	gcLock   sync.Mutex    // guards below
+	gcPeriod time.Duration // how often the periodic collector runs, zero means disabled
+	gcDone   chan bool     // closed to stop the periodic collector
+)
+
+// gcOrphan describes a resource on this host that is not associated with any
+// VM that minimega knows about.
+type gcOrphan struct {
+	Type string // "tap", "instance", or "vlan"
+	Name string
+	Info string
+
+	// clean removes the orphaned resource
+	clean func() error
+}
+
+// gcFindOrphans returns all the orphaned resources on this host.
+func gcFindOrphans() []gcOrphan {
+	var res []gcOrphan
+
+	res = append(res, gcFindTaps()...)
+	res = append(res, gcFindInstances()...)
+	res = append(res, gcFindVLANs()...)
+
+	return res
+}
+
+// gcFindTaps returns mega_tap devices on this host that the bridge layer has
+// no record of, typically left behind by a previous instance that crashed.
+func gcFindTaps() []gcOrphan {
+	var res []gcOrphan
+
+	dirs, err := ioutil.ReadDir("/sys/class/net")
+	if err != nil {
+		log.Errorln(err)
+		return nil
+	}
+
+	for _, n := range dirs {
+		name := n.Name()
+		if !strings.Contains(name, "mega_tap") {
+			continue
+		}
+
+		if _, err := bridges.FindTap(name); err == nil {
+			// a bridge still knows about this tap
+			continue
+		}
+
+		res = append(res, gcOrphan{
+			Type: "tap",
+			Name: name,
+			Info: "not on any bridge",
+			clean: func() error {
+				return bridge.DestroyTap(name)
+			},
+		})
+	}
+
+	return res
+}
+
+// gcFindInstances returns instance directories under the base path whose IDs
+// do not belong to any VM in any namespace. If the directory contains a pid
+// file for a still-running process, cleaning kills the process too.
+func gcFindInstances() []gcOrphan {
+	// collect the IDs of all VMs in all namespaces, IDs are unique per host
+	ids := map[int]bool{}
+
+	for _, name := range ListNamespaces() {
+		ns := GetOrCreateNamespace(name)
+
+		ns.VMs.Apply(Wildcard, func(vm VM, _ bool) (bool, error) {
+			ids[vm.GetID()] = true
+			return true, nil
+		})
+	}
+
+	dirs, err := ioutil.ReadDir(*f_base)
+	if err != nil {
+		log.Errorln(err)
+		return nil
+	}
+
+	var res []gcOrphan
+
+	for _, n := range dirs {
+		if !n.IsDir() {
+			continue
+		}
+
+		id, err := strconv.Atoi(n.Name())
+		if err != nil {
+			// not an instance directory
+			continue
+		}
+
+		if ids[id] {
+			continue
+		}
+
+		path := filepath.Join(*f_base, n.Name())
+		info := "no running process"
+
+		pid := gcReadPid(filepath.Join(path, "qemu.pid"))
+		if pid != 0 && syscall.Kill(pid, 0) == nil {
+			info = fmt.Sprintf("qemu pid %v still running", pid)
+		} else {
+			pid = 0
+		}
+
+		res = append(res, gcOrphan{
+			Type: "instance",
+			Name: path,
+			Info: info,
+			clean: func() error {
+				if pid != 0 {
+					out, err := processWrapper("kill", strconv.Itoa(pid))
+					if err != nil && !strings.Contains(err.Error(), "No such process") {
+						return fmt.Errorf("%v: %v", err, out)
+					}
+				}
+
+				return os.RemoveAll(path)
+			},
+		})
+	}
+
+	return res
+}
+
+// gcFindVLANs returns VLAN aliases that are not referenced by any VM, queued
+// VM, or allowlist entry in their namespace.
+func gcFindVLANs() []gcOrphan {
+	var res []gcOrphan
+
+	for _, name := range ListNamespaces() {
+		ns := GetOrCreateNamespace(name)
+
+		// collect all the aliases that are still in use
+		referenced := map[string]bool{}
+
+		ns.VMs.Apply(Wildcard, func(vm VM, _ bool) (bool, error) {
+			for _, nic := range vm.GetNetworks() {
+				referenced[nic.Alias] = true
+			}
+			return true, nil
+		})
+
+		for _, q := range ns.queue {
+			for _, nic := range q.Networks {
+				referenced[nic.Alias] = true
+			}
+		}
+
+		for alias := range ns.vlanAllowlist {
+			referenced[alias] = true
+		}
+
+		for _, row := range vlans.Tabular(ns.Name) {
+			alias, vlan := row[0], row[1]
+			if referenced[alias] {
+				continue
+			}
+
+			namespace := ns.Name
+
+			res = append(res, gcOrphan{
+				Type: "vlan",
+				Name: fmt.Sprintf("%v//%v", namespace, alias),
+				Info: fmt.Sprintf("vlan %v unreferenced", vlan),
+				clean: func() error {
+					vlans.Delete(namespace, alias)
+
+					// update file so that we have a copy of the vlans if
+					// minimega crashes
+					mustWrite(filepath.Join(*f_base, "vlans"), vlanInfo())
+					return nil
+				},
+			})
+		}
+	}
+
+	return res
+}
+
+// gcReadPid reads a pid from the given file, returning zero if the file does
+// not exist or does not contain a pid.
+func gcReadPid(path string) int {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(d)))
+	if err != nil {
+		log.Error("invalid pid in %v: %v", path, err)
+		return 0
+	}
+
+	return pid
+}
+
+// gcSetPeriod updates the periodic collector, stopping any previous one. A
+// zero duration disables periodic collection.
+func gcSetPeriod(d time.Duration) {
+	gcLock.Lock()
+	defer gcLock.Unlock()
+
+	if gcDone != nil {
+		close(gcDone)
+		gcDone = nil
+	}
+
+	gcPeriod = d
+	if d == 0 {
+		return
+	}
+
+	gcDone = make(chan bool)
+	go gcRun(d, gcDone)
+}
+
+// gcRun periodically cleans up orphaned resources on this host until done is
+// closed.
+func gcRun(d time.Duration, done chan bool) {
+	for {
+		select {
+		case <-time.After(d):
+			for _, o := range gcFindOrphans() {
+				log.Info("gc: removing orphaned %v %v (%v)", o.Type, o.Name, o.Info)
+
+				if err := o.clean(); err != nil {
+					log.Error("gc: %v %v -- %v", o.Type, o.Name, err)
+				}
+			}
+		case <-done:
+			return
+		}
+	}
+}
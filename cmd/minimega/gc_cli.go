@@ -0,0 +1,93 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"time"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var gcCLIHandlers = []minicli.Handler{
+	{ // gc
+		HelpShort: "find and clean up orphaned resources",
+		HelpLong: `
+Find taps, VLAN aliases, and instance directories that no longer correspond to
+a VM that minimega knows about, typically left behind by a crashed instance or
+a VM that was killed outside of minimega. This command is namespace aware and
+runs on every host in the namespace.
+
+With no arguments, gc reports the orphans it finds without touching them:
+
+	gc
+
+"gc run" removes them, killing any processes still running out of an orphaned
+instance directory:
+
+	gc run
+
+"gc period" views or sets how often orphans are cleaned up automatically on
+each host. A period of 0, the default, disables automatic collection:
+
+	gc period 5m
+
+Unlike nuke, gc only touches resources that minimega no longer has a record
+of, so it is safe to run while VMs are active.`,
+		Patterns: []string{
+			"gc",
+			"gc <run,>",
+			"gc <period,> [duration]",
+		},
+		Call: wrapBroadcastCLI(cliGC),
+	},
+}
+
+func cliGC(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["period"] {
+		if c.StringArgs["duration"] == "" {
+			gcLock.Lock()
+			defer gcLock.Unlock()
+
+			if gcPeriod == 0 {
+				resp.Response = "disabled"
+			} else {
+				resp.Response = gcPeriod.String()
+			}
+			return nil
+		}
+
+		d, err := time.ParseDuration(c.StringArgs["duration"])
+		if err != nil {
+			return err
+		}
+
+		gcSetPeriod(d)
+		return nil
+	}
+
+	run := c.BoolArgs["run"]
+
+	resp.Header = []string{"type", "name", "info", "status"}
+
+	for _, o := range gcFindOrphans() {
+		status := "found"
+
+		if run {
+			status = "removed"
+			if err := o.clean(); err != nil {
+				status = err.Error()
+			}
+		}
+
+		resp.Tabular = append(resp.Tabular, []string{
+			o.Type,
+			o.Name,
+			o.Info,
+			status,
+		})
+	}
+
+	return nil
+}
@@ -5,9 +5,34 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// historyEntry records one accepted command with its provenance: when it ran,
+// where it came from, and which namespace was active at the time.
+type historyEntry struct {
+	Time      time.Time
+	Source    string
+	Namespace string
+	Command   string
+}
+
+// historyMaxLen caps the in-memory structured history so that long-running
+// instances don't grow without bound. The -history file, if set, is
+// append-only and keeps everything.
+const historyMaxLen = 10000
+
+var (
+	historyLock    sync.Mutex
+	historyEntries []historyEntry
+	historyFile    *os.File
 )
 
 var historyCLIHandlers = []minicli.Handler{
@@ -20,11 +45,27 @@ History includes only valid commands and comments. Invalid lines and blank
 lines are not recorded. There are some commands that interact differently with
 history, namely read. Instead of recording the "read" command in the history,
 minimega records all the valid commands executed from the read file in the
-history. This allows the full execution history to be listed using history.`,
+history. This allows the full execution history to be listed using history.
+
+minimega also keeps a structured history that records when each command ran,
+where it entered minimega (local CLI, the command socket used by attach and
+miniweb, a read file, or the mesh), and which namespace was active, useful
+for reconstructing what was done during an exercise. Passing any filter
+queries the structured history instead:
+
+	history -n 100
+	history -namespace foo
+	history -n 50 -source socket
+
+Filters may be combined; -n limits the output to the most recent N entries.
+The structured history is capped in memory; start minimega with -history
+<file> to also append every entry to a file that survives restarts and
+"clear history".`,
 		Patterns: []string{
-			"history",
+			"history [filter]...",
 		},
-		Call: wrapSimpleCLI(cliHistory),
+		Call:     wrapSimpleCLI(cliHistory),
+		ReadOnly: true,
 	},
 	{ // clear history
 		HelpShort: "reset history",
@@ -47,13 +88,125 @@ the minimega command line and then saving them for later use.`,
 	},
 }
 
+// initHistoryFile opens the optional append-only history file, see -history
+func initHistoryFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	historyFile = f
+	return nil
+}
+
+// recordHistory adds a command to the structured history. source is where the
+// command entered minimega: "local", "socket", "read", or "mesh". Commands
+// with recording disabled (e.g. internal fan-out copies) are skipped.
+func recordHistory(source string, c *minicli.Command) {
+	if c == nil || !c.Record {
+		return
+	}
+
+	e := historyEntry{
+		Time:      time.Now(),
+		Source:    source,
+		Namespace: GetNamespace().Name,
+		Command:   c.Original,
+	}
+
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	historyEntries = append(historyEntries, e)
+	if len(historyEntries) > historyMaxLen {
+		historyEntries = historyEntries[len(historyEntries)-historyMaxLen:]
+	}
+
+	if historyFile != nil {
+		line := fmt.Sprintf("%v\t%v\t%v\t%v\n", e.Time.Format(time.RFC3339), e.Source, e.Namespace, e.Command)
+		if _, err := historyFile.WriteString(line); err != nil {
+			log.Error("unable to write history file: %v", err)
+		}
+	}
+}
+
+// queryHistory returns the most recent entries matching the given source and
+// namespace (empty matches everything), at most limit when limit is non-zero.
+func queryHistory(limit int, source, namespace string) []historyEntry {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	res := []historyEntry{}
+	for _, e := range historyEntries {
+		if source != "" && e.Source != source {
+			continue
+		}
+		if namespace != "" && e.Namespace != namespace {
+			continue
+		}
+
+		res = append(res, e)
+	}
+
+	if limit != 0 && len(res) > limit {
+		res = res[len(res)-limit:]
+	}
+
+	return res
+}
+
 func cliHistory(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
-	resp.Response = minicli.History()
+	args := c.ListArgs["filter"]
+
+	if len(args) == 0 {
+		resp.Response = minicli.History()
+		return nil
+	}
+
+	var limit int
+	var source, namespace string
+
+	for i := 0; i < len(args); i++ {
+		flag := args[i]
+
+		i++
+		if i >= len(args) {
+			return fmt.Errorf("%v requires a value", flag)
+		}
+
+		switch flag {
+		case "-n":
+			v, err := strconv.Atoi(args[i])
+			if err != nil || v < 0 {
+				return fmt.Errorf("invalid count: `%v`", args[i])
+			}
+			limit = v
+		case "-namespace":
+			namespace = args[i]
+		case "-source":
+			source = args[i]
+		default:
+			return fmt.Errorf("invalid filter: `%v`", flag)
+		}
+	}
+
+	resp.Header = []string{"time", "source", "namespace", "command"}
+	for _, e := range queryHistory(limit, source, namespace) {
+		resp.Tabular = append(resp.Tabular, []string{
+			e.Time.Format(time.RFC3339), e.Source, e.Namespace, e.Command,
+		})
+	}
+
 	return nil
 }
 
 func cliHistoryClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	minicli.ClearHistory()
+
+	historyLock.Lock()
+	historyEntries = nil
+	historyLock.Unlock()
+
 	c.Record = false
 	return nil
 }
@@ -0,0 +1,155 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// VM lifecycle events that hooks can attach to, see `hook`
+const (
+	HookPreLaunch = "pre-launch"
+	HookBootReady = "boot-ready"
+	HookVMLost    = "vm-lost"
+	HookPreKill   = "pre-kill"
+)
+
+// hookEvents lists the events in the order they occur in a VM's life
+var hookEvents = []string{HookPreLaunch, HookBootReady, HookVMLost, HookPreKill}
+
+// hookTimeout bounds how long a single hook may run so that a wedged script
+// or unreachable webhook cannot hang VM launches or kills
+const hookTimeout = 30 * time.Second
+
+// hookClient is used for webhook targets
+var hookClient = &http.Client{Timeout: hookTimeout}
+
+// hookData is the VM metadata passed to hooks: webhooks receive it as a JSON
+// POST body, scripts receive the same fields as MINIMEGA_HOOK_* environment
+// variables. State is empty for pre-launch hooks since the VM does not exist
+// yet.
+type hookData struct {
+	Event     string            `json:"event"`
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Type      string            `json:"type"`
+	State     string            `json:"state,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// vmHookData collects hook metadata from a VM. Must not be called with the
+// VM's lock held since the accessors lock it themselves.
+func vmHookData(event string, vm VM) *hookData {
+	return &hookData{
+		Event:     event,
+		Name:      vm.GetName(),
+		Namespace: vm.GetNamespace(),
+		Type:      vm.GetType().String(),
+		State:     vm.GetState().String(),
+		Tags:      vm.GetTags(),
+	}
+}
+
+// env converts the hook metadata into environment variables for script hooks
+func (h *hookData) env() []string {
+	res := append(os.Environ(),
+		"MINIMEGA_HOOK_EVENT="+h.Event,
+		"MINIMEGA_HOOK_VM_NAME="+h.Name,
+		"MINIMEGA_HOOK_NAMESPACE="+h.Namespace,
+		"MINIMEGA_HOOK_VM_TYPE="+h.Type,
+		"MINIMEGA_HOOK_VM_STATE="+h.State,
+	)
+
+	if tags, err := json.Marshal(h.Tags); err == nil {
+		res = append(res, "MINIMEGA_HOOK_VM_TAGS="+string(tags))
+	}
+
+	return res
+}
+
+// AddHook attaches target, a script path or http(s) URL, to the given event
+func (n *Namespace) AddHook(event, target string) {
+	n.Hooks[event] = append(n.Hooks[event], target)
+}
+
+// ClearHooks removes the hooks for the given event, or all hooks for Wildcard
+func (n *Namespace) ClearHooks(event string) {
+	if event == Wildcard {
+		n.Hooks = make(map[string][]string)
+		return
+	}
+
+	delete(n.Hooks, event)
+}
+
+// RunHooks fires all hooks attached to the given event in the order they were
+// added, stopping at the first error. Callers decide whether an error vetoes
+// the transition (pre-launch) or is simply logged (boot-ready, pre-kill).
+func (n *Namespace) RunHooks(event string, data *hookData) error {
+	for _, target := range n.Hooks[event] {
+		if err := runHook(target, data); err != nil {
+			return fmt.Errorf("%v hook %v: %v", event, target, err)
+		}
+	}
+
+	return nil
+}
+
+// runHook invokes a single hook target with the given metadata
+func runHook(target string, data *hookData) error {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return runWebhook(target, data)
+	}
+
+	return runHookScript(target, data)
+}
+
+// runWebhook POSTs the metadata to the target as JSON, treating any non-2xx
+// status as an error
+func runWebhook(target string, data *hookData) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hookClient.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %v", resp.Status)
+	}
+
+	return nil
+}
+
+// runHookScript executes the target with the metadata in its environment, a
+// non-zero exit status is an error
+func runHookScript(target string, data *hookData) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, target)
+	cmd.Env = data.env()
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("%v: %v", err, strings.TrimSpace(string(out)))
+		}
+		return err
+	}
+
+	return nil
+}
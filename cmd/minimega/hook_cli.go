@@ -0,0 +1,91 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var hookCLIHandlers = []minicli.Handler{
+	{ // hook
+		HelpShort: "run scripts or webhooks on VM lifecycle events",
+		HelpLong: `
+Attach hooks to VM lifecycle events in the current namespace, so that sites
+can integrate CMDB updates, license checkouts, or custom logging without
+patching minimega. The supported events are:
+
+- pre-launch : before a VM is created, on the host that will run it
+- boot-ready : when the cc agent first checks in, i.e. the guest has booted
+  far enough to run programs
+- vm-lost    : when the host a VM was scheduled on drops out of the mesh, see
+  "ns reconcile"
+- pre-kill   : before a VM is killed, including via "clear namespace"
+
+A hook target is either a path to an executable or an http(s) URL. Webhooks
+receive a JSON POST body with the event name and VM metadata (name,
+namespace, type, state, and tags); any non-2xx response is an error. Scripts
+receive the same fields in MINIMEGA_HOOK_* environment variables and fail on
+a non-zero exit status. Hooks are limited to 30 seconds per invocation.
+
+pre-launch hooks gate the launch: if one fails, that VM is not launched and
+the error is reported, so a hook can enforce site policy such as license
+availability. Failures of the other hooks are only logged.
+
+For example, to register VMs with an inventory service and log kills:
+
+	hook pre-launch http://cmdb.example.com/minimega
+	hook pre-kill /usr/local/bin/log-kill.sh
+
+When run without arguments, hook lists the attached hooks. Hooks for an event
+run in the order they were added.`,
+		Patterns: []string{
+			"hook",
+			"hook <pre-launch,boot-ready,vm-lost,pre-kill> <path or URL>",
+		},
+		Call: wrapSimpleCLI(cliHook),
+	},
+	{ // clear hook
+		HelpShort: "remove lifecycle hooks",
+		HelpLong: `
+Removes the hooks for one event, or all hooks. See "help hook" for more
+information.`,
+		Patterns: []string{
+			"clear hook [pre-launch,boot-ready,vm-lost,pre-kill]",
+		},
+		Call: wrapSimpleCLI(cliHookClear),
+	},
+}
+
+func cliHook(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	for _, event := range hookEvents {
+		if c.BoolArgs[event] {
+			ns.AddHook(event, c.StringArgs["path"])
+			return nil
+		}
+	}
+
+	// no event specified -- list the attached hooks
+	resp.Header = []string{"event", "target"}
+
+	for _, event := range hookEvents {
+		for _, target := range ns.Hooks[event] {
+			resp.Tabular = append(resp.Tabular, []string{event, target})
+		}
+	}
+
+	return nil
+}
+
+func cliHookClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	for _, event := range hookEvents {
+		if c.BoolArgs[event] {
+			ns.ClearHooks(event)
+			return nil
+		}
+	}
+
+	ns.ClearHooks(Wildcard)
+	return nil
+}
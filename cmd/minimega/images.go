@@ -0,0 +1,138 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// imageExts are the file extensions that the image library considers disk
+// images when scanning the files directory.
+var imageExts = map[string]bool{
+	".qcow2": true,
+	".qc2":   true,
+	".img":   true,
+	".raw":   true,
+	".iso":   true,
+}
+
+// imageEntry describes one image in the local files directory.
+type imageEntry struct {
+	Name    string // path relative to the files directory
+	Format  string
+	Size    int64
+	Backing string
+	Hash    string
+}
+
+// imageHashes caches image hashes keyed on path. Entries are invalidated when
+// the size or modification time changes so that a rebuilt image is re-hashed.
+var imageHashes = struct {
+	sync.Mutex
+	m map[string]imageHash
+}{m: map[string]imageHash{}}
+
+type imageHash struct {
+	size  int64
+	mtime time.Time
+	hash  string
+}
+
+// hashImage returns the SHA-256 of the image, reusing the cached value when
+// the image has not changed since it was last hashed.
+func hashImage(path string, fi os.FileInfo) (string, error) {
+	imageHashes.Lock()
+	if v, ok := imageHashes.m[path]; ok && v.size == fi.Size() && v.mtime.Equal(fi.ModTime()) {
+		imageHashes.Unlock()
+		return v.hash, nil
+	}
+	imageHashes.Unlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	hash := fmt.Sprintf("%x", h.Sum(nil))
+
+	imageHashes.Lock()
+	imageHashes.m[path] = imageHash{
+		size:  fi.Size(),
+		mtime: fi.ModTime(),
+		hash:  hash,
+	}
+	imageHashes.Unlock()
+
+	return hash, nil
+}
+
+// listImages scans the local files directory for disk images and returns an
+// entry for each, sorted by name.
+func listImages() ([]imageEntry, error) {
+	res := []imageEntry{}
+
+	err := filepath.Walk(*f_iomBase, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fi.IsDir() || !imageExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		rel, err := filepath.Rel(*f_iomBase, path)
+		if err != nil {
+			return err
+		}
+
+		entry := imageEntry{
+			Name: rel,
+			Size: fi.Size(),
+		}
+
+		// qemu-img understands more than just qcow2 so query every image,
+		// falling back to an empty format when it cannot be read
+		if info, err := diskInfo(path); err != nil {
+			log.Debug("unable to query image %v: %v", path, err)
+		} else {
+			entry.Format = info.Format
+			entry.Backing = info.BackingFile
+		}
+
+		if hash, err := hashImage(path, fi); err != nil {
+			log.Debug("unable to hash image %v: %v", path, err)
+		} else {
+			entry.Hash = hash
+		}
+
+		res = append(res, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
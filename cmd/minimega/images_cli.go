@@ -0,0 +1,71 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var imagesCLIHandlers = []minicli.Handler{
+	{ // images
+		HelpShort: "catalog and replicate base images across the mesh",
+		HelpLong: `
+Catalog the base images available in the files directory (see -filepath) on
+every host in the namespace. Each host reports the images it has along with
+their format, size, backing file, and SHA-256 hash, so mismatched or missing
+copies of an image are easy to spot:
+
+	images
+
+Hashes are cached and only recomputed when an image changes, so the first
+listing after adding a large image is slow and subsequent listings are cheap.
+
+To replicate an image to every host in the namespace ahead of a launch,
+instead of paying the transfer cost at "vm launch":
+
+	images fetch ubuntu.qcow2
+
+fetch uses the same file transfer layer as "file get", runs on all hosts in
+parallel, and also pulls any backing images. Image names are relative to the
+files directory.
+
+Like all tabular commands, the catalog is available to scripts and miniweb
+via the command socket.`,
+		Patterns: []string{
+			"images",
+			"images <fetch,> <image>",
+		},
+		Call: wrapBroadcastCLI(cliImages),
+	},
+}
+
+func cliImages(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["fetch"] {
+		_, err := iomHelper(c.StringArgs["image"], "")
+		return err
+	}
+
+	images, err := listImages()
+	if err != nil {
+		return err
+	}
+
+	resp.Header = []string{"name", "format", "size", "backing", "hash"}
+	resp.Tabular = [][]string{}
+
+	for _, image := range images {
+		resp.Tabular = append(resp.Tabular, []string{
+			image.Name,
+			image.Format,
+			strconv.FormatInt(image.Size, 10),
+			image.Backing,
+			image.Hash,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readInventory parses an inventory file into one map per host, keyed by the
+// lower-cased column name. Files ending in .json must contain a JSON array of
+// objects, anything else is parsed as a CSV with a header row.
+func readInventory(fname string) ([]map[string]string, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(fname)) == ".json" {
+		var raw []map[string]interface{}
+
+		if err := json.NewDecoder(f).Decode(&raw); err != nil {
+			return nil, fmt.Errorf("invalid inventory: %v", err)
+		}
+
+		res := []map[string]string{}
+		for _, v := range raw {
+			row := map[string]string{}
+			for k, v2 := range v {
+				row[strings.ToLower(strings.TrimSpace(k))] = fmt.Sprintf("%v", v2)
+			}
+			res = append(res, row)
+		}
+
+		return res, nil
+	}
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid inventory: %v", err)
+	}
+
+	if len(records) < 2 {
+		return nil, fmt.Errorf("inventory must have a header row and at least one host")
+	}
+
+	header := records[0]
+	for i, k := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(k))
+	}
+
+	res := []map[string]string{}
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, v := range record {
+			row[header[i]] = strings.TrimSpace(v)
+		}
+		res = append(res, row)
+	}
+
+	return res, nil
+}
+
+// inventoryConfig builds a VM name, type, and config from an inventory row.
+// The config starts from the namespace's current vm config, so any columns
+// the inventory doesn't specify are inherited from it. Recognized columns are
+// name (or hostname), type, net (or network or vlan), disk, memory, and
+// vcpus. Any other column becomes a tag on the VM.
+func (n *Namespace) inventoryConfig(row map[string]string) (string, VMType, VMConfig, error) {
+	vmConfig := n.vmConfig.Copy()
+	vmType := KVM
+
+	var name string
+
+	for k, v := range row {
+		if v == "" {
+			continue
+		}
+
+		var err error
+
+		switch k {
+		case "name", "hostname":
+			name = v
+		case "type":
+			vmType, err = ParseVMType(v)
+		case "net", "network", "vlan":
+			var nics []NetConfig
+			nics, err = n.parseVMNets(strings.Fields(v))
+			if err == nil {
+				vmConfig.Networks = NetConfigs{}
+				for _, nic := range nics {
+					vmConfig.Networks = append(vmConfig.Networks, nic)
+				}
+			}
+		case "disk":
+			vmConfig.Disks = DiskConfigs{}
+			for _, spec := range strings.Fields(v) {
+				var disk *DiskConfig
+				disk, err = ParseDiskConfig(spec, vmConfig.Snapshot)
+				if err != nil {
+					break
+				}
+				disk.Raw = spec
+				vmConfig.Disks = append(vmConfig.Disks, *disk)
+			}
+		case "memory":
+			vmConfig.Memory, err = strconv.ParseUint(v, 10, 64)
+		case "vcpus":
+			vmConfig.VCPUs, err = strconv.ParseUint(v, 10, 64)
+		default:
+			// unrecognized columns such as os, ip, and role become tags so
+			// that they can be used with `vm tag` and scheduling
+			vmConfig.Tags[k] = v
+		}
+
+		if err != nil {
+			return "", 0, VMConfig{}, fmt.Errorf("%v: %v", k, err)
+		}
+	}
+
+	if name == "" {
+		return "", 0, VMConfig{}, fmt.Errorf("missing name or hostname column")
+	}
+
+	return name, vmType, vmConfig, nil
+}
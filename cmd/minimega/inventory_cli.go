@@ -0,0 +1,105 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var inventoryCLIHandlers = []minicli.Handler{
+	{ // inventory
+		HelpShort: "queue VMs from an inventory file",
+		HelpLong: `
+Queue a VM for each host in an inventory file, bridging the common "we have a
+spreadsheet of the target network" workflow into minimega. Files ending in
+.json must contain a JSON array of objects, anything else is parsed as a CSV
+with a header row. For example:
+
+	name,os,ip,role,net
+	gateway,linux,10.0.0.1,router,DMZ
+	fileserver,windows,10.0.0.2,server,LAN
+
+Each VM starts from the current vm config, so set things like the default disk
+and memory with "vm config" first. The following columns override the config
+for a single host:
+
+- name (or hostname) : the VM name, required
+- type               : kvm, container, or firecracker (default kvm)
+- net (or network or vlan) : one or more space-separated netspecs, in the same
+  format as "vm config net"
+- disk               : one or more space-separated diskspecs
+- memory             : memory in megabytes
+- vcpus              : number of virtual CPUs
+
+Any other column such as os, ip, or role becomes a tag on the VM so that it
+can be used with "vm tag" and ".filter".
+
+"inventory check" parses the file and reports the VMs it would create without
+queueing them:
+
+	inventory check hosts.csv
+
+"inventory load" queues the VMs, launching them immediately unless "ns
+queueing" is enabled:
+
+	inventory load hosts.csv`,
+		Patterns: []string{
+			"inventory <check,> <file>",
+			"inventory <load,> <file>",
+		},
+		Call: wrapSimpleCLI(cliInventory),
+	},
+}
+
+func cliInventory(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	// HAX: prevent running as a subcommand, see cliVMLaunch
+	if c.Source == SourceMeshage {
+		return fmt.Errorf("cannot run `%s` via meshage", c.Original)
+	}
+
+	rows, err := readInventory(c.StringArgs["file"])
+	if err != nil {
+		return err
+	}
+
+	resp.Header = []string{"name", "type", "networks", "tags"}
+
+	for i, row := range rows {
+		name, vmType, vmConfig, err := ns.inventoryConfig(row)
+		if err != nil {
+			return fmt.Errorf("host %v: %v", i+1, err)
+		}
+
+		if c.BoolArgs["load"] {
+			if err := ns.Queue(name, vmType, vmConfig); err != nil {
+				return fmt.Errorf("host %v: %v", i+1, err)
+			}
+		}
+
+		tags := []string{}
+		for k, v := range vmConfig.Tags {
+			tags = append(tags, fmt.Sprintf("%v=%v", k, v))
+		}
+		sort.Strings(tags)
+
+		resp.Tabular = append(resp.Tabular, []string{
+			name,
+			vmType.String(),
+			vmConfig.NetworkString(ns.Name),
+			strings.Join(tags, ","),
+		})
+	}
+
+	if c.BoolArgs["load"] && !ns.QueueVMs {
+		// no error queueing and user has disabled queueing -- launch now!
+		return ns.Schedule(false)
+	}
+
+	return nil
+}
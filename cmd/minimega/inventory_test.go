@@ -0,0 +1,74 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeInventory(t *testing.T, name, data string) string {
+	t.Helper()
+
+	fname := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(fname, []byte(data), os.FileMode(0644)); err != nil {
+		t.Fatal(err)
+	}
+
+	return fname
+}
+
+func TestReadInventoryCSV(t *testing.T) {
+	fname := writeInventory(t, "hosts.csv", `Name,OS,IP,Role
+gateway,linux,10.0.0.1,router
+fileserver,windows,10.0.0.2,server
+`)
+
+	rows, err := readInventory(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %v", len(rows))
+	}
+
+	// header should be lower-cased
+	if rows[0]["name"] != "gateway" || rows[0]["role"] != "router" {
+		t.Errorf("unexpected row: %v", rows[0])
+	}
+	if rows[1]["ip"] != "10.0.0.2" {
+		t.Errorf("unexpected row: %v", rows[1])
+	}
+}
+
+func TestReadInventoryJSON(t *testing.T) {
+	fname := writeInventory(t, "hosts.json", `[
+	{"Name": "gateway", "OS": "linux", "vcpus": 2}
+]`)
+
+	rows, err := readInventory(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %v", len(rows))
+	}
+
+	if rows[0]["name"] != "gateway" || rows[0]["vcpus"] != "2" {
+		t.Errorf("unexpected row: %v", rows[0])
+	}
+}
+
+func TestReadInventoryInvalid(t *testing.T) {
+	fname := writeInventory(t, "hosts.csv", "name,os\n")
+
+	if _, err := readInventory(fname); err == nil {
+		t.Error("expected error for inventory with no hosts")
+	}
+}
@@ -81,6 +81,12 @@ form the original file. This command blocks until the stream is complete.`,
 			"file <status,>",
 		},
 		Call: cliFile,
+		Suggest: wrapSuggest(func(ns *Namespace, val, prefix string) []string {
+			if val == "file" || val == "path" {
+				return cliFileSuggest(prefix)
+			}
+			return nil
+		}),
 	},
 }
 
@@ -327,6 +333,37 @@ func iomCompleter(last string) []string {
 	return finalMatches
 }
 
+// cliFileSuggest takes a prefix that could be the start of a path in the
+// files directory and makes suggestions for files and directories that have a
+// common prefix. Suggestions are relative to the files directory since that
+// is how the file API addresses them.
+func cliFileSuggest(prefix string) []string {
+	path := filepath.Join(*f_iomBase, prefix)
+	if prefix == "" || strings.HasSuffix(prefix, string(filepath.Separator)) {
+		path += string(filepath.Separator)
+	}
+
+	var res []string
+
+	for _, v := range fileCompleter(path) {
+		rel, err := filepath.Rel(*f_iomBase, v)
+		if err != nil {
+			continue
+		}
+
+		if strings.HasPrefix(prefix, string(filepath.Separator)) {
+			rel = string(filepath.Separator) + rel
+		}
+		if strings.HasSuffix(v, string(filepath.Separator)) {
+			rel += string(filepath.Separator)
+		}
+
+		res = append(res, rel)
+	}
+
+	return res
+}
+
 // a simple longest common prefix function
 func lcp(s []string) string {
 	var lcp string
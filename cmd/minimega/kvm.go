@@ -17,8 +17,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"text/tabwriter"
 	"time"
@@ -43,6 +47,12 @@ const (
 
 	QMP_CONNECT_RETRY = 50
 	QMP_CONNECT_DELAY = 100
+
+	// QMP_HEALTH_INTERVAL is the number of seconds between QMP keepalives.
+	QMP_HEALTH_INTERVAL = 5
+	// QMP_RECONNECT_MAX_DELAY is the backoff cap, in seconds, between
+	// reconnect attempts when the QMP connection has dropped.
+	QMP_RECONNECT_MAX_DELAY = 30
 )
 
 type KVMConfig struct {
@@ -93,6 +103,18 @@ type KVMConfig struct {
 	// Default: "host"
 	CPU string `validate:"validCPU" suggest:"wrapSuggest(suggestCPU)"`
 
+	// Add or remove CPUID feature flags on the virtual CPU, as a
+	// comma-separated list of flags prefixed with '+' to enable or '-' to
+	// disable. The flags are appended to the -cpu argument. For example, to
+	// enable AES-NI and hide the VMware IO port:
+	//
+	// 	vm config cpu-flags +aes,-vmport
+	//
+	// See 'qemu -cpu help' for the full list of flags.
+	//
+	// Note: this configuration only applies to KVM-based VMs.
+	CpuFlags string `validate:"validCpuFlags"`
+
 	// Set the number of CPU sockets. If unspecified, QEMU will calculate
 	// missing values based on vCPUs, cores, and threads.
 	Sockets uint64
@@ -114,6 +136,29 @@ type KVMConfig struct {
 	// Note: this configuration only applies to KVM-based VMs.
 	Machine string `validate:"validMachine" suggest:"wrapSuggest(suggestMachine)"`
 
+	// Set the guest architecture. Supported values are "x86_64" and
+	// "aarch64". When unset, guests match the host architecture.
+	//
+	// Setting "aarch64" on an x86 host switches the QEMU binary to
+	// qemu-system-aarch64 (unless 'vm config qemu' was changed from its
+	// default), selects the "virt" machine type unless 'vm config machine'
+	// is set, and provides a USB bus via xHCI since "virt" has none built
+	// in. Cross-architecture guests run under TCG emulation rather than KVM
+	// acceleration and default to the "max" CPU model instead of "host".
+	//
+	// aarch64 guests have no default BIOS -- point 'vm config firmware' at
+	// an EDK2 image.
+	//
+	// Note: this configuration only applies to KVM-based VMs.
+	Arch string `validate:"validArch"`
+
+	// Attach a firmware image (e.g. EDK2/OVMF) to boot the guest with,
+	// passed to QEMU via -bios. Required for aarch64 guests, which have no
+	// default BIOS; typically QEMU_EFI.fd from the edk2-aarch64 package.
+	//
+	// Note: this configuration only applies to KVM-based VMs.
+	FirmwarePath string
+
 	// Specify the serial ports that will be created for the VM to use. Serial
 	// ports specified will be mapped to the VM's /dev/ttySX device, where X
 	// refers to the connected unix socket on the host at
@@ -176,6 +221,9 @@ type KVMConfig struct {
 	// used for multiple VMs since minimega snapshots the disk image when the
 	// VM launches, creating a back qcow2 in the VM's instance directory.
 	//
+	// A diskspec may include a luks=<key file> option for qcow2 images with
+	// inline LUKS encryption, see "vm config disks" for details.
+	//
 	// Note: this configuration only applies to KVM-based VMs.
 	Disks DiskConfigs
 
@@ -195,6 +243,36 @@ type KVMConfig struct {
 	// Default: false
 	BidirectionalCopyPaste bool
 
+	// Enable nested virtualization by exposing the hardware virtualization
+	// extensions (vmx on Intel, svm on AMD) to the virtual CPU. Needed for
+	// experiments that run hypervisors inside VMs. Requires nested support
+	// to be enabled in the host's kvm_intel or kvm_amd module -- launching
+	// will fail if it is not.
+	//
+	// Note: this configuration only applies to KVM-based VMs.
+	//
+	// Default: false
+	Nested bool
+
+	// Set the base date-time for the guest's real-time clock instead of
+	// syncing it to the host clock at launch. Accepts either a date-time in
+	// the format "2006-01-02T15:04:05", which the guest clock starts at, or a
+	// signed offset from the host clock such as "-240h" or "30m", which is
+	// resolved when the VM launches. The guest clock then advances normally
+	// from that base, so date-dependent behaviors such as certificate expiry
+	// can be tested without changing the host clock.
+	//
+	// Examples:
+	//
+	// To start the guest clock ten days in the past:
+	// 	vm config rtc-base -240h
+	//
+	// To start the guest clock at a specific instant:
+	// 	vm config rtc-base 2030-01-01T00:00:00
+	//
+	// Note: this configuration only applies to KVM-based VMs.
+	RtcBase string `validate:"validRtcBase"`
+
 	// Add additional arguments to be passed to the QEMU instance. For example:
 	//
 	// 	vm config qemu-append -serial tcp:localhost:4001
@@ -229,10 +307,56 @@ type KvmVM struct {
 	// Internal variables
 	hotplug map[int]vmHotplug
 
+	// usb maps device IDs to host USB devices attached via "vm usb". The
+	// value is the device spec as given by the user, either vendor:product
+	// or bus.port.
+	usb map[int]string
+
 	q qmp.Conn // qmp connection for this vm
 
+	// qmpStatus is the health of the qmp connection, one of "connected",
+	// "reconnecting", or "disconnected". Updated by qmpHealth.
+	qmpStatus string
+
+	// qemu stderr and recent QMP messages, retained for crash triage
+	qemuStderr *bytes.Buffer
+	qmpLog     *qmpLogRing
+
 	vncShim net.Listener // shim for VNC connections
 	VNCPort int
+
+	// bytes relayed to and from VNC clients across all shim connections,
+	// accessed atomically since the shim runs outside of the VM's lock
+	vncTxBytes uint64
+	vncRxBytes uint64
+}
+
+// qmpLogRing retains the most recent asynchronous QMP messages for a VM so
+// that they can be included in a crash report. It has its own lock since the
+// logger runs outside of the VM's lock.
+type qmpLogRing struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+// qmpLogSize is the number of asynchronous QMP messages retained per VM.
+const qmpLogSize = 64
+
+func (r *qmpLogRing) add(v string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.msgs = append(r.msgs, v)
+	if len(r.msgs) > qmpLogSize {
+		r.msgs = r.msgs[len(r.msgs)-qmpLogSize:]
+	}
+}
+
+func (r *qmpLogRing) tail() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string{}, r.msgs...)
 }
 
 type BlockDevice struct {
@@ -347,6 +471,8 @@ func NewKVM(name, namespace string, config VMConfig) (*KvmVM, error) {
 	vm.KVMConfig = config.KVMConfig.Copy() // deep-copy configured fields
 
 	vm.hotplug = make(map[int]vmHotplug)
+	vm.usb = make(map[int]string)
+	vm.qmpLog = &qmpLogRing{}
 
 	return vm, nil
 }
@@ -369,6 +495,11 @@ func (vm *KvmVM) Copy() VM {
 		vm2.hotplug[k] = v
 	}
 
+	vm2.usb = make(map[int]string)
+	for k, v := range vm.usb {
+		vm2.usb[k] = v
+	}
+
 	return vm2
 }
 
@@ -493,6 +624,12 @@ func (vm *KvmVM) Info(field string) (string, error) {
 	switch field {
 	case "vnc_port":
 		return strconv.Itoa(vm.VNCPort), nil
+	case "vnc_tx":
+		return strconv.FormatUint(atomic.LoadUint64(&vm.vncTxBytes), 10), nil
+	case "vnc_rx":
+		return strconv.FormatUint(atomic.LoadUint64(&vm.vncRxBytes), 10), nil
+	case "qmp_status":
+		return vm.qmpStatus, nil
 	case "pid":
 		return strconv.Itoa(vm.Pid), nil
 	}
@@ -506,6 +643,8 @@ func (vm *KvmVM) Conflicts(vm2 VM) error {
 		return vm.ConflictsKVM(vm2)
 	case *ContainerVM:
 		return vm.BaseVM.conflicts(vm2.BaseVM)
+	case *FirecrackerVM:
+		return vm.BaseVM.conflicts(vm2.BaseVM)
 	}
 
 	return errors.New("unknown VM type")
@@ -567,6 +706,48 @@ func (vm *KvmVM) QMPRaw(input string) (string, error) {
 	return vm.q.Raw(input)
 }
 
+// SaveSnapshot saves a named internal snapshot of the VM's disk and memory via
+// QEMU's savevm. Requires writable qcow2 disks. An existing snapshot with the
+// same tag is overwritten.
+func (vm *KvmVM) SaveSnapshot(tag string) error {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	return vm.hmp("savevm " + tag)
+}
+
+// RestoreSnapshot reverts the VM's disk and memory to a snapshot taken with
+// SaveSnapshot.
+func (vm *KvmVM) RestoreSnapshot(tag string) error {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	return vm.hmp("loadvm " + tag)
+}
+
+// DeleteSnapshot deletes a snapshot taken with SaveSnapshot.
+func (vm *KvmVM) DeleteSnapshot(tag string) error {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	return vm.hmp("delvm " + tag)
+}
+
+// hmp runs a human monitor command, converting any printed output into an
+// error since the commands we use are silent on success.
+func (vm *KvmVM) hmp(command string) error {
+	res, err := vm.q.HumanMonitorCommand(command)
+	if err != nil {
+		return err
+	}
+
+	if res = strings.TrimSpace(res); res != "" {
+		return errors.New(res)
+	}
+
+	return nil
+}
+
 func (vm *KvmVM) Save(filename string) error {
 	// skip save if using kernel/initrd or cdrom as boot device
 	if len(vm.KVMConfig.Disks) == 0 {
@@ -739,7 +920,7 @@ func (vm *KvmVM) connectQMP() (err error) {
 }
 
 func (vm *KvmVM) connectVNC() error {
-	l, err := net.Listen("tcp", "")
+	l, err := reservedPorts.Listen(fmt.Sprintf("vnc %v", vm.Name))
 	if err != nil {
 		return err
 	}
@@ -777,11 +958,12 @@ func (vm *KvmVM) connectVNC() error {
 				}
 				defer local.Close()
 
-				// copy local -> remote
-				go io.Copy(remote, local)
+				// copy local -> remote with a bounded buffer and write
+				// deadlines so a slow client cannot balloon memory
+				go vm.vncCopy(remote, local, &vm.vncTxBytes)
 
 				// Reads will implicitly copy from remote -> local
-				tee := io.TeeReader(remote, local)
+				tee := io.TeeReader(&countingReader{remote, &vm.vncRxBytes}, local)
 				for {
 					msg, err := vnc.ReadClientMessage(tee)
 					if err == nil {
@@ -821,6 +1003,57 @@ func (vm *KvmVM) connectVNC() error {
 	return nil
 }
 
+const (
+	// vncShimBufSize bounds the per-connection copy buffer in the VNC shim.
+	vncShimBufSize = 32 << 10
+
+	// vncShimWriteTimeout is how long the shim waits on a write to a VNC
+	// client before dropping the connection.
+	vncShimWriteTimeout = 30 * time.Second
+)
+
+// vncCopy relays src to dst using a bounded buffer, accumulating the number
+// of bytes copied in counter. Each write must complete within
+// vncShimWriteTimeout or the connection is dropped, so a stalled client
+// cannot back up the shim indefinitely.
+func (vm *KvmVM) vncCopy(dst net.Conn, src io.Reader, counter *uint64) {
+	buf := make([]byte, vncShimBufSize)
+
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			dst.SetWriteDeadline(time.Now().Add(vncShimWriteTimeout))
+
+			w, err := dst.Write(buf[:n])
+			atomic.AddUint64(counter, uint64(w))
+			if err != nil {
+				log.Debug("vnc shim write for %v: %v", vm.Name, err)
+				return
+			}
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				log.Debug("vnc shim read for %v: %v", vm.Name, err)
+			}
+			return
+		}
+	}
+}
+
+// countingReader wraps a reader, atomically accumulating the number of bytes
+// read in counter.
+type countingReader struct {
+	io.Reader
+	counter *uint64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	atomic.AddUint64(r.counter, uint64(n))
+	return n, err
+}
+
 // createTapName will return a generated tap name from the specified bridge
 func (vm *KvmVM) createTapName(bridge string) (string, error) {
 	br, err := getBridge(bridge)
@@ -861,6 +1094,25 @@ func (vm *KvmVM) createTaps() error {
 			continue
 		}
 
+		br, err := getBridge(nic.Bridge)
+		if err != nil {
+			return vm.setErrorf("unable to get bridge %v: %v", nic.Bridge, err)
+		}
+
+		if br.DPDK() {
+			// vhost-user port instead of a kernel tap -- qemu serves the
+			// socket and the vswitch connects to it (see qemuArgs)
+			tap := br.CreateTapName()
+
+			if _, err := br.CreateVhostUserPort(tap, vm.path(tap+".sock"), nic.VLAN); err != nil {
+				return vm.setErrorf("unable to create vhost-user port %v: %v", i, err)
+			}
+
+			nic.Tap = tap
+			nic.Vhost = true
+			continue
+		}
+
 		tap, err := vm.addTap("", nic.Bridge, nic.MAC, nic.VLAN, nic.QinQ)
 		if err != nil {
 			return vm.setErrorf("unable to create tap %v: %v", i, err)
@@ -921,7 +1173,7 @@ func (vm *KvmVM) launch() error {
 		if vm.Snapshot {
 			for i, d := range vm.Disks {
 				dst := vm.path(fmt.Sprintf("disk-%v.qcow2", i))
-				if err := diskSnapshot(d.Path, dst); err != nil {
+				if err := diskSnapshot(d.Path, dst, d.KeyFile); err != nil {
 					return vm.setErrorf("unable to snapshot %v: %v", d, err)
 				}
 
@@ -960,12 +1212,23 @@ func (vm *KvmVM) launch() error {
 		}
 	}
 
+	// if using nested virtualization, error out if the host doesn't support it
+	if vmConfig.Nested {
+		if err := checkNestedVirt(); err != nil {
+			return fmt.Errorf("nested virtualization not supported. Please disable: %v", err)
+		}
+	}
+
 	args := vmConfig.qemuArgs(vm.ID, vm.instancePath)
 	args = vmConfig.applyQemuOverrides(args)
 	log.Debug("final qemu args: %#v", args)
 
 	// if the QemuPath is not absolute, try a lookup based on $PATH
 	qemu := vm.QemuPath
+	if qemu == "kvm" && vm.Arch != "" && vm.Arch != hostQemuArch() {
+		// cross-architecture guests need the matching emulator binary
+		qemu = "qemu-system-" + vm.Arch
+	}
 	if !filepath.IsAbs(qemu) {
 		v, err := process(qemu)
 		if err != nil {
@@ -988,6 +1251,9 @@ func (vm *KvmVM) launch() error {
 	vm.Pid = cmd.Process.Pid
 	log.Debug("vm %v has pid %v", vm.ID, vm.Pid)
 
+	// keep a reference to qemu's stderr for crash triage
+	vm.qemuStderr = &sErr
+
 	// Channel to signal when the process has exited
 	var waitChan = make(chan bool)
 
@@ -1000,8 +1266,12 @@ func (vm *KvmVM) launch() error {
 		return vm.setErrorf("unable to connect to qmp socket: %v. qemu output: %v", err, sErr.String())
 	}
 
+	vm.qmpStatus = "connected"
+
 	go vm.qmpLogger()
 
+	vm.qmpHealth(waitChan)
+
 	if err := vm.connectVNC(); err != nil {
 		// Failed to connect to vnc so clean up the process
 		cmd.Process.Kill()
@@ -1035,8 +1305,13 @@ func (vm *KvmVM) waitForExit(p *os.Process, wait chan bool) {
 
 		// Check if the process quit for some reason other than being killed
 		if err != nil && err.Error() != "signal: killed" {
+			vm.writeCrashReport(err)
 			vm.setErrorf("qemu killed: %v", err)
 		} else if vm.State != VM_ERROR {
+			if vm.autoScreenshot() {
+				vm.saveCrashScreenshot()
+			}
+
 			// Set to QUIT unless we've already been put into the error state
 			vm.setState(VM_QUIT)
 		}
@@ -1044,6 +1319,7 @@ func (vm *KvmVM) waitForExit(p *os.Process, wait chan bool) {
 		// Kill the VNC shim, if it exists
 		if vm.vncShim != nil {
 			vm.vncShim.Close()
+			reservedPorts.Release(vm.VNCPort)
 		}
 	}()
 }
@@ -1093,8 +1369,14 @@ func (vm *KvmVM) AddNIC(nic NetConfig) error {
 	defer vm.lock.Unlock()
 
 	if nic.MAC == "" {
-		nic.MAC = randomMac()
+		nic.MAC = vm.generateMac()
 	}
+
+	if br, err := getBridge(nic.Bridge); err == nil && br.DPDK() {
+		// qemu would need a new chardev and shared memory backend
+		return errors.New("cannot hot-plug interface on a DPDK bridge")
+	}
+
 	var err error
 	nic.Tap, err = vm.createTapName(nic.Bridge)
 	vm.Networks = append(vm.Networks, nic)
@@ -1238,6 +1520,133 @@ func (vm *KvmVM) HotplugInfo() map[int]vmHotplug {
 	return res
 }
 
+// parseUSBDevice converts a host USB device spec, either vendor:product or
+// bus.port, into options for qemu's usb-host device.
+func parseUSBDevice(spec string) (string, error) {
+	if v := strings.Split(spec, ":"); len(v) == 2 {
+		vendor, err := strconv.ParseUint(v[0], 16, 16)
+		product, err2 := strconv.ParseUint(v[1], 16, 16)
+		if err != nil || err2 != nil {
+			return "", fmt.Errorf("invalid vendor:product: `%v`", spec)
+		}
+
+		return fmt.Sprintf("vendorid=0x%04x,productid=0x%04x", vendor, product), nil
+	}
+
+	if v := strings.SplitN(spec, ".", 2); len(v) == 2 {
+		bus, err := strconv.Atoi(v[0])
+		if err != nil {
+			return "", fmt.Errorf("invalid bus.port: `%v`", spec)
+		}
+
+		// the port may be a dotted path for devices behind a hub, e.g. 3.2.4
+		for _, p := range strings.Split(v[1], ".") {
+			if _, err := strconv.Atoi(p); err != nil {
+				return "", fmt.Errorf("invalid bus.port: `%v`", spec)
+			}
+		}
+
+		return fmt.Sprintf("hostbus=%v,hostport=%v", bus, v[1]), nil
+	}
+
+	return "", fmt.Errorf("invalid usb device: `%v`, expected vendor:product or bus.port", spec)
+}
+
+// USBAttach passes a host USB device through to the VM using qemu's usb-host
+// device. spec identifies the device by vendor:product or bus.port.
+func (vm *KvmVM) USBAttach(spec string) error {
+	options, err := parseUSBDevice(spec)
+	if err != nil {
+		return err
+	}
+
+	bus := "ehci.0"
+	if vm.UsbUseXHCI {
+		bus = "xhci.0"
+	}
+
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	// generate an id by adding 1 to the highest in the list for the
+	// attached devices, 0 if it's empty
+	id := 0
+	for k := range vm.usb {
+		if k >= id {
+			id = k + 1
+		}
+	}
+
+	uid := fmt.Sprintf("usbhost%v", id)
+	log.Debugln("usb attach generated id:", uid)
+
+	r, err := vm.q.USBHostDeviceAdd(uid, bus, options)
+	if err != nil {
+		return err
+	}
+
+	log.Debugln("usb attach device add response:", r)
+	vm.usb[id] = spec
+
+	return nil
+}
+
+func (vm *KvmVM) USBDetachAll() error {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	if len(vm.usb) == 0 {
+		return errors.New("no usb devices to detach")
+	}
+
+	for k := range vm.usb {
+		if err := vm.usbDetach(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (vm *KvmVM) USBDetach(id int) error {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	return vm.usbDetach(id)
+}
+
+func (vm *KvmVM) usbDetach(id int) error {
+	if _, ok := vm.usb[id]; !ok {
+		return errors.New("no such usb device")
+	}
+
+	uid := fmt.Sprintf("usbhost%v", id)
+
+	resp, err := vm.q.USBDeviceDel(uid)
+	if err != nil {
+		return err
+	}
+
+	log.Debugln("usb detach device del response:", resp)
+	delete(vm.usb, id)
+
+	return nil
+}
+
+// USBInfo returns a copy of the VM's attached host USB devices
+func (vm *KvmVM) USBInfo() map[int]string {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	res := map[int]string{}
+
+	for k, v := range vm.usb {
+		res[k] = v
+	}
+
+	return res
+}
+
 func (vm *KvmVM) ChangeCD(f string, force bool) error {
 	vm.lock.Lock()
 	defer vm.lock.Unlock()
@@ -1282,6 +1691,12 @@ func (vm *KvmVM) ProcStats() (map[int]*ProcStats, error) {
 		return nil, err
 	}
 
+	p.VCPUs, err = GetVCPUStats(vm.Pid)
+	if err != nil {
+		// not fatal, `vm vcpu` just won't have anything to report
+		log.Debug("failed to get vCPU stats for %v: %v", vm.ID, err)
+	}
+
 	return map[int]*ProcStats{vm.Pid: p}, nil
 }
 
@@ -1306,13 +1721,39 @@ func (vm VMConfig) qemuArgs(id int, vmPath string) []string {
 	args = append(args, "-name")
 	args = append(args, strconv.Itoa(id))
 
+	arch := vm.qemuArch()
+	crossArch := arch != hostQemuArch()
+
 	if vm.Machine != "" {
 		args = append(args, "-M", vm.Machine)
+	} else if arch == "aarch64" {
+		// the virt machine is the catch-all for aarch64 guests
+		args = append(args, "-M", "virt")
+	}
+
+	if crossArch {
+		// no KVM acceleration across architectures
+		args = append(args, "-accel", "tcg")
+	}
+
+	if vm.FirmwarePath != "" {
+		args = append(args, "-bios", vm.FirmwarePath)
 	}
 
 	args = append(args, "-m")
 	args = append(args, strconv.FormatUint(vm.Memory, 10))
 
+	// vhost-user interfaces require guest memory that the vswitch can map
+	for _, net := range vm.Networks {
+		if net.Vhost {
+			args = append(args, "-object")
+			args = append(args, fmt.Sprintf("memory-backend-file,id=mem0,size=%vM,mem-path=/dev/hugepages,share=on", vm.Memory))
+			args = append(args, "-numa")
+			args = append(args, "node,memdev=mem0")
+			break
+		}
+	}
+
 	args = append(args, "-nographic")
 
 	args = append(args, "-vnc")
@@ -1342,18 +1783,25 @@ func (vm VMConfig) qemuArgs(id int, vmPath string) []string {
 	}
 
 	args = append(args, "-rtc")
-	args = append(args, "clock=vm,base=utc")
+	args = append(args, "clock=vm,base="+vm.rtcBase())
 
-	// for USB 1.0, creates bus named usb-bus.0
-	args = append(args, "-usb")
-	// create bus for xHCI or EHCI depending on config
-	if vm.UsbUseXHCI {
+	if arch == "aarch64" {
+		// virt has no built-in USB bus, provide one via xHCI and let the
+		// tablet attach to it
 		args = append(args, "-device", "qemu-xhci,id=xhci")
+		args = append(args, "-device", "usb-tablet")
 	} else {
-		args = append(args, "-device", "usb-ehci,id=ehci")
+		// for USB 1.0, creates bus named usb-bus.0
+		args = append(args, "-usb")
+		// create bus for xHCI or EHCI depending on config
+		if vm.UsbUseXHCI {
+			args = append(args, "-device", "qemu-xhci,id=xhci")
+		} else {
+			args = append(args, "-device", "usb-ehci,id=ehci")
+		}
+		// this allows absolute pointers in vnc, and works great on android vms
+		args = append(args, "-device", "usb-tablet,bus=usb-bus.0")
 	}
-	// this allows absolute pointers in vnc, and works great on android vms
-	args = append(args, "-device", "usb-tablet,bus=usb-bus.0")
 
 	if vm.TpmSocketPath != "" {
 		args = append(args, "-chardev")
@@ -1379,9 +1827,27 @@ func (vm VMConfig) qemuArgs(id int, vmPath string) []string {
 	args = append(args, "-k")
 	args = append(args, "en-us")
 
-	if vm.CPU != "" {
+	if vm.CPU != "" || vm.CpuFlags != "" {
+		cpu := vm.CPU
+		if cpu == "" {
+			// flags require a model to modify
+			cpu = DefaultKVMCPU
+		}
+		if crossArch && cpu == DefaultKVMCPU {
+			// "host" requires KVM acceleration, "max" is the TCG equivalent
+			cpu = "max"
+		}
+		if vm.Nested {
+			if flag := nestedCPUFlag(); flag != "" {
+				cpu += ",+" + flag
+			}
+		}
+		if vm.CpuFlags != "" {
+			cpu += "," + vm.CpuFlags
+		}
+
 		args = append(args, "-cpu")
-		args = append(args, vm.CPU)
+		args = append(args, cpu)
 	}
 
 	args = append(args, "-net")
@@ -1410,7 +1876,7 @@ func (vm VMConfig) qemuArgs(id int, vmPath string) []string {
 	// disks
 	var ahciBusSlot int
 
-	for _, diskConfig := range vm.Disks {
+	for i, diskConfig := range vm.Disks {
 		var driveParams string
 
 		path := diskConfig.Path
@@ -1446,6 +1912,22 @@ func (vm VMConfig) qemuArgs(id int, vmPath string) []string {
 			}
 		}
 
+		if diskConfig.KeyFile != "" {
+			// expose the passphrase to QEMU as a secret object
+			secret := fmt.Sprintf("luks-%v", i)
+
+			args = append(args, "-object")
+			args = append(args, fmt.Sprintf("secret,id=%v,file=%v", secret, diskConfig.KeyFile))
+
+			driveParams = fmt.Sprintf("%v,encrypt.key-secret=%v", driveParams, secret)
+
+			if path != diskConfig.Path {
+				// running from a snapshot overlay -- the backing image is
+				// encrypted with the same key
+				driveParams = fmt.Sprintf("%v,backing.encrypt.key-secret=%v", driveParams, secret)
+			}
+		}
+
 		args = append(args, "-drive")
 		args = append(args, driveParams)
 	}
@@ -1473,9 +1955,16 @@ func (vm VMConfig) qemuArgs(id int, vmPath string) []string {
 	}
 
 	addBus()
-	for _, net := range vm.Networks {
-		args = append(args, "-netdev")
-		args = append(args, fmt.Sprintf("tap,id=%v,script=no,ifname=%v", net.Tap, net.Tap))
+	for i, net := range vm.Networks {
+		if net.Vhost {
+			args = append(args, "-chardev")
+			args = append(args, fmt.Sprintf("socket,id=charvhost%v,path=%v,server=on,wait=off", i, filepath.Join(vmPath, net.Tap+".sock")))
+			args = append(args, "-netdev")
+			args = append(args, fmt.Sprintf("vhost-user,id=%v,chardev=charvhost%v", net.Tap, i))
+		} else {
+			args = append(args, "-netdev")
+			args = append(args, fmt.Sprintf("tap,id=%v,script=no,ifname=%v", net.Tap, net.Tap))
+		}
 		args = append(args, "-device")
 		args = append(args, fmt.Sprintf("driver=%v,netdev=%v,mac=%v,bus=pci.%v,addr=0x%x", net.Driver, net.Tap, net.MAC, bus, addr))
 		addr++
@@ -1608,12 +2097,268 @@ func (c QemuOverrides) WriteConfig(w io.Writer) error {
 }
 
 // log any asynchronous messages, such as vnc connects, to log.Info
+// setQMPStatus updates the recorded health of the VM's qmp connection.
+func (vm *KvmVM) setQMPStatus(s string) {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	if vm.qmpStatus != s {
+		log.Info("vm %v qmp status: %v", vm.ID, s)
+	}
+
+	vm.qmpStatus = s
+}
+
+// qmpHealth starts a goroutine that periodically checks that the QMP
+// connection is still usable and, if it isn't, redials with backoff until
+// QEMU answers again. Without this, a dropped QMP socket leaves the VM
+// uncontrollable until it is relaunched. The goroutine exits when the QEMU
+// process does.
+func (vm *KvmVM) qmpHealth(wait chan bool) {
+	go func() {
+		for {
+			select {
+			case <-wait:
+				vm.setQMPStatus("disconnected")
+				return
+			case <-time.After(QMP_HEALTH_INTERVAL * time.Second):
+			}
+
+			// keepalive -- any command that round-trips will do
+			if _, err := vm.q.Status(); err == nil {
+				vm.setQMPStatus("connected")
+
+				// keep a rolling screendump around so that there is
+				// something to attach once the VM goes away
+				if vm.autoScreenshot() {
+					if err := vm.q.Screendump(vm.path("screenshot.ppm")); err != nil {
+						log.Debug("screendump failed for vm %v: %v", vm.ID, err)
+					}
+				}
+
+				continue
+			}
+
+			vm.setQMPStatus("reconnecting")
+
+			delay := QMP_CONNECT_DELAY * time.Millisecond
+
+			for {
+				select {
+				case <-wait:
+					vm.setQMPStatus("disconnected")
+					return
+				default:
+				}
+
+				q, err := qmp.Dial(vm.path("qmp"))
+				if err == nil {
+					vm.lock.Lock()
+					vm.q = q
+					vm.lock.Unlock()
+
+					vm.setQMPStatus("connected")
+
+					// the old logger exited with the old connection
+					go vm.qmpLogger()
+
+					break
+				}
+
+				log.Debug("qmp redial to %v: %v, retrying in %v", vm.ID, err, delay)
+				time.Sleep(delay)
+
+				if delay < QMP_RECONNECT_MAX_DELAY*time.Second {
+					delay *= 2
+				}
+			}
+		}
+	}()
+}
+
 func (vm KvmVM) qmpLogger() {
 	for v := vm.q.Message(); v != nil; v = vm.q.Message() {
 		log.Info("VM %v received asynchronous message: %v", vm.ID, v)
+		vm.qmpLog.add(fmt.Sprintf("%v", v))
 	}
 }
 
+// writeCrashReport captures triage data for an unexpected qemu exit into
+// "crash.report" in the instance path: the exit status, qemu's stderr, a tail
+// of the QMP log, and the core dump, if the kernel wrote one somewhere we can
+// find it. Assumes that the caller has locked the vm.
+func (vm *KvmVM) writeCrashReport(exit error) {
+	f, err := os.Create(vm.path("crash.report"))
+	if err != nil {
+		log.Error("unable to create crash report for vm %v: %v", vm.ID, err)
+		return
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "vm:   %v (id %v)\n", vm.Name, vm.ID)
+	fmt.Fprintf(f, "pid:  %v\n", vm.Pid)
+	fmt.Fprintf(f, "time: %v\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(f, "exit: %v\n", exit)
+
+	if core := vm.captureCore(); core != "" {
+		fmt.Fprintf(f, "core: %v\n", core)
+	}
+
+	if vm.autoScreenshot() {
+		if p := vm.saveCrashScreenshot(); p != "" {
+			fmt.Fprintf(f, "screenshot: %v\n", p)
+		}
+	}
+
+	fmt.Fprintf(f, "\n[qemu stderr]\n")
+	if vm.qemuStderr != nil {
+		f.Write(vm.qemuStderr.Bytes())
+	}
+
+	fmt.Fprintf(f, "\n[qmp log tail]\n")
+	if vm.qmpLog != nil {
+		for _, v := range vm.qmpLog.tail() {
+			fmt.Fprintln(f, v)
+		}
+	}
+
+	log.Info("wrote crash report for vm %v", vm.ID)
+}
+
+// autoScreenshot returns true if the vm's namespace has auto-screenshot
+// enabled, see "ns auto-screenshot".
+func (vm *KvmVM) autoScreenshot() bool {
+	return GetOrCreateNamespace(vm.Namespace).AutoScreenshot
+}
+
+// saveCrashScreenshot converts the most recent periodic screendump (see
+// qmpHealth) into "crash.png" in the instance path. QEMU is already gone by
+// the time we notice the state change, so this is a picture of the console
+// from up to QMP_HEALTH_INTERVAL seconds before the VM went away. Returns the
+// path to the png or "" if no screendump is available.
+func (vm *KvmVM) saveCrashScreenshot() string {
+	ppm, err := ioutil.ReadFile(vm.path("screenshot.ppm"))
+	if err != nil {
+		log.Debug("no screendump for vm %v: %v", vm.ID, err)
+		return ""
+	}
+
+	img, err := ppmToPng(ppm, 0)
+	if err != nil {
+		log.Error("unable to convert screendump for vm %v: %v", vm.ID, err)
+		return ""
+	}
+
+	dst := vm.path("crash.png")
+	if err := ioutil.WriteFile(dst, img, 0664); err != nil {
+		log.Error("unable to write screenshot for vm %v: %v", vm.ID, err)
+		return ""
+	}
+
+	log.Warn("vm %v: saved screenshot from before exit to %v", vm.ID, dst)
+
+	return dst
+}
+
+// captureCore moves a core dump for the qemu process into the instance path,
+// if the kernel wrote one to our working directory. Core files piped to an
+// external handler or written to an absolute path (see core(5)) are left
+// where they are.
+func (vm *KvmVM) captureCore() string {
+	pattern, err := ioutil.ReadFile("/proc/sys/kernel/core_pattern")
+	if err != nil {
+		return ""
+	}
+
+	p := strings.TrimSpace(string(pattern))
+	if strings.HasPrefix(p, "|") || filepath.IsAbs(p) {
+		return ""
+	}
+
+	// cores from the default core_pattern land in our working directory as
+	// "core" or "core.<pid>"
+	for _, name := range []string{fmt.Sprintf("core.%v", vm.Pid), "core"} {
+		if _, err := os.Stat(name); err != nil {
+			continue
+		}
+
+		dst := vm.path("qemu.core")
+		if err := os.Rename(name, dst); err != nil {
+			log.Error("unable to move core for vm %v: %v", vm.ID, err)
+			return ""
+		}
+
+		return dst
+	}
+
+	return ""
+}
+
+// checkNestedVirt verifies that the host's kvm module has nested
+// virtualization enabled.
+func checkNestedVirt() error {
+	for _, module := range []string{"kvm_intel", "kvm_amd"} {
+		data, err := ioutil.ReadFile(filepath.Join("/sys/module", module, "parameters", "nested"))
+		if err != nil {
+			continue
+		}
+
+		switch strings.TrimSpace(string(data)) {
+		case "Y", "y", "1":
+			return nil
+		}
+
+		return fmt.Errorf("nested disabled in %v, enable with `modprobe %v nested=1`", module, module)
+	}
+
+	return errors.New("no kvm module with nested support loaded")
+}
+
+// nestedCPUFlag returns the CPU flag that exposes the host's hardware
+// virtualization extensions to the guest, based on which kvm module is loaded.
+func nestedCPUFlag() string {
+	if _, err := os.Stat("/sys/module/kvm_intel"); err == nil {
+		return "vmx"
+	}
+
+	if _, err := os.Stat("/sys/module/kvm_amd"); err == nil {
+		return "svm"
+	}
+
+	return ""
+}
+
+// rtcTimeLayout is the date-time format accepted by "vm config rtc-base" and
+// by the qemu -rtc base option.
+const rtcTimeLayout = "2006-01-02T15:04:05"
+
+// rtcBase resolves the configured RTC base into a value for qemu's -rtc base
+// option. Offsets are applied to the host clock at launch time.
+func (vm VMConfig) rtcBase() string {
+	if vm.RtcBase == "" {
+		return "utc"
+	}
+
+	if d, err := time.ParseDuration(vm.RtcBase); err == nil {
+		return time.Now().UTC().Add(d).Format(rtcTimeLayout)
+	}
+
+	// validRtcBase already ensured that this parses as a date-time
+	return vm.RtcBase
+}
+
+func validRtcBase(vmConfig VMConfig, base string) error {
+	if _, err := time.ParseDuration(base); err == nil {
+		return nil
+	}
+
+	if _, err := time.Parse(rtcTimeLayout, base); err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("invalid rtc base: `%v`, expected offset such as -240h or date-time in the format %v", base, rtcTimeLayout)
+}
+
 func validCPU(vmConfig VMConfig, cpu string) error {
 	cpus, err := qemu.CPUs(vmConfig.QemuPath, vmConfig.Machine)
 	if err != nil {
@@ -1627,6 +2372,49 @@ func validCPU(vmConfig VMConfig, cpu string) error {
 	return nil
 }
 
+// validCpuFlags matches a single +flag or -flag entry.
+var validCpuFlag = regexp.MustCompile(`^[+-][a-z0-9._-]+$`)
+
+func validCpuFlags(vmConfig VMConfig, flags string) error {
+	for _, flag := range strings.Split(flags, ",") {
+		if !validCpuFlag.MatchString(flag) {
+			return fmt.Errorf("invalid CPU flag: `%v`, expected +flag or -flag", flag)
+		}
+	}
+
+	return nil
+}
+
+// hostQemuArch maps the host GOARCH to the QEMU architecture name.
+func hostQemuArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	}
+
+	return runtime.GOARCH
+}
+
+// qemuArch returns the guest architecture, defaulting to the host's.
+func (vm VMConfig) qemuArch() string {
+	if vm.Arch != "" {
+		return vm.Arch
+	}
+
+	return hostQemuArch()
+}
+
+func validArch(vmConfig VMConfig, arch string) error {
+	switch arch {
+	case "x86_64", "aarch64":
+		return nil
+	}
+
+	return fmt.Errorf("invalid architecture: `%v`, expected x86_64 or aarch64", arch)
+}
+
 func validMachine(vmConfig VMConfig, machine string) error {
 	machines, err := qemu.Machines(vmConfig.QemuPath)
 	if err != nil {
@@ -1637,6 +2425,19 @@ func validMachine(vmConfig VMConfig, machine string) error {
 		return fmt.Errorf("invalid QEMU machine: `%v`, see help", machine)
 	}
 
+	// changing the machine changes the set of valid CPUs so make sure that
+	// the currently configured CPU is still one of them
+	if vmConfig.CPU != "" {
+		cpus, err := qemu.CPUs(vmConfig.QemuPath, machine)
+		if err != nil {
+			return err
+		}
+
+		if !cpus[vmConfig.CPU] {
+			return fmt.Errorf("invalid QEMU CPU for machine `%v`: `%v`, see help", machine, vmConfig.CPU)
+		}
+	}
+
 	return nil
 }
 
@@ -19,6 +19,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/sandia-minimega/minimega/v2/internal/bridge"
 	"github.com/sandia-minimega/minimega/v2/internal/version"
 	"github.com/sandia-minimega/minimega/v2/internal/vlans"
 	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
@@ -55,10 +56,13 @@ var (
 	f_pipe        = flag.String("pipe", "", "read/write to or from a named pipe")
 	f_headnode    = flag.String("headnode", "", "mesh node to send all logs to and get all files from")
 	f_hashfiles   = flag.Bool("hashfiles", false, "hash files to be served by iomeshage")
+	f_helper      = flag.String("helper", "", "command prefix (e.g. `sudo -n` or a setuid helper) for privileged network operations, allows running without root")
+	f_history     = flag.String("history", "", "file to append command history with provenance to, see `history`")
 
 	f_e         = flag.Bool("e", false, "execute command on running minimega")
 	f_attach    = flag.Bool("attach", false, "attach the minimega command line to a running instance of minimega")
 	f_namespace = flag.String("namespace", "", "prepend namespace to all -attach and -e commands")
+	f_readonly  = flag.Bool("readonly", false, "with -attach or -e, reject commands that modify state")
 
 	hostname string
 	reserved = []string{Wildcard}
@@ -127,13 +131,30 @@ func main() {
 		return
 	}
 
+	// append structured command history to a file, if one is set
+	if *f_history != "" {
+		if err := initHistoryFile(*f_history); err != nil {
+			log.Fatal("unable to open history file: %v", err)
+		}
+	}
+
+	// delegate privileged network operations to a helper, if one is set
+	if *f_helper != "" {
+		helper = strings.Fields(*f_helper)
+		bridge.SetHelper(helper)
+	}
+
 	// warn if we're not root
 	user, err := user.Current()
 	if err != nil {
 		log.Fatalln(err)
 	}
 	if user.Uid != "0" {
-		log.Warnln("not running as root")
+		if *f_helper != "" {
+			log.Info("not running as root, using helper for network operations: %v", *f_helper)
+		} else {
+			log.Warnln("not running as root")
+		}
 	}
 
 	// set global for hostname
@@ -162,6 +183,7 @@ func main() {
 			log.Fatalln(err)
 		}
 		mm.Pager = minipager.DefaultPager
+		mm.ReadOnly = *f_readonly
 
 		if *f_e {
 			parts := []string{}
@@ -250,6 +272,8 @@ func main() {
 		log.Fatal("unable to setup mesh logging: %v", err)
 	}
 
+	meshLivenessStart()
+
 	if *f_recover { // has to happen after meshageNode is created
 		if err := recover(); err != nil {
 			log.Fatal("recovery failed: %v", err)
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sandia-minimega/minimega/v2/internal/version"
 	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
 	"github.com/sandia-minimega/minimega/v2/pkg/ranges"
 )
@@ -25,6 +27,23 @@ var meshageCLIHandlers = []minicli.Handler{
 		},
 		Call: wrapSimpleCLI(cliMeshageDegree),
 	},
+	{ // mesh compress
+		HelpShort: "view or set mesh message compression",
+		HelpLong: `
+View or set whether large mesh message bodies are compressed before they are
+sent. Compression reduces command broadcast and file transfer time on slower
+management networks at the cost of some CPU. Only bodies over a few KB are
+compressed, small messages are always sent as-is.
+
+All nodes in the mesh must be running a version of minimega that understands
+compressed messages, though they need not all have compression enabled.
+
+By default, compression is disabled.`,
+		Patterns: []string{
+			"mesh compress [true,false]",
+		},
+		Call: wrapSimpleCLI(cliMeshageCompress),
+	},
 	{ // mesh dial
 		HelpShort: "attempt to connect this node to another node",
 		Patterns: []string{
@@ -85,6 +104,30 @@ By default, the mesh timeout is 0 which disables timeouts.`,
 		},
 		Call: wrapSimpleCLI(cliMeshageTimeout),
 	},
+	{ // mesh health
+		HelpShort: "check the health of the mesh",
+		HelpLong: `
+Probe each mesh peer and report issues that otherwise manifest as mysterious
+command timeouts. For every peer, mesh health measures the command round-trip
+latency, retries with a large (~9KB) payload to detect MTU blackholes along
+the path, and compares the peer's version against the local one. It also
+inspects the adjacency list for one-way links, which indicate asymmetric
+connectivity.
+
+The report is one row per peer:
+
+- latency : round-trip time of a trivial command
+- payload : round-trip time of the same command with a ~9KB payload
+- version : the peer's minimega version
+- issues  : version mismatches, one-way links, timeouts, if any
+
+Each probe waits up to 10 seconds, so the command may take a while on a large
+or unhealthy mesh.`,
+		Patterns: []string{
+			"mesh health",
+		},
+		Call: wrapSimpleCLI(cliMeshageHealth),
+	},
 	{ // mesh send
 		HelpShort: "send a command to one or more connected clients",
 		HelpLong: `
@@ -118,6 +161,16 @@ func cliMeshageDegree(ns *Namespace, c *minicli.Command, resp *minicli.Response)
 	return nil
 }
 
+func cliMeshageCompress(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["true"] || c.BoolArgs["false"] {
+		meshageNode.SetCompression(c.BoolArgs["true"])
+		return nil
+	}
+
+	resp.Response = strconv.FormatBool(meshageNode.GetCompression())
+	return nil
+}
+
 func cliMeshageDial(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	return meshageNode.Dial(c.StringArgs["hostname"])
 }
@@ -195,6 +248,110 @@ func cliMeshageStatus(ns *Namespace, c *minicli.Command, resp *minicli.Response)
 	return nil
 }
 
+// meshHealthTimeout is how long each mesh health probe waits for a response.
+const meshHealthTimeout = 10 * time.Second
+
+// meshProbe runs a command on a single host via the mesh and returns the
+// round-trip time along with the response.
+func meshProbe(cmd *minicli.Command, host string) (time.Duration, string, error) {
+	start := time.Now()
+
+	respChan, err := meshageSend(cmd, host)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var response string
+
+	for {
+		select {
+		case resps, ok := <-respChan:
+			if !ok {
+				return time.Since(start), response, err
+			}
+
+			for _, r := range resps {
+				if r.Error != "" {
+					err = errors.New(r.Error)
+				} else {
+					response = r.Response
+				}
+			}
+		case <-time.After(meshHealthTimeout):
+			return 0, "", errors.New("timeout")
+		}
+	}
+}
+
+func cliMeshageHealth(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	mesh := meshageNode.Mesh()
+
+	// find one-way links in the adjacency list, keyed by endpoint
+	asymmetric := map[string][]string{}
+
+	for a, neighbors := range mesh {
+		for _, b := range neighbors {
+			var found bool
+			for _, x := range mesh[b] {
+				if x == a {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				issue := fmt.Sprintf("one-way link %v -> %v", a, b)
+				asymmetric[a] = append(asymmetric[a], issue)
+				asymmetric[b] = append(asymmetric[b], issue)
+			}
+		}
+	}
+
+	localVersion := fmt.Sprintf("minimega %v %v %v", version.Version, version.Revision, version.Date)
+
+	// ~9KB payload to catch MTU blackholes that a small command slips past
+	payload := strings.Repeat("x", 9000)
+
+	peers := meshageNode.BroadcastRecipients()
+	sort.Strings(peers)
+
+	resp.Header = []string{"host", "latency", "payload", "version", "issues"}
+
+	for _, host := range peers {
+		issues := asymmetric[host]
+		latency, payloadLatency, peerVersion := "-", "-", "-"
+
+		d, v, err := meshProbe(minicli.MustCompile("version"), host)
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("unreachable: %v", err))
+		} else {
+			latency = d.Truncate(time.Microsecond).String()
+			peerVersion = v
+
+			if v != localVersion {
+				issues = append(issues, fmt.Sprintf("version mismatch, local is `%v`", localVersion))
+			}
+
+			d, _, err = meshProbe(minicli.MustCompilef("echo %v", payload), host)
+			if err != nil {
+				issues = append(issues, fmt.Sprintf("large payload failed, possible MTU blackhole: %v", err))
+			} else {
+				payloadLatency = d.Truncate(time.Microsecond).String()
+			}
+		}
+
+		resp.Tabular = append(resp.Tabular, []string{
+			host,
+			latency,
+			payloadLatency,
+			peerVersion,
+			strings.Join(issues, "; "),
+		})
+	}
+
+	return nil
+}
+
 func cliMeshageTimeout(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	if c.StringArgs["timeout"] != "" {
 		timeout, err := strconv.Atoi(c.StringArgs["timeout"])
@@ -0,0 +1,201 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// metadataIP is the well-known link-local address that cloud images query for
+// instance metadata.
+const metadataIP = "169.254.169.254"
+
+var (
+	metadataLock     sync.Mutex     // guards below
+	metadataListener net.Listener   // nil when the service is stopped
+	metadataTaps     map[string]int // tap name -> VLAN for taps we created
+	metadataUserData string         // default user-data
+)
+
+// metadataFindVM returns the VM that owns the given IP, searching all
+// namespaces. The IPs are learned from the bridge so a VM doesn't show up
+// until it has sent some traffic.
+func metadataFindVM(ip string) VM {
+	var found VM
+
+	for _, name := range ListNamespaces() {
+		ns := GetOrCreateNamespace(name)
+
+		ns.VMs.Apply(Wildcard, func(vm VM, _ bool) (bool, error) {
+			for _, nic := range vm.GetNetworks() {
+				if nic.IP4 == ip || nic.IP6 == ip {
+					found = vm
+				}
+			}
+			return true, nil
+		})
+
+		if found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// metadataServe starts the metadata listener, if it isn't already running,
+// and creates a host tap carrying the metadata IP on the given VLAN.
+func metadataServe(ns *Namespace, b string, vlan int) (string, error) {
+	metadataLock.Lock()
+	defer metadataLock.Unlock()
+
+	if metadataListener == nil {
+		l, err := net.Listen("tcp", metadataIP+":80")
+		if err != nil {
+			return "", fmt.Errorf("metadata listen: %v", err)
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", metadataHandler)
+
+		go func() {
+			if err := http.Serve(l, mux); err != nil {
+				// expected when the listener is closed via `clear metadata`
+				log.Debug("metadata server exited: %v", err)
+			}
+		}()
+
+		metadataListener = l
+		metadataTaps = map[string]int{}
+	}
+
+	for tap, v := range metadataTaps {
+		if v == vlan {
+			return tap, fmt.Errorf("already serving metadata on VLAN %v", vlan)
+		}
+	}
+
+	tap, err := hostTapCreate(b, "", vlan)
+	if err != nil {
+		return "", err
+	}
+	ns.Taps[tap] = true
+
+	out, err := privProcessWrapper("ip", "addr", "add", "dev", tap, metadataIP+"/16")
+	if err != nil {
+		// try to clean up the tap
+		if err := hostTapDelete(ns, tap); err != nil {
+			log.Error("zombie metadata tap -- %v %v", tap, err)
+		}
+		return "", fmt.Errorf("ip error %v: `%v`", err, out)
+	}
+
+	metadataTaps[tap] = vlan
+
+	return tap, nil
+}
+
+// metadataClear stops the metadata listener and deletes the taps it created.
+func metadataClear(ns *Namespace) error {
+	metadataLock.Lock()
+	defer metadataLock.Unlock()
+
+	if metadataListener != nil {
+		metadataListener.Close()
+		metadataListener = nil
+	}
+
+	var errs []error
+	for tap := range metadataTaps {
+		if err := hostTapDelete(ns, tap); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", tap, err))
+		}
+	}
+	metadataTaps = nil
+	metadataUserData = ""
+
+	return makeErrSlice(errs)
+}
+
+// metadataHandler serves a minimal EC2-style metadata tree:
+//
+//	/latest/meta-data/hostname
+//	/latest/meta-data/instance-id
+//	/latest/meta-data/local-ipv4
+//	/latest/meta-data/tags/<key>
+//	/latest/user-data
+//
+// The requesting VM is identified by its source IP.
+func metadataHandler(w http.ResponseWriter, r *http.Request) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	vm := metadataFindVM(ip)
+	if vm == nil {
+		log.Debug("metadata request from unknown IP: %v", ip)
+		http.Error(w, "unknown VM", http.StatusNotFound)
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+
+	switch path {
+	case "":
+		fmt.Fprintln(w, "latest")
+	case "latest":
+		fmt.Fprintln(w, "meta-data")
+		fmt.Fprintln(w, "user-data")
+	case "latest/meta-data":
+		fmt.Fprintln(w, "hostname")
+		fmt.Fprintln(w, "instance-id")
+		fmt.Fprintln(w, "local-ipv4")
+		fmt.Fprintln(w, "tags")
+	case "latest/meta-data/hostname":
+		fmt.Fprintln(w, vm.GetName())
+	case "latest/meta-data/instance-id":
+		fmt.Fprintln(w, vm.GetUUID())
+	case "latest/meta-data/local-ipv4":
+		fmt.Fprintln(w, ip)
+	case "latest/meta-data/tags":
+		keys := []string{}
+		for k := range vm.GetTags() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fmt.Fprintln(w, k)
+		}
+	case "latest/user-data":
+		// per-VM user-data, set via `vm tag`, overrides the default
+		data := vm.Tag("user-data")
+		if data == "" {
+			metadataLock.Lock()
+			data = metadataUserData
+			metadataLock.Unlock()
+		}
+
+		fmt.Fprint(w, data)
+	default:
+		if k := strings.TrimPrefix(path, "latest/meta-data/tags/"); k != path {
+			if v := vm.Tag(k); v != "" {
+				fmt.Fprintln(w, v)
+				return
+			}
+		}
+
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"io/ioutil"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var metadataCLIHandlers = []minicli.Handler{
+	{ // metadata
+		HelpShort: "serve an EC2-style metadata endpoint to VMs",
+		HelpLong: `
+Serve an EC2-style metadata HTTP endpoint on 169.254.169.254 so that stock
+cloud images can self-configure without a cloud-init ISO. "metadata serve"
+creates a host tap carrying the metadata IP on the given VLAN and starts the
+service if it isn't already running:
+
+	metadata serve LAN
+
+VMs on that VLAN can then query the usual paths:
+
+	/latest/meta-data/hostname
+	/latest/meta-data/instance-id
+	/latest/meta-data/local-ipv4
+	/latest/meta-data/tags/<key>
+	/latest/user-data
+
+The requesting VM is identified by its source IP, which minimega learns by
+snooping traffic, so a VM that has never sent a packet will get a 404.
+
+"metadata user-data" views or sets the user-data returned to VMs, read from a
+file. A VM with a "user-data" tag gets the tag's value instead:
+
+	metadata user-data /tmp/cloud-config.yaml
+
+With no arguments, metadata lists the VLANs being served. To stop the service
+and remove the taps it created, use "clear metadata".`,
+		Patterns: []string{
+			"metadata",
+			"metadata <serve,> <vlan>",
+			"metadata <serve,> <vlan> bridge <bridge>",
+			"metadata <user-data,> [file]",
+		},
+		Call: wrapSimpleCLI(cliMetadata),
+		Suggest: wrapSuggest(func(ns *Namespace, val, prefix string) []string {
+			if val == "vlan" {
+				return cliVLANSuggest(ns, prefix)
+			}
+			return nil
+		}),
+	},
+	{ // clear metadata
+		HelpShort: "stop the metadata service",
+		HelpLong: `
+Stop the metadata service and remove the host taps it created.`,
+		Patterns: []string{
+			"clear metadata",
+		},
+		Call: wrapSimpleCLI(cliMetadataClear),
+	},
+}
+
+func cliMetadata(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["serve"] {
+		vlan, err := lookupVLAN(ns.Name, c.StringArgs["vlan"])
+		if err != nil {
+			return err
+		}
+
+		tap, err := metadataServe(ns, c.StringArgs["bridge"], vlan)
+		if err != nil {
+			return err
+		}
+
+		resp.Response = tap
+		return nil
+	}
+
+	if c.BoolArgs["user-data"] {
+		if c.StringArgs["file"] == "" {
+			metadataLock.Lock()
+			defer metadataLock.Unlock()
+
+			resp.Response = metadataUserData
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(c.StringArgs["file"])
+		if err != nil {
+			return err
+		}
+
+		metadataLock.Lock()
+		defer metadataLock.Unlock()
+
+		metadataUserData = string(data)
+		return nil
+	}
+
+	// must be the list command
+	metadataLock.Lock()
+	defer metadataLock.Unlock()
+
+	resp.Header = []string{"tap", "vlan"}
+
+	for tap, vlan := range metadataTaps {
+		resp.Tabular = append(resp.Tabular, []string{
+			tap,
+			printVLAN(ns.Name, vlan),
+		})
+	}
+
+	return nil
+}
+
+func cliMetadataClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	return metadataClear(ns)
+}
@@ -116,10 +116,15 @@ func generateUUID() string {
 
 // generate a random mac address and return as a string
 func randomMac() string {
+	return randomMacPrefix(validMACPrefix)
+}
+
+// generate a random mac address using a prefix from the given list
+func randomMacPrefix(prefixes [][3]byte) string {
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	//
-	prefix := validMACPrefix[r.Intn(len(validMACPrefix))]
+	prefix := prefixes[r.Intn(len(prefixes))]
 
 	mac := fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", prefix[0], prefix[1], prefix[2], r.Intn(256), r.Intn(256), r.Intn(256))
 	log.Info("generated mac: %v", mac)
@@ -344,6 +349,12 @@ func lookupVLAN(namespace, alias string) (int, error) {
 		return vlan, err
 	}
 
+	// the alias would allocate a new VLAN -- make sure the namespace allows
+	// it so that typos don't silently create isolated VLANs
+	if !GetOrCreateNamespace(namespace).VLANAllowed(alias) {
+		return 0, fmt.Errorf("VLAN alias not allowed in namespace, see `ns allow-vlans`: %v", alias)
+	}
+
 	vlan, created, err := vlans.Allocate(namespace, alias)
 	if err != nil {
 		return 0, err
@@ -49,11 +49,22 @@ or the control socket as an indication that minimega has quit.`,
 		HelpShort: "show command help",
 		HelpLong: `
 Show help on a command. If called with no arguments, show a summary of all
-commands.`,
+commands.
+
+To search all command help for a keyword instead of looking up a command by
+name:
+
+	help -search vlan
+
+To dump the full command schema (patterns, argument types, and help text) as
+JSON, for external tools that offer completion or validation:
+
+	help -json`,
 		Patterns: []string{
 			"help [command]...",
 		},
-		Call: wrapSimpleCLI(cliHelp),
+		Call:     wrapSimpleCLI(cliHelp),
+		ReadOnly: true,
 	},
 	{ // read
 		HelpShort: "read and execute a command file",
@@ -62,6 +73,23 @@ Read a command file and execute it. This has the same behavior as if you typed
 the file in manually. read stops if it reads an invalid command. read does not
 stop if a command returns an error. Nested reads are not permitted.
 
+Command files may splice in other command files with the include directive,
+so that large experiment script trees can be modularized instead of
+concatenated by external tooling:
+
+	include common/network.mm
+
+Relative paths are resolved against the directory of the including file, not
+minimega's working directory. Includes may nest; a cycle of includes is an
+error. Includes may also be parameterized with key=value arguments, making
+the included file a reusable macro. Within the included file, occurrences of
+$key or ${key} are replaced by the given value:
+
+	include snippets/desktop.mm name=ws-1 vlan=100
+
+Parameters do not carry into files that the included file itself includes
+unless passed again, and parameter values may not contain spaces.
+
 To prevent issues with another script changing the namespace and commands being
 run in a different namespace than originally intended, read records the active
 namespace when it starts and prepends that namespace to all commands that it
@@ -122,7 +150,8 @@ With arguments, debug writes files that can be read using "go tool pprof":
 		Patterns: []string{
 			"version",
 		},
-		Call: wrapBroadcastCLI(cliVersion),
+		Call:     wrapBroadcastCLI(cliVersion),
+		ReadOnly: true,
 	},
 	{ // echo
 		HelpShort: "display input text after comment removal",
@@ -174,12 +203,31 @@ func cliQuit(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 }
 
 func cliHelp(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
-	input := ""
-	if args, ok := c.ListArgs["command"]; ok {
-		input = strings.Join(args, " ")
+	args := c.ListArgs["command"]
+
+	// flag-style arguments -- these cannot collide with command names since
+	// no command starts with a dash
+	if len(args) > 0 {
+		switch args[0] {
+		case "-search":
+			if len(args) != 2 {
+				return errors.New("-search takes exactly one keyword")
+			}
+
+			resp.Response = minicli.Search(args[1])
+			return nil
+		case "-json":
+			doc, err := minicli.Doc()
+			if err != nil {
+				return err
+			}
+
+			resp.Response = doc
+			return nil
+		}
 	}
 
-	resp.Response = minicli.Help(input)
+	resp.Response = minicli.Help(strings.Join(args, " "))
 	return nil
 }
 
@@ -191,18 +239,57 @@ func cliRead(c *minicli.Command, respChan chan<- minicli.Responses) {
 		return
 	}
 
-	ns := GetNamespace()
+	rs := &readState{
+		ns:       GetNamespace(),
+		check:    c.BoolArgs["check"],
+		respChan: respChan,
+		reading:  map[string]bool{},
+	}
+
+	if err := rs.readFile(c.StringArgs["file"], nil); err != nil {
+		resp := &minicli.Response{
+			Host:  hostname,
+			Error: err.Error(),
+		}
+		respChan <- minicli.Responses{resp}
+	}
+}
+
+// readState tracks the state shared across a read and the files it includes.
+type readState struct {
+	// ns is the namespace prepended to commands, updated when a command
+	// changes the active namespace
+	ns *Namespace
+
+	// check toggles syntax checking instead of execution
+	check bool
+
+	respChan chan<- minicli.Responses
+
+	// reading tracks the files currently being read so that a cycle of
+	// includes fails instead of looping
+	reading map[string]bool
+}
+
+// readFile reads and executes the commands in fname, splicing in included
+// files as it encounters them. params, when non-nil, holds the key=value
+// substitutions applied to each line before it is compiled.
+func (rs *readState) readFile(fname string, params map[string]string) error {
+	abs, err := filepath.Abs(fname)
+	if err != nil {
+		return err
+	}
 
-	resp := &minicli.Response{Host: hostname}
+	if rs.reading[abs] {
+		return fmt.Errorf("include cycle detected: %v", fname)
+	}
 
-	fname := c.StringArgs["file"]
-	check := c.BoolArgs["check"]
+	rs.reading[abs] = true
+	defer delete(rs.reading, abs)
 
 	file, err := os.Open(fname)
 	if err != nil {
-		resp.Error = err.Error()
-		respChan <- minicli.Responses{resp}
-		return
+		return err
 	}
 
 	defer file.Close()
@@ -214,14 +301,33 @@ func cliRead(c *minicli.Command, respChan chan<- minicli.Responses) {
 
 	for scanner.Scan() {
 		line += 1
-		var cmd *minicli.Command
 
 		command := scanner.Text()
+
+		if params != nil {
+			command = os.Expand(command, func(k string) string {
+				if v, ok := params[k]; ok {
+					return v
+				}
+
+				// not one of ours, leave it alone
+				return "$" + k
+			})
+		}
+
 		log.Debug("read command: %v", command)
 
-		cmd, err = minicli.Compile(command)
+		if fields := strings.Fields(command); len(fields) > 0 && fields[0] == "include" {
+			if err := rs.include(fname, fields[1:]); err != nil {
+				return fmt.Errorf("%v:%v %v", filepath.Base(fname), line, err)
+			}
+
+			continue
+		}
+
+		cmd, err := minicli.Compile(command)
 		if err != nil {
-			break
+			return fmt.Errorf("%v:%v %v", filepath.Base(fname), line, err)
 		}
 
 		// Must have been a blank line. Don't try to run.
@@ -231,12 +337,12 @@ func cliRead(c *minicli.Command, respChan chan<- minicli.Responses) {
 
 		// HAX: Make sure we don't have a recursive read command
 		if hasCommand(cmd, "read") {
-			err = errors.New("cannot run nested `read` commands")
-			break
+			err := errors.New("cannot run nested `read` commands")
+			return fmt.Errorf("%v:%v %v", filepath.Base(fname), line, err)
 		}
 
 		// If we're checking the syntax, don't actually execute the command
-		if check {
+		if rs.check {
 			continue
 		}
 
@@ -256,30 +362,50 @@ func cliRead(c *minicli.Command, respChan chan<- minicli.Responses) {
 			if namespace == "" {
 				// no change in namespace so recompile the command to execute in
 				// the original namespace
-				cmd = minicli.MustCompilef("namespace %q %v", ns.Name, command)
+				cmd = minicli.MustCompilef("namespace %q %v", rs.ns.Name, command)
 			}
 		}
 
-		forward(runCommands(cmd), respChan)
+		recordHistory("read", cmd)
+
+		forward(runCommands(cmd), rs.respChan)
 
 		if namespace != "" {
 			log.Info("read switching to namespace `%v`", namespace)
 
 			// update the namespace that we prepend to match the newly
 			// activated namespace
-			ns = GetOrCreateNamespace(namespace)
+			rs.ns = GetOrCreateNamespace(namespace)
 		}
 	}
 
-	if err != nil {
-		resp.Error = fmt.Sprintf("%v:%v %v", filepath.Base(fname), line, err)
-		respChan <- minicli.Responses{resp}
+	return scanner.Err()
+}
+
+// include splices in the file named by args, resolving relative paths against
+// the including file and parsing any key=value parameters.
+func (rs *readState) include(fname string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("malformed include, expected `include <file> [key=value]...`")
 	}
 
-	if err := scanner.Err(); err != nil {
-		resp.Error = err.Error()
-		respChan <- minicli.Responses{resp}
+	f := args[0]
+	if !filepath.IsAbs(f) {
+		f = filepath.Join(filepath.Dir(fname), f)
 	}
+
+	params := map[string]string{}
+
+	for _, arg := range args[1:] {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid include parameter: `%v`, expected key=value", arg)
+		}
+
+		params[parts[0]] = parts[1]
+	}
+
+	return rs.readFile(f, params)
 }
 
 func cliStatusUpdates(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
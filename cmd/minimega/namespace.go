@@ -75,9 +75,42 @@ type Namespace struct {
 	// QueuedVMs toggles whether we should queue VMs or not when launching
 	QueueVMs bool
 
+	// AutoScreenshot toggles whether we should capture a screenshot of KVM
+	// VMs when they transition to the error or quit state
+	AutoScreenshot bool
+
+	// AutoTunnel toggles whether scheduling creates overlay tunnels between
+	// hosts that a bridge spans, see `ns auto-tunnel`
+	AutoTunnel bool
+
+	// MemOvercommit is the maximum ratio of committed memory to total memory
+	// the scheduler allows per host, zero means unlimited. When
+	// MemOvercommitStrict is set, exceeding the ratio fails the schedule
+	// instead of logging a warning. See `ns mem-overcommit`.
+	MemOvercommit       float64
+	MemOvercommitStrict bool
+
+	// ReadOnly, when set, rejects commands that modify the namespace so
+	// that observers and dashboards can safely watch a live experiment. The
+	// `ns read-only` toggle itself stays allowed.
+	ReadOnly bool
+
+	// RunPath overrides the directory that holds per-VM instance
+	// directories for VMs launched in this namespace, e.g. to put overlays
+	// on fast local storage. Supports the {{namespace}} and {{id}} tokens,
+	// see `ns run-path`. Empty means the minimega base path.
+	RunPath string
+
+	// overlay meshes built by auto-tunneling, per bridge
+	autoTunnels map[string]*autoTunnel
+
 	vmConfig      VMConfig
 	savedVMConfig map[string]VMConfig
 
+	// configHistory records commands that changed vmConfig, most recent
+	// last, for `vm config history` and `vm config undo`
+	configHistory []vmConfigMutation
+
 	captures // embed captures for this namespace
 
 	routers map[int]*Router
@@ -92,6 +125,48 @@ type Namespace struct {
 
 	ccMounts map[string]ccMount
 
+	// Background traffic groups for this namespace
+	trafficGroups map[string]*TrafficGroup
+
+	// Network probes for this namespace, keyed by VM pair
+	netprobes map[string]*NetProbe
+
+	// Pairwise reachability matrix for this namespace, nil when none
+	netmatrix *NetMatrix
+
+	// Security posture reports for this namespace, keyed by VM name
+	postures map[string]*PostureReport
+
+	// Sinkhole services for this namespace, keyed by service and VLAN
+	sinkholes map[string]*sinkhole
+
+	// Detonation loops for this namespace, keyed by name
+	detonations map[string]*detonation
+
+	// Hooks maps VM lifecycle events to the scripts or webhooks to run, in
+	// the order they were attached, see `hook`
+	Hooks map[string][]string
+
+	// Scheduled daily start/stop events for this namespace's VMs
+	power *powerScheduler
+
+	// Restart policy for errored VMs, see `vm autorestart`
+	autorestart *autorestarter
+
+	// Tracks which hosts VMs were scheduled on so that VMs on hosts that
+	// drop out of the mesh can be relaunched, see `ns reconcile`
+	reconcile *reconciler
+
+	// Advisory lock taken via `ns lock` so that scripts from different
+	// operators don't interleave. Empty when unlocked.
+	lockOwner string
+	lockTime  time.Time
+
+	// Allowed VLAN aliases, set via `ns allow-vlans`. When non-empty,
+	// launching a VM with an alias not in the set fails instead of
+	// allocating a new VLAN.
+	vlanAllowlist map[string]bool
+
 	// optimizations
 	hugepagesMountPath string
 
@@ -140,6 +215,17 @@ func NewNamespace(name string) *Namespace {
 		vmConfig:      NewVMConfig(),
 		savedVMConfig: make(map[string]VMConfig),
 		ccMounts:      make(map[string]ccMount),
+		trafficGroups: make(map[string]*TrafficGroup),
+		netprobes:     make(map[string]*NetProbe),
+		postures:      make(map[string]*PostureReport),
+		sinkholes:     make(map[string]*sinkhole),
+		detonations:   make(map[string]*detonation),
+		Hooks:         make(map[string][]string),
+		autoTunnels:   make(map[string]*autoTunnel),
+		power:         newPowerScheduler(),
+		autorestart:   newAutorestarter(),
+		reconcile:     newReconciler(),
+		vlanAllowlist: make(map[string]bool),
 	}
 
 	if name == DefaultNamespace {
@@ -183,6 +269,43 @@ func (n *Namespace) String() string {
 	return n.Name
 }
 
+// VLANAllowed returns whether allocating a new VLAN for the given alias is
+// permitted. An empty allowlist permits everything.
+func (n *Namespace) VLANAllowed(alias string) bool {
+	if len(n.vlanAllowlist) == 0 {
+		return true
+	}
+
+	return n.vlanAllowlist[alias]
+}
+
+// Lock takes the advisory lock for the namespace on behalf of owner. Scripts
+// that modify shared state (e.g. vm config followed by vm launch) should take
+// the lock first so that concurrent scripts fail fast instead of interleaving.
+func (n *Namespace) Lock(owner string) error {
+	if n.lockOwner != "" {
+		return fmt.Errorf("namespace locked by %v since %v", n.lockOwner, n.lockTime.Format(time.RFC1123))
+	}
+
+	n.lockOwner = owner
+	n.lockTime = time.Now()
+
+	return nil
+}
+
+// Unlock releases the advisory lock for the namespace. The lock is advisory
+// so we cannot tell who is asking -- any operator may unlock.
+func (n *Namespace) Unlock() error {
+	if n.lockOwner == "" {
+		return errors.New("namespace is not locked")
+	}
+
+	n.lockOwner = ""
+	n.lockTime = time.Time{}
+
+	return nil
+}
+
 func (n *Namespace) Destroy() error {
 	log.Info("destroying namespace: %v", n.Name)
 
@@ -208,6 +331,30 @@ func (n *Namespace) Destroy() error {
 	n.Recorder.Clear()
 	n.Player.Clear()
 
+	// Stop and delete all traffic groups
+	n.ClearTraffic(Wildcard)
+
+	// Delete all netprobes
+	n.ClearNetProbes(Wildcard)
+
+	// Delete the reachability matrix
+	n.ClearNetMatrix()
+
+	// Delete all posture reports
+	n.ClearPostures(Wildcard)
+
+	// Stop all sinkholes
+	n.ClearSinkholes(Wildcard)
+
+	// Abort all detonations
+	n.ClearDetonations(Wildcard)
+
+	// Stop the power scheduler
+	n.power.Destroy()
+
+	// Stop the autorestarter
+	n.autorestart.Destroy()
+
 	// Kill and flush all the VMs
 	n.Kill(Wildcard)
 	n.FlushAll(n.ccServer)
@@ -379,7 +526,15 @@ func (n *Namespace) Schedule(dryRun bool) error {
 	}
 
 	// Create the host -> VMs assignment
-	assignment, err := schedule(n.queue, hostStats, hostSorter)
+	s := &Scheduler{
+		queue:         n.queue,
+		hosts:         hostStats,
+		hostSortBy:    hostSorter,
+		memOvercommit: n.MemOvercommit,
+		memStrict:     n.MemOvercommitStrict,
+	}
+
+	assignment, err := s.Schedule()
 	if err != nil {
 		return err
 	}
@@ -408,6 +563,14 @@ func (n *Namespace) schedule(assignment map[string][]*QueuedVMs) error {
 		state: SchedulerRunning,
 	}
 
+	// mesh the involved hosts together before launching so that VMs come up
+	// with cross-host networking in place
+	if n.AutoTunnel {
+		if err := n.autoTunnel(assignment); err != nil {
+			log.Error("auto-tunnel: %v", err)
+		}
+	}
+
 	n.scheduleStats = append(n.scheduleStats, stats)
 
 	// Result of vm launch commands
@@ -464,6 +627,92 @@ func (n *Namespace) schedule(assignment map[string][]*QueuedVMs) error {
 	return nil
 }
 
+// ScheduleCanary carves a single canary VM off the front of the queue,
+// launches and starts it, and waits for it to check in via cc before
+// launching the remaining VMs. If the canary errors out, quits, or does not
+// check in before the timeout, the remaining VMs are left queued so that the
+// user can fix the image and try again. Blocks until the canary is ready and
+// the rest of the queue is in-flight.
+func (n *Namespace) ScheduleCanary(timeout time.Duration) error {
+	if len(n.queue) == 0 {
+		return errors.New("namespace must contain at least one queued VM to launch VMs")
+	}
+
+	// invalidate any dry-run assignment, we are about to change the queue
+	n.assignment = nil
+
+	q := n.queue[0]
+	if q.Names[0] == "" {
+		// assign a name now so that we can find the canary later
+		q.Names[0] = fmt.Sprintf("vm-%v", n.vmID.Next())
+	}
+	name := q.Names[0]
+
+	canary := *q
+	canary.Names = []string{name}
+
+	remaining := n.queue
+	q.Names = q.Names[1:]
+	if len(q.Names) == 0 {
+		remaining = remaining[1:]
+	}
+
+	// schedule clears the queue so stash the remainder until the canary
+	// checks in
+	n.queue = []*QueuedVMs{&canary}
+	if err := n.Schedule(false); err != nil {
+		n.queue = remaining
+		return err
+	}
+
+	// VMs launch in a paused state -- start the canary so the guest can boot
+	if err := n.Start(name); err != nil {
+		n.queue = remaining
+		return err
+	}
+
+	log.Info("waiting up to %v for canary %v to check in", timeout, name)
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		var state VMState
+		var ready bool
+
+		for _, vm := range globalVMs(n) {
+			if vm.GetName() == name {
+				state = vm.GetState()
+				ready = vm.HasCC()
+			}
+		}
+
+		if ready {
+			break
+		}
+
+		if state&(VM_QUIT|VM_ERROR) != 0 {
+			n.queue = remaining
+			return fmt.Errorf("canary %v is in state %v, remaining VMs left queued", name, state)
+		}
+
+		if time.Now().After(deadline) {
+			n.queue = remaining
+			return fmt.Errorf("canary %v did not check in within %v, remaining VMs left queued", name, timeout)
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	log.Info("canary %v checked in, launching remaining VMs", name)
+
+	n.queue = remaining
+	if len(n.queue) == 0 {
+		return nil
+	}
+
+	return n.Schedule(false)
+}
+
 // Reschedule
 func (n *Namespace) Reschedule(target, dst string) error {
 	if n.assignment == nil {
@@ -521,6 +770,31 @@ Outer:
 func (n *Namespace) Launch(requestor string, q *QueuedVMs) []error {
 	// collect all the errors
 	errs := []error{}
+
+	// fire pre-launch hooks, which may veto individual VMs
+	if len(n.Hooks[HookPreLaunch]) > 0 {
+		names := []string{}
+
+		for _, name := range q.Names {
+			data := &hookData{
+				Event:     HookPreLaunch,
+				Name:      name,
+				Namespace: n.Name,
+				Type:      q.VMType.String(),
+				Tags:      q.VMConfig.Tags,
+			}
+
+			if err := n.RunHooks(HookPreLaunch, data); err != nil {
+				errs = append(errs, fmt.Errorf("%v not launched: %v", name, err))
+				continue
+			}
+
+			names = append(names, name)
+		}
+
+		q.Names = names
+	}
+
 	for err := range n.VMs.Launch(requestor, n.Name, q) {
 		errs = append(errs, err)
 	}
@@ -528,6 +802,28 @@ func (n *Namespace) Launch(requestor string, q *QueuedVMs) []error {
 	return errs
 }
 
+// Kill VMs matching target, firing any pre-kill hooks first. Hook failures
+// are logged rather than blocking the kill -- a misbehaving hook must not
+// leave VMs unkillable.
+func (n *Namespace) Kill(target string) error {
+	return n.VMs.Apply(target, func(vm VM, _ bool) (bool, error) {
+		if vm.GetState()&VM_KILLABLE == 0 {
+			return false, nil
+		}
+
+		if err := n.RunHooks(HookPreKill, vmHookData(HookPreKill, vm)); err != nil {
+			log.Error("%v: %v", vm.GetName(), err)
+		}
+
+		if err := vm.Kill(); err != nil {
+			log.Error("unleash the zombie VM: %v", err)
+			return true, err
+		}
+
+		return true, nil
+	})
+}
+
 // NewCommand takes a command, adds the current filter and prefix, and then
 // sends the command to ron.
 func (ns *Namespace) NewCommand(c *ron.Command) int {
@@ -544,6 +840,9 @@ func (ns *Namespace) NewCommand(c *ron.Command) int {
 func (n *Namespace) hostLaunch(host string, queued *QueuedVMs, respChan chan<- minicli.Responses) {
 	log.Info("scheduling %v %v VMs on %v", len(queued.Names), queued.VMType, host)
 
+	// remember the placement in case the host later drops out of the mesh
+	n.recordLaunch(host, queued)
+
 	// Launching the VMs locally
 	if host == hostname {
 		resp := &minicli.Response{
@@ -563,6 +862,27 @@ func (n *Namespace) hostLaunch(host string, queued *QueuedVMs, respChan chan<- m
 	forward(meshageLaunch(host, n.Name, queued), respChan)
 }
 
+// InstancePath returns the directory that holds instance data for a VM ID in
+// this namespace. The default layout is <base>/<id>. When RunPath is set, the
+// {{namespace}} and {{id}} tokens are expanded; if RunPath does not contain
+// {{id}}, the VM ID is appended as a final path element.
+func (n *Namespace) InstancePath(id int) string {
+	if n.RunPath == "" {
+		return filepath.Join(*f_base, strconv.Itoa(id))
+	}
+
+	path := strings.NewReplacer(
+		"{{namespace}}", n.Name,
+		"{{id}}", strconv.Itoa(id),
+	).Replace(n.RunPath)
+
+	if !strings.Contains(n.RunPath, "{{id}}") {
+		path = filepath.Join(path, strconv.Itoa(id))
+	}
+
+	return path
+}
+
 // hostSlice converts the hosts map into a slice of hostnames
 func (n *Namespace) hostSlice() []string {
 	hosts := []string{}
@@ -639,6 +959,14 @@ func (n *Namespace) parseVMNets(vals []string) ([]NetConfig, error) {
 			return nil, err
 		}
 
+		// ParseNetConfig only uses the driver list to disambiguate the spec;
+		// also make sure the driver it settled on (possibly the default) is
+		// one that the local QEMU actually provides
+		if len(nics) > 0 && !nics[nic.Driver] {
+			n.vmConfig.Networks = NetConfigs{}
+			return nil, fmt.Errorf("invalid NIC driver: `%v`, see help", nic.Driver)
+		}
+
 		nic.VLAN = vlan
 		nic.Raw = spec
 		res = append(res, *nic)
@@ -725,6 +1053,19 @@ func (n *Namespace) Save(dir string) error {
 
 	fmt.Fprintf(f, "namespace %q\n\n", n.Name)
 	fmt.Fprintf(f, "ns queueing %v\n\n", n.QueueVMs)
+	fmt.Fprintf(f, "ns auto-screenshot %v\n\n", n.AutoScreenshot)
+	fmt.Fprintf(f, "ns auto-tunnel %v\n\n", n.AutoTunnel)
+	fmt.Fprintf(f, "ns mem-overcommit %v\n\n", n.MemOvercommit)
+	fmt.Fprintf(f, "ns mem-overcommit strict %v\n\n", n.MemOvercommitStrict)
+	if n.RunPath != "" {
+		fmt.Fprintf(f, "ns run-path %v\n\n", n.RunPath)
+	}
+
+	for _, event := range hookEvents {
+		for _, target := range n.Hooks[event] {
+			fmt.Fprintf(f, "hook %v %v\n\n", event, target)
+		}
+	}
 
 	// pause all vms
 	var respChan <-chan minicli.Responses
@@ -825,6 +1166,217 @@ func (n *Namespace) Save(dir string) error {
 	fmt.Fprintf(f, "vm stop all\n")
 	fmt.Fprintf(f, "vm start all\n")
 
+	// last so that the replay itself is not rejected
+	if n.ReadOnly {
+		fmt.Fprintf(f, "ns read-only true\n")
+	}
+
+	return nil
+}
+
+// CloneNamespace replicates the src namespace's settings, VLAN aliases, and
+// VM configurations into a newly created namespace so that the same scenario
+// can run in parallel. VMs, both queued and launched, are queued in the clone
+// rather than launched so that the clone can be scheduled independently. When
+// freshDisks is set, disk images referenced by the cloned configs are copied
+// so that the clone does not share writable disk state with the original.
+func CloneNamespace(src, dst string, freshDisks bool) error {
+	if !validName.MatchString(dst) {
+		return fmt.Errorf("%v: `%v`", validNameErr, dst)
+	}
+
+	namespaceLock.Lock()
+
+	n, ok := namespaces[src]
+	if !ok {
+		namespaceLock.Unlock()
+		return fmt.Errorf("unknown namespace: `%v`", src)
+	}
+
+	if _, ok := namespaces[dst]; ok {
+		namespaceLock.Unlock()
+		return fmt.Errorf("namespace already exists: `%v`", dst)
+	}
+
+	n2 := NewNamespace(dst)
+	namespaces[dst] = n2
+
+	// release before touching VLANs -- allocation takes namespaceLock again
+	namespaceLock.Unlock()
+
+	// settings
+	for host := range n.Hosts {
+		n2.Hosts[host] = true
+	}
+	n2.HostSortBy = n.HostSortBy
+	n2.QueueVMs = n.QueueVMs
+	n2.AutoScreenshot = n.AutoScreenshot
+	n2.AutoTunnel = n.AutoTunnel
+	n2.MemOvercommit = n.MemOvercommit
+	n2.MemOvercommitStrict = n.MemOvercommitStrict
+	n2.RunPath = n.RunPath
+
+	for event, targets := range n.Hooks {
+		n2.Hooks[event] = append([]string{}, targets...)
+	}
+
+	if n.vlanAllowlist != nil {
+		n2.vlanAllowlist = map[string]bool{}
+		for alias := range n.vlanAllowlist {
+			n2.vlanAllowlist[alias] = true
+		}
+	}
+
+	n2.vmConfig = n.vmConfig.Copy()
+	for name, config := range n.savedVMConfig {
+		n2.savedVMConfig[name] = config.Copy()
+	}
+
+	// replicate the VLAN aliases so that the clone's networks come up on its
+	// own, freshly allocated VLANs
+	prefix := src + vlans.AliasSep
+	for _, alias := range vlans.GetAliases(prefix) {
+		if _, err := lookupVLAN(dst, strings.TrimPrefix(alias, prefix)); err != nil {
+			return err
+		}
+	}
+
+	// requeue the already queued VMs
+	for _, q := range n.queue {
+		q2 := &QueuedVMs{
+			Names:    append([]string{}, q.Names...),
+			VMType:   q.VMType,
+			VMConfig: q.VMConfig.Copy(),
+		}
+
+		if err := n2.adoptConfig(&q2.VMConfig, false); err != nil {
+			return err
+		}
+
+		n2.queue = append(n2.queue, q2)
+	}
+
+	// queue a copy of every launched VM
+	err := n.VMs.Apply(Wildcard, func(vm VM, _ bool) (bool, error) {
+		config, err := vmConfigFor(vm)
+		if err != nil {
+			return true, err
+		}
+
+		if err := n2.adoptConfig(&config, true); err != nil {
+			return true, err
+		}
+
+		n2.queue = append(n2.queue, &QueuedVMs{
+			Names:    []string{vm.GetName()},
+			VMType:   vm.GetType(),
+			VMConfig: config,
+		})
+
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if freshDisks {
+		if err := n2.copyDisks(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// vmConfigFor reconstructs the VMConfig that was used to launch the VM.
+func vmConfigFor(vm VM) (VMConfig, error) {
+	var config VMConfig
+
+	switch vm := vm.(type) {
+	case *KvmVM:
+		config.BaseConfig = vm.BaseConfig.Copy()
+		config.KVMConfig = vm.KVMConfig.Copy()
+	case *ContainerVM:
+		config.BaseConfig = vm.BaseConfig.Copy()
+		config.ContainerConfig = vm.ContainerConfig.Copy()
+	case *FirecrackerVM:
+		config.BaseConfig = vm.BaseConfig.Copy()
+		config.FirecrackerConfig = vm.FirecrackerConfig.Copy()
+	default:
+		return config, fmt.Errorf("unable to clone vm of type %v", vm.GetType())
+	}
+
+	return config, nil
+}
+
+// adoptConfig rewrites a cloned VMConfig so that it belongs to the namespace:
+// per-instance network state is dropped and VLAN aliases are resolved against
+// the namespace's own VLANs. When the config came from a launched VM, the
+// UUID and generated MACs are cleared so that the clone gets fresh ones.
+func (n *Namespace) adoptConfig(config *VMConfig, launched bool) error {
+	if launched {
+		config.UUID = ""
+
+		for i := range config.Disks {
+			config.Disks[i].SnapshotPath = ""
+		}
+	}
+
+	for i := range config.Networks {
+		nic := &config.Networks[i]
+
+		nic.Tap = ""
+		nic.IP4, nic.IP6 = "", ""
+		nic.RxRate, nic.TxRate = 0, 0
+
+		if launched {
+			nic.MAC = ""
+		}
+
+		vlan, err := lookupVLAN(n.Name, nic.Alias)
+		if err != nil {
+			return err
+		}
+		nic.VLAN = vlan
+	}
+
+	return nil
+}
+
+// copyDisks copies the disk images referenced by the namespace's queued VM
+// configs into a namespace-specific directory and points the configs at the
+// copies, see `ns clone`.
+func (n *Namespace) copyDisks() error {
+	dir := filepath.Join(*f_base, "clones", n.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// the same image may back several VMs -- copy it once
+	copies := map[string]string{}
+
+	for i, q := range n.queue {
+		for j := range q.VMConfig.Disks {
+			disk := &q.VMConfig.Disks[j]
+			if disk.Path == "" {
+				continue
+			}
+
+			if _, ok := copies[disk.Path]; !ok {
+				// disambiguate images from different paths that share a name
+				dst := filepath.Join(dir, fmt.Sprintf("%v_%v_%v", i, j, filepath.Base(disk.Path)))
+
+				if out, err := processWrapper("cp", "-f", disk.Path, dst); err != nil {
+					return fmt.Errorf("copy disk %v: %v: %v", disk.Path, err, out)
+				}
+
+				copies[disk.Path] = dst
+			}
+
+			disk.Path = copies[disk.Path]
+		}
+	}
+
 	return nil
 }
 
@@ -10,6 +10,9 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -52,6 +55,8 @@ Display or modify the active namespace.
 - hosts     : list hosts
 - add-hosts : add comma-separated list of hosts to the namespace
 - del-hosts : delete comma-separated list of hosts from the namespace
+- bootstrap : deploy minimega to hosts over SSH and add them once they join
+  the mesh
 - load      : display or change host load is computed for scheduler, based on:
   - cpucommit : total CPU commit divided by number of CPUs (default)
   - netcommit : total NIC
@@ -59,6 +64,17 @@ Display or modify the active namespace.
 - queue     : display VM queue
 - flush     : clear the VM queue
 - queueing  : toggle VMs queueing when launching (default false)
+- auto-screenshot : toggle screenshots of KVM VMs that error or quit (default
+  false), see "vm crashreport"
+- auto-tunnel : toggle automatic GRE tunnels between hosts (default false)
+- mem-overcommit : display or set the maximum ratio of committed to total
+  memory per host when scheduling (default 0, unlimited)
+  - strict : toggle refusing to schedule instead of warning (default false)
+- read-only : toggle rejecting commands that modify the namespace (default
+  false)
+- run-path  : display or set the directory that holds per-VM instance
+  directories (default the minimega base path)
+- reconcile : list VMs lost to dead hosts, or relaunch them elsewhere
 - schedule  : run scheduler (same as "vm launch")
   - dry-run : determine VM placement and print out VM -> host assignments
   - dump    : print out VM -> host assignments (after dry-run)
@@ -68,12 +84,104 @@ Display or modify the active namespace.
 - del-bridge: destroy a bridge
 - snapshot  : **DEPRECATED**: Use 'ns save'
 - save      : Save all VMs in the namespace or print save progress
+- clone     : replicate a namespace's settings, VLAN aliases, and VM configs
+  into a new namespace
 - run       : run a command on all nodes in the namespace
+- lock      : take the advisory lock for the namespace
+- unlock    : release the advisory lock for the namespace
+- allow-vlans    : display or add to the set of allowed VLAN aliases
+- disallow-vlans : remove aliases from the set of allowed VLAN aliases
+
+When the set of allowed VLAN aliases is non-empty, launching a VM with a VLAN
+alias outside the set fails instead of silently allocating a new, isolated
+VLAN. Declare the aliases an experiment uses up front so that typos in network
+specs fail at "vm launch". Aliases are comma-separated and may use range
+expressions (e.g. "dmz,lan[0-4]"). Use "disallow-vlans all" to clear the set
+and disable the check.
+
+When auto-tunnel is enabled, scheduling VMs across hosts automatically
+creates a GRE mesh between the involved hosts for each bridge in use, so
+multi-host experiments work without a shared trunk or manual "ns bridge"
+setup. The tunnels carry every VLAN on the bridge and are torn down when the
+namespace is cleared.
+
+The scheduler tracks the memory each host has committed to VMs, which for
+large launches can far exceed what the host can actually back. When
+mem-overcommit is non-zero, a host whose committed memory would exceed that
+multiple of its total memory logs a warning during scheduling, or fails the
+schedule when strict is enabled, so overcommit is caught at "vm launch"
+instead of by the OOM killer:
+
+	ns mem-overcommit 1.5
+	ns mem-overcommit strict true
+
+bootstrap replaces external deploy scripts for growing a cluster: it copies
+the running minimega binary to each host with scp, launches it with the same
+flags as this instance (including -degree and -port, see "deploy" to
+override), waits for the host to join the mesh, and then adds it to the
+namespace. Hosts already in the mesh are added directly. The result is
+reported per host so partial failures are visible:
+
+	ns bootstrap kn[1-50]
+	ns bootstrap kn[1-50] user sudo
+
+Note: this instance must have been started with a non-zero -degree so that
+the remote instances, which inherit the flags, dial into the mesh.
+
+When read-only is enabled, commands that modify the namespace are rejected
+and only commands that report state (e.g. "vm info", "host") are allowed, so
+observers and dashboards can watch a live production experiment without
+risking it. "ns read-only false" is always allowed so that the namespace can
+be made writable again. See also "minimega -attach -readonly", which applies
+the same restriction to everything sent over an attached command line.
+
+run-path changes where VMs launched in this namespace keep their instance
+data (overlays, sockets, logs), e.g. to put disk overlays on fast local NVMe
+or to keep experiments on separate storage. The path may contain the
+{{namespace}} and {{id}} tokens; when {{id}} is absent, the VM ID is appended
+as a final path element. Use "default" to return to the minimega base path.
+The setting only affects VMs launched after it is set and applies on every
+host in the namespace, so the path must exist or be creatable everywhere:
+
+	ns run-path /nvme/minimega/{{namespace}}
+
+Note: "minimega -recover" only scans the base path, so VMs under a custom
+run-path are not recovered.
+
+minimega watches the mesh for hosts that disappear. When a host that VMs were
+scheduled on drops out of the mesh and stays gone, those VMs are marked as
+lost (firing any vm-lost hooks, see "hook"). "ns reconcile" lists the lost
+VMs; "ns reconcile launch" removes the dead hosts from the namespace, requeues
+the lost VMs from the configs they were originally launched with, and runs the
+scheduler to place them on the remaining hosts. If a presumed-dead host
+rejoins the mesh, e.g. after a network partition, its VMs are unmarked. Note
+that relaunched VMs boot fresh from their configured disks -- any state the
+lost VMs had is gone with their host.
+
+clone replicates a scenario so that multiple teams can run it in parallel:
+the source namespace's settings, VLAN aliases, and VM configurations are
+copied into a newly created namespace, with all VMs (queued or launched)
+queued in the clone so that it can be scheduled independently. VLAN aliases
+resolve to the clone's own VLANs so the two experiments stay isolated. With
+fresh-disks, disk images referenced by the cloned configs are also copied so
+that the clone does not share writable disk state with the original:
+
+	ns clone demo demo-red
+	ns clone demo demo-blue fresh-disks
+
+The lock is advisory -- scripts that modify shared state (e.g. vm config
+followed by vm launch) should start with "ns lock" and end with "ns unlock" so
+that scripts run by other operators against the same namespace fail fast
+instead of interleaving. The optional owner is recorded and reported to anyone
+who finds the namespace locked. "ns lock" with no owner displays the current
+lock status.
 `,
 		Patterns: []string{
 			"ns <hosts,>",
 			"ns <add-hosts,> <hostname or range or all>",
 			"ns <del-hosts,> <hostname or range or all>",
+			"ns <bootstrap,> <hosts>",
+			"ns <bootstrap,> <hosts> <user> [sudo,]",
 			"ns <load,>",
 			"ns <load,> <cpucommit,>",
 			"ns <load,> <netcommit,>",
@@ -81,6 +189,13 @@ Display or modify the active namespace.
 			"ns <queue,>",
 			"ns <flush,>",
 			"ns <queueing,> [true,false]",
+			"ns <auto-screenshot,> [true,false]",
+			"ns <auto-tunnel,> [true,false]",
+			"ns <mem-overcommit,> [limit]",
+			"ns <mem-overcommit,> <strict,> [true,false]",
+			"ns <read-only,> [true,false]",
+			"ns <run-path,> [path]",
+			"ns <reconcile,> [launch,]",
 			"ns <schedule,>",
 			"ns <schedule,> <dry-run,>",
 			"ns <schedule,> <dump,>",
@@ -90,7 +205,12 @@ Display or modify the active namespace.
 			"ns <del-bridge,> <bridge>",
 			"ns <snapshot,> [name]",
 			"ns <save,> [name]",
+			"ns <clone,> <src> <dst> [fresh-disks,]",
 			"ns <run,> (command)",
+			"ns <lock,> [owner]",
+			"ns <unlock,>",
+			"ns <allow-vlans,> [alias or range]",
+			"ns <disallow-vlans,> <alias or range or all>",
 		},
 		Call: cliNS,
 		Suggest: wrapSuggest(func(_ *Namespace, val, prefix string) []string {
@@ -125,19 +245,31 @@ any remote state as well.`,
 
 // Functions pointers to the various handlers for the subcommands
 var nsCliHandlers = map[string]minicli.CLIFunc{
-	"hosts":      wrapSimpleCLI(cliNamespaceHosts),
-	"add-hosts":  wrapSimpleCLI(cliNamespaceAddHost),
-	"del-hosts":  wrapSimpleCLI(cliNamespaceDelHost),
-	"load":       wrapSimpleCLI(cliNamespaceLoad),
-	"queue":      wrapSimpleCLI(cliNamespaceQueue),
-	"queueing":   wrapSimpleCLI(cliNamespaceQueueing),
-	"flush":      wrapSimpleCLI(cliNamespaceFlush),
-	"schedule":   wrapSimpleCLI(cliNamespaceSchedule),
-	"bridge":     wrapSimpleCLI(cliNamespaceBridge),
-	"del-bridge": wrapSimpleCLI(cliNamespaceDelBridge),
-	"snapshot":   cliNamespaceSave,
-	"save":       cliNamespaceSave,
-	"run":        cliNamespaceRun,
+	"hosts":           wrapSimpleCLI(cliNamespaceHosts),
+	"add-hosts":       wrapSimpleCLI(cliNamespaceAddHost),
+	"del-hosts":       wrapSimpleCLI(cliNamespaceDelHost),
+	"bootstrap":       wrapSimpleCLI(cliNamespaceBootstrap),
+	"load":            wrapSimpleCLI(cliNamespaceLoad),
+	"queue":           wrapSimpleCLI(cliNamespaceQueue),
+	"queueing":        wrapSimpleCLI(cliNamespaceQueueing),
+	"auto-screenshot": wrapSimpleCLI(cliNamespaceAutoScreenshot),
+	"auto-tunnel":     wrapSimpleCLI(cliNamespaceAutoTunnel),
+	"mem-overcommit":  wrapSimpleCLI(cliNamespaceMemOvercommit),
+	"read-only":       wrapSimpleCLI(cliNamespaceReadOnly),
+	"run-path":        wrapSimpleCLI(cliNamespaceRunPath),
+	"reconcile":       wrapSimpleCLI(cliNamespaceReconcile),
+	"flush":           wrapSimpleCLI(cliNamespaceFlush),
+	"schedule":        wrapSimpleCLI(cliNamespaceSchedule),
+	"bridge":          wrapSimpleCLI(cliNamespaceBridge),
+	"del-bridge":      wrapSimpleCLI(cliNamespaceDelBridge),
+	"snapshot":        cliNamespaceSave,
+	"save":            cliNamespaceSave,
+	"clone":           wrapSimpleCLI(cliNamespaceClone),
+	"run":             cliNamespaceRun,
+	"lock":            wrapSimpleCLI(cliNamespaceLock),
+	"unlock":          wrapSimpleCLI(cliNamespaceUnlock),
+	"allow-vlans":     wrapSimpleCLI(cliNamespaceAllowVLANs),
+	"disallow-vlans":  wrapSimpleCLI(cliNamespaceDisallowVLANs),
 }
 
 func cliNamespace(c *minicli.Command, respChan chan<- minicli.Responses) {
@@ -217,6 +349,26 @@ func cliNS(c *minicli.Command, respChan chan<- minicli.Responses) {
 	}
 }
 
+func cliNamespaceLock(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	owner := c.StringArgs["owner"]
+	if owner == "" {
+		// no owner -- display the current lock status
+		if ns.lockOwner == "" {
+			resp.Response = "unlocked"
+		} else {
+			resp.Response = fmt.Sprintf("locked by %v since %v", ns.lockOwner, ns.lockTime.Format(time.RFC1123))
+		}
+
+		return nil
+	}
+
+	return ns.Lock(owner)
+}
+
+func cliNamespaceUnlock(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	return ns.Unlock()
+}
+
 func cliNamespaceHosts(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	resp.Response = ranges.UnsplitList(ns.hostSlice())
 	return nil
@@ -287,6 +439,152 @@ func cliNamespaceDelHost(ns *Namespace, c *minicli.Command, resp *minicli.Respon
 	return nil
 }
 
+// bootstrapJoinTimeout is how long "ns bootstrap" waits for a freshly
+// launched host to join the mesh.
+const bootstrapJoinTimeout = 30 * time.Second
+
+func cliNamespaceBootstrap(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	hosts, err := ranges.SplitList(c.StringArgs["hosts"])
+	if err != nil {
+		return fmt.Errorf("invalid hosts -- %v", err)
+	}
+
+	user := c.StringArgs["user"]
+	sudo := c.BoolArgs["sudo"]
+
+	peers := map[string]bool{}
+	for _, peer := range meshageNode.BroadcastRecipients() {
+		peers[peer] = true
+	}
+
+	// per-host outcome, reported even when some hosts fail
+	status := map[string]string{}
+
+	// same naming scheme as "deploy launch" so the latest binary is easy to
+	// identify on the remote system
+	fname := fmt.Sprintf("minimega_deploy_%v", time.Now().Unix())
+	remotePath := filepath.Join(os.TempDir(), fname)
+
+	// hosts that were launched and should eventually join the mesh
+	var pending []string
+
+	for _, host := range hosts {
+		if host == Localhost {
+			host = hostname
+		}
+
+		if host == hostname || peers[host] {
+			ns.Hosts[host] = true
+			status[host] = "already in mesh"
+			continue
+		}
+
+		if err := deployCopyHost(host, user, remotePath); err != nil {
+			status[host] = fmt.Sprintf("copy failed: %v", err)
+			continue
+		}
+
+		if err := deployRunHost(host, user, remotePath, sudo); err != nil {
+			status[host] = fmt.Sprintf("launch failed: %v", err)
+			continue
+		}
+
+		pending = append(pending, host)
+	}
+
+	// wait for the launched hosts to dial into the mesh
+	for deadline := time.Now().Add(bootstrapJoinTimeout); len(pending) > 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Second)
+
+		joined := map[string]bool{}
+		for _, peer := range meshageNode.BroadcastRecipients() {
+			joined[peer] = true
+		}
+
+		var still []string
+		for _, host := range pending {
+			if joined[host] {
+				ns.Hosts[host] = true
+				status[host] = "joined"
+			} else {
+				still = append(still, host)
+			}
+		}
+
+		pending = still
+	}
+
+	for _, host := range pending {
+		status[host] = fmt.Sprintf("did not join mesh within %v", bootstrapJoinTimeout)
+	}
+
+	resp.Header = []string{"host", "status"}
+
+	names := []string{}
+	for host := range status {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+
+	var failed int
+	for _, host := range names {
+		if !ns.Hosts[host] {
+			failed += 1
+		}
+
+		resp.Tabular = append(resp.Tabular, []string{host, status[host]})
+	}
+
+	// the error would mask the per-host table so just log the count
+	if failed > 0 {
+		log.Error("bootstrap failed on %v/%v hosts", failed, len(names))
+	}
+
+	return nil
+}
+
+func cliNamespaceAllowVLANs(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.StringArgs["alias"] == "" {
+		// no aliases -- display the current allowlist
+		aliases := []string{}
+		for alias := range ns.vlanAllowlist {
+			aliases = append(aliases, alias)
+		}
+
+		resp.Response = ranges.UnsplitList(aliases)
+		return nil
+	}
+
+	aliases, err := ranges.SplitList(c.StringArgs["alias"])
+	if err != nil {
+		return fmt.Errorf("invalid aliases -- %v", err)
+	}
+
+	for _, alias := range aliases {
+		ns.vlanAllowlist[alias] = true
+	}
+
+	return nil
+}
+
+func cliNamespaceDisallowVLANs(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	aliases, err := ranges.SplitList(c.StringArgs["alias"])
+	if err != nil {
+		return fmt.Errorf("invalid aliases -- %v", err)
+	}
+
+	for _, alias := range aliases {
+		if alias == Wildcard {
+			ns.vlanAllowlist = map[string]bool{}
+			break
+		}
+
+		delete(ns.vlanAllowlist, alias)
+	}
+
+	return nil
+}
+
 func cliNamespaceLoad(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	// check if we're updating the sort by func
 	for k := range hostSortByFns {
@@ -340,6 +638,103 @@ func cliNamespaceQueueing(ns *Namespace, c *minicli.Command, resp *minicli.Respo
 	return nil
 }
 
+func cliNamespaceAutoScreenshot(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["true"] || c.BoolArgs["false"] {
+		ns.AutoScreenshot = c.BoolArgs["true"]
+	} else {
+		resp.Response = strconv.FormatBool(ns.AutoScreenshot)
+	}
+
+	return nil
+}
+
+func cliNamespaceAutoTunnel(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["true"] || c.BoolArgs["false"] {
+		ns.AutoTunnel = c.BoolArgs["true"]
+	} else {
+		resp.Response = strconv.FormatBool(ns.AutoTunnel)
+	}
+
+	return nil
+}
+
+func cliNamespaceReadOnly(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["true"] || c.BoolArgs["false"] {
+		ns.ReadOnly = c.BoolArgs["true"]
+	} else {
+		resp.Response = strconv.FormatBool(ns.ReadOnly)
+	}
+
+	return nil
+}
+
+func cliNamespaceMemOvercommit(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["strict"] {
+		if c.BoolArgs["true"] || c.BoolArgs["false"] {
+			ns.MemOvercommitStrict = c.BoolArgs["true"]
+		} else {
+			resp.Response = strconv.FormatBool(ns.MemOvercommitStrict)
+		}
+
+		return nil
+	}
+
+	if v, ok := c.StringArgs["limit"]; ok {
+		limit, err := strconv.ParseFloat(v, 64)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid limit: `%v`", v)
+		}
+
+		ns.MemOvercommit = limit
+		return nil
+	}
+
+	resp.Response = strconv.FormatFloat(ns.MemOvercommit, 'f', -1, 64)
+	return nil
+}
+
+func cliNamespaceRunPath(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	path, ok := c.StringArgs["path"]
+	if !ok {
+		if ns.RunPath == "" {
+			resp.Response = *f_base
+		} else {
+			resp.Response = ns.RunPath
+		}
+
+		return nil
+	}
+
+	if path == "default" {
+		ns.RunPath = ""
+		return nil
+	}
+
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("expected absolute path: `%v`", path)
+	}
+
+	ns.RunPath = path
+	return nil
+}
+
+func cliNamespaceReconcile(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["launch"] {
+		count, err := ns.Reconcile()
+		if err != nil {
+			return err
+		}
+
+		resp.Response = fmt.Sprintf("relaunched %v VMs", count)
+		return nil
+	}
+
+	resp.Header = []string{"host", "name", "type", "state"}
+	resp.Tabular = ns.LostVMs()
+
+	return nil
+}
+
 func cliNamespaceFlush(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	ns.queue = nil
 	return nil
@@ -592,6 +987,12 @@ func cliClearNamespace(c *minicli.Command, respChan chan<- minicli.Responses) {
 			respChan <- errResp(err)
 			return
 		}
+
+		// clean up any tunnels that auto-tunneling created
+		if err := ns.clearAutoTunnels(); err != nil {
+			respChan <- errResp(err)
+			return
+		}
 	}
 
 	// destroy the namespace locally first
@@ -634,6 +1035,10 @@ func cliClearNamespace(c *minicli.Command, respChan chan<- minicli.Responses) {
 	respChan <- minicli.Responses{resp}
 }
 
+func cliNamespaceClone(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	return CloneNamespace(c.StringArgs["src"], c.StringArgs["dst"], c.BoolArgs["fresh-disks"])
+}
+
 func cliNamespaceRun(c *minicli.Command, respChan chan<- minicli.Responses) {
 	// HAX: prevent running as a subcommand
 	if c.Source == SourceMeshage {
@@ -25,6 +25,21 @@ func init() {
 	}
 }
 
+// macVendorPrefixes returns the MAC prefixes registered to vendors whose name
+// contains vendor, matched case-insensitively.
+func macVendorPrefixes(vendor string) [][3]byte {
+	vendor = strings.ToLower(vendor)
+
+	var res [][3]byte
+	for k, v := range macs.ValidMACPrefixMap {
+		if strings.Contains(strings.ToLower(v), vendor) {
+			res = append(res, k)
+		}
+	}
+
+	return res
+}
+
 // NetConfig contains all the network-related config for an interface. The IP
 // addresses are automagically populated by snooping ARP traffic. The bandwidth
 // stats and IP addresses are updated on-demand by calling the UpdateNetworks
@@ -40,6 +55,18 @@ type NetConfig struct {
 	IP6    string
 	QinQ   bool
 
+	// Static address plan for the interface, parsed from the optional ip=,
+	// ip6=, and hostname= fields in the netspec. These are pushed to running
+	// dnsmasq servers when the VM launches so that the guest picks them up
+	// via DHCP and DNS.
+	IP4Static string
+	IP6Static string
+	Hostname  string
+
+	// Vhost is set when the interface is a vhost-user port on a DPDK bridge
+	// rather than a kernel tap, see "bridge dpdk"
+	Vhost bool
+
 	RxRate, TxRate float64 // Most recent bandwidth measurements for Tap
 
 	// Raw string that we used when creating this network config will be
@@ -75,11 +102,52 @@ type NetConfigs []NetConfig
 //
 //	bridge,vlan alias,mac,driver,qinq
 //
-// If there are 2 or 3 fields, just the last field for the presence of a mac
+// If there are 2 or 3 fields, just the last field for the presence of a mac.
+//
+// Additionally, any of the above forms may include ip=, ip6=, and hostname=
+// fields, in any position, to record a static address plan for the interface:
+//
+//	vlan alias,ip=10.0.0.5/24,ip6=fd00::5/64,hostname=web-1
+//
+// The addresses may optionally include a CIDR mask.
 func ParseNetConfig(spec string, nics map[string]bool) (*NetConfig, error) {
 	// example: my_bridge,100,00:00:00:00:00:00
 	f := strings.Split(spec, ",")
 
+	// pull out any key=value fields before parsing the positional fields
+	var ip4, ip6, hostname string
+
+	positional := f[:0]
+	for _, field := range f {
+		idx := strings.Index(field, "=")
+		if idx < 0 {
+			positional = append(positional, field)
+			continue
+		}
+
+		key, val := field[:idx], field[idx+1:]
+		switch key {
+		case "ip":
+			if !isIPField(val, false) {
+				return nil, fmt.Errorf("invalid IPv4 address: `%v`", val)
+			}
+			ip4 = val
+		case "ip6":
+			if !isIPField(val, true) {
+				return nil, fmt.Errorf("invalid IPv6 address: `%v`", val)
+			}
+			ip6 = val
+		case "hostname":
+			if val == "" {
+				return nil, errors.New("blank hostname in netspec")
+			}
+			hostname = val
+		default:
+			return nil, fmt.Errorf("invalid field in netspec: `%v`", field)
+		}
+	}
+	f = positional
+
 	isDriver := func(d string) bool {
 		return nics[d]
 	}
@@ -167,11 +235,14 @@ func ParseNetConfig(spec string, nics map[string]bool) (*NetConfig, error) {
 	}
 
 	return &NetConfig{
-		Alias:  v,
-		Bridge: b,
-		MAC:    strings.ToLower(m),
-		Driver: d,
-		QinQ:   q,
+		Alias:     v,
+		Bridge:    b,
+		MAC:       strings.ToLower(m),
+		Driver:    d,
+		QinQ:      q,
+		IP4Static: ip4,
+		IP6Static: ip6,
+		Hostname:  hostname,
 	}, nil
 }
 
@@ -198,6 +269,18 @@ func (c NetConfig) String() string {
 		parts = append(parts, "qinq")
 	}
 
+	if c.IP4Static != "" {
+		parts = append(parts, "ip="+c.IP4Static)
+	}
+
+	if c.IP6Static != "" {
+		parts = append(parts, "ip6="+c.IP6Static)
+	}
+
+	if c.Hostname != "" {
+		parts = append(parts, "hostname="+c.Hostname)
+	}
+
 	return strings.Join(parts, ",")
 }
 
@@ -458,6 +541,28 @@ func ParseBondConfig(spec string) (*BondConfig, error) {
 	}, nil
 }
 
+// isIPField tests whether s is an IP address or CIDR of the requested family.
+func isIPField(s string, v6 bool) bool {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		var err error
+		if ip, _, err = net.ParseCIDR(s); err != nil {
+			return false
+		}
+	}
+
+	return (ip.To4() == nil) == v6
+}
+
+// trimMask strips the optional CIDR mask from a static address.
+func trimMask(s string) string {
+	if ip, _, err := net.ParseCIDR(s); err == nil {
+		return ip.String()
+	}
+
+	return s
+}
+
 func isMAC(mac string) bool {
 	_, err := net.ParseMAC(mac)
 	return err == nil
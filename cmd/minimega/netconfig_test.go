@@ -24,6 +24,11 @@ func TestParseNetConfig(t *testing.T) {
 		"my_bridge,foo,virtio-net-pci",
 		"my_bridge,foo,de:ad:be:ef:ca:fe",
 		"my_bridge,foo,de:ad:be:ef:ca:fe,virtio-net-pci",
+
+		"foo,ip=10.0.0.5",
+		"foo,ip=10.0.0.5/24,hostname=web",
+		"my_bridge,foo,ip6=fd00::5/64",
+		"foo,de:ad:be:ef:ca:fe,ip=10.0.0.5,ip6=fd00::5,hostname=web",
 	}
 
 	for _, s := range examples {
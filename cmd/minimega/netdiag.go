@@ -0,0 +1,212 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// netDiagIssue describes a single problem found by `network diag` and, when
+// one is known, the command that remedies it.
+type netDiagIssue struct {
+	Iface string
+	Issue string
+
+	// Fix is the argv of the command that remedies the issue. Empty when
+	// there is no safe automatic fix.
+	Fix []string
+}
+
+// netDiagOffloads are the offload features that hurt performance when left
+// enabled on tap interfaces, mapped from the name ethtool reports to the
+// short name used to change them via `ethtool -K`.
+var netDiagOffloads = map[string]string{
+	"generic-receive-offload":  "gro",
+	"tcp-segmentation-offload": "tso",
+}
+
+// ethtoolFeatures queries the offload features of an interface via `ethtool
+// -k` and returns the raw state ("on", "off", possibly suffixed with
+// "[fixed]") for each feature.
+func ethtoolFeatures(iface string) (map[string]string, error) {
+	out, err := processWrapper("ethtool", "-k", iface)
+	if err != nil {
+		return nil, fmt.Errorf("ethtool -k %v: %v", iface, err)
+	}
+
+	features := map[string]string{}
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		features[fields[0]] = strings.TrimSpace(fields[1])
+	}
+
+	return features, nil
+}
+
+// featureOn tests whether an ethtool feature is enabled.
+func featureOn(v string) bool {
+	return strings.HasPrefix(v, "on")
+}
+
+// featureFixed tests whether an ethtool feature cannot be changed.
+func featureFixed(v string) bool {
+	return strings.Contains(v, "[fixed]")
+}
+
+// ifaceMTU reads the MTU of an interface from sysfs.
+func ifaceMTU(iface string) (int, error) {
+	data, err := ioutil.ReadFile(filepath.Join("/sys/class/net", iface, "mtu"))
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// networkDiag checks the bridges, physical trunks, and taps in the namespace
+// for known performance killers and returns any issues found.
+func networkDiag(ns *Namespace) []netDiagIssue {
+	issues := []netDiagIssue{}
+
+	// map tap name to the bridge it is attached to, skipping vhost-user
+	// ports which have no kernel interface to inspect
+	taps := map[string]string{}
+
+	for _, tap := range bridges.HostTaps() {
+		taps[tap.Name] = tap.Bridge
+	}
+
+	ns.VMs.Apply(Wildcard, func(vm VM, _ bool) (bool, error) {
+		for _, nc := range vm.GetNetworks() {
+			if nc.Tap != "" && !nc.Vhost {
+				taps[nc.Tap] = nc.Bridge
+			}
+		}
+
+		return false, nil
+	})
+
+	mtus := map[string]int{}
+
+	// mtu returns the cached MTU for an interface, reading it on first use.
+	// Interfaces that cannot be read (e.g. already destroyed) report zero.
+	mtu := func(iface string) int {
+		if v, ok := mtus[iface]; ok {
+			return v
+		}
+
+		v, err := ifaceMTU(iface)
+		if err != nil {
+			log.Debug("unable to read MTU for %v: %v", iface, err)
+			v = 0
+		}
+
+		mtus[iface] = v
+		return v
+	}
+
+	// check that physical trunks agree with the MTU of their bridge
+	for _, info := range bridges.Info() {
+		if mtu(info.Name) == 0 {
+			continue
+		}
+
+		for _, trunk := range info.Trunks {
+			// trunk entries may include the allowed VLANs after the name
+			name := strings.Fields(trunk)[0]
+
+			if mtu(name) != 0 && mtu(name) != mtu(info.Name) {
+				issues = append(issues, netDiagIssue{
+					Iface: name,
+					Issue: fmt.Sprintf("MTU %v does not match bridge %v MTU %v", mtu(name), info.Name, mtu(info.Name)),
+					Fix:   []string{"ip", "link", "set", "dev", info.Name, "mtu", strconv.Itoa(mtu(name))},
+				})
+			}
+		}
+	}
+
+	for tap, br := range taps {
+		// check that the tap agrees with the MTU of its bridge
+		if mtu(tap) != 0 && mtu(br) != 0 && mtu(tap) != mtu(br) {
+			issues = append(issues, netDiagIssue{
+				Iface: tap,
+				Issue: fmt.Sprintf("MTU %v does not match bridge %v MTU %v", mtu(tap), br, mtu(br)),
+				Fix:   []string{"ip", "link", "set", "dev", tap, "mtu", strconv.Itoa(mtu(br))},
+			})
+		}
+
+		features, err := ethtoolFeatures(tap)
+		if err != nil {
+			log.Debug("unable to query offloads for %v: %v", tap, err)
+			continue
+		}
+
+		// segmentation and receive offload on a tap defers work that the
+		// guest ends up doing anyway and inflates packets beyond what nested
+		// bridges expect
+		for feature, short := range netDiagOffloads {
+			if v, ok := features[feature]; ok && featureOn(v) && !featureFixed(v) {
+				issues = append(issues, netDiagIssue{
+					Iface: tap,
+					Issue: fmt.Sprintf("%v is enabled", feature),
+					Fix:   []string{"ethtool", "-K", tap, short, "off"},
+				})
+			}
+		}
+
+		// checksum offload should match the bridge or packets get dropped
+		// with bad checksums when they cross the host boundary
+		brFeatures, err := ethtoolFeatures(br)
+		if err != nil {
+			log.Debug("unable to query offloads for %v: %v", br, err)
+			continue
+		}
+
+		for _, feature := range []string{"tx-checksumming", "rx-checksumming"} {
+			v, v2 := features[feature], brFeatures[feature]
+			if v == "" || v2 == "" || featureOn(v) == featureOn(v2) {
+				continue
+			}
+
+			issue := netDiagIssue{
+				Iface: tap,
+				Issue: fmt.Sprintf("%v is %v but %v on bridge %v", feature, v, v2, br),
+			}
+
+			if !featureFixed(v) {
+				state := "off"
+				if featureOn(v2) {
+					state = "on"
+				}
+
+				issue.Fix = []string{"ethtool", "-K", tap, strings.TrimSuffix(feature, "-checksumming"), state}
+			}
+
+			issues = append(issues, issue)
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Iface == issues[j].Iface {
+			return issues[i].Issue < issues[j].Issue
+		}
+
+		return issues[i].Iface < issues[j].Iface
+	})
+
+	return issues
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var netDiagCLIHandlers = []minicli.Handler{
+	{ // network diag
+		HelpShort: "diagnose common virtual network performance problems",
+		HelpLong: `
+Check the bridges, physical trunks, and taps used by the active namespace for
+known performance killers:
+
+- GRO and TSO left enabled on tap interfaces, which defers segmentation work
+  that the guest ends up doing anyway.
+- Checksum offload mismatches between a tap and its bridge, which can cause
+  packets to be dropped with bad checksums when they cross the host boundary.
+- MTU inconsistencies between taps, bridges, and physical trunk interfaces,
+  which silently blackhole large packets.
+
+Each issue is reported along with the command that remedies it, when there is
+a safe automatic fix. To apply all recommended fixes:
+
+	network diag fix
+
+Issues without an automatic fix (e.g. offloads the driver reports as fixed)
+are reported but left alone.`,
+		Patterns: []string{
+			"network diag",
+			"network diag <fix,>",
+		},
+		Call: wrapSimpleCLI(cliNetworkDiag),
+	},
+}
+
+func cliNetworkDiag(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	issues := networkDiag(ns)
+
+	if c.BoolArgs["fix"] {
+		applied := 0
+
+		for _, issue := range issues {
+			if len(issue.Fix) == 0 {
+				continue
+			}
+
+			if out, err := privProcessWrapper(issue.Fix...); err != nil {
+				return fmt.Errorf("fixing %v on %v: %v: %v", issue.Issue, issue.Iface, err, out)
+			}
+
+			applied++
+		}
+
+		resp.Response = fmt.Sprintf("applied %v of %v fixes", applied, len(issues))
+		return nil
+	}
+
+	resp.Header = []string{"interface", "issue", "fix"}
+	resp.Tabular = [][]string{}
+
+	for _, issue := range issues {
+		resp.Tabular = append(resp.Tabular, []string{
+			issue.Iface,
+			issue.Issue,
+			strings.Join(issue.Fix, " "),
+		})
+	}
+
+	return nil
+}
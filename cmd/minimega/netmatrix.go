@@ -0,0 +1,140 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sandia-minimega/minimega/v2/internal/ron"
+)
+
+// NetMatrix describes a pairwise reachability test across a set of VMs,
+// selected by VLAN or by tag. Each VM pings every other VM via a cc-deployed
+// probe and the results render as a pass/fail matrix, instantly validating
+// that a topology matches the intended segmentation. One matrix exists per
+// namespace at a time; starting a new one clears the old.
+type NetMatrix struct {
+	// Target is the selector the matrix was built from, e.g. "vlan DMZ"
+	Target string
+
+	// VMs are the sorted names of the selected VMs
+	VMs []string
+
+	// snooped IPv4 per VM, the address the other VMs probe
+	ips map[string]string
+
+	// cc command IDs, keyed by source and then destination VM name
+	ids map[string]map[string]int
+}
+
+// e.g. "3 packets transmitted, 3 received, 0% packet loss, time 404ms"
+var pingLossRe = regexp.MustCompile(`([\d.]+)% packet loss`)
+
+// prefix returns the cc prefix used to track the matrix's commands.
+func (m *NetMatrix) prefix() string {
+	return "netmatrix"
+}
+
+// StartNetMatrix deploys ping probes between every pair of VMs matched by the
+// selector, replacing any previous matrix. VLAN selectors match VMs with an
+// interface on the aliased VLAN; tag selectors match VMs carrying the key
+// (and value, if given). Every matched VM must have a snooped IP.
+func (ns *Namespace) StartNetMatrix(target string, match func(VM) bool) error {
+	if err := ns.ClearNetMatrix(); err != nil {
+		return err
+	}
+
+	m := &NetMatrix{
+		Target: target,
+		ips:    make(map[string]string),
+		ids:    make(map[string]map[string]int),
+	}
+
+	vms := map[string]VM{}
+
+	for _, vm := range globalVMs(ns) {
+		if !match(vm) {
+			continue
+		}
+
+		v4, _ := vmIPs(vm)
+		if len(v4) == 0 {
+			return fmt.Errorf("no known IP for vm %v -- is it up and snooped?", vm.GetName())
+		}
+
+		vms[vm.GetName()] = vm
+		m.ips[vm.GetName()] = v4[0].String()
+		m.VMs = append(m.VMs, vm.GetName())
+	}
+
+	if len(m.VMs) < 2 {
+		return fmt.Errorf("matrix needs at least two VMs, matched %v", len(m.VMs))
+	}
+
+	sort.Strings(m.VMs)
+
+	for _, src := range m.VMs {
+		m.ids[src] = make(map[string]int)
+
+		for _, dst := range m.VMs {
+			if src == dst {
+				continue
+			}
+
+			m.ids[src][dst] = ns.ccServer.NewCommand(&ron.Command{
+				Command: []string{"ping", "-c", "3", "-W", "2", m.ips[dst]},
+				Prefix:  m.prefix(),
+				Filter:  &ron.Filter{UUID: vms[src].GetUUID()},
+			})
+		}
+	}
+
+	ns.netmatrix = m
+
+	return nil
+}
+
+// ClearNetMatrix deletes the matrix and its posted commands, if any.
+func (ns *Namespace) ClearNetMatrix() error {
+	m := ns.netmatrix
+	if m == nil {
+		return nil
+	}
+
+	if err := ns.ccServer.DeleteCommands(m.prefix()); err != nil {
+		if !strings.HasPrefix(err.Error(), "no such prefix") {
+			return err
+		}
+	}
+
+	ns.netmatrix = nil
+
+	return nil
+}
+
+// Status reports the reachability from src to dst: "pass" if pings got
+// through, "fail" if they were all lost, and empty if the source hasn't
+// reported back yet.
+func (m *NetMatrix) Status(ns *Namespace, src, dst string) string {
+	r, err := ns.ccServer.GetResponse(m.ids[src][dst], true)
+	if err != nil {
+		return ""
+	}
+
+	l := pingLossRe.FindStringSubmatch(r)
+	if l == nil {
+		return ""
+	}
+
+	if loss, err := strconv.ParseFloat(l[1], 64); err == nil && loss < 100 {
+		return "pass"
+	}
+
+	return "fail"
+}
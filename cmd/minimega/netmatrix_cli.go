@@ -0,0 +1,126 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var netmatrixCLIHandlers = []minicli.Handler{
+	{ // network matrix
+		HelpShort: "test pairwise reachability between VMs",
+		HelpLong: `
+Deploy ping probes via the command and control layer between every pair of
+VMs on a VLAN or carrying a tag, and render the results as a pass/fail
+matrix. Useful for instantly validating that a newly-built topology matches
+the intended segmentation:
+
+	network matrix vlan DMZ
+	network matrix tag role server
+
+Every selected VM must be running the cc agent and have a snooped IP. Once
+started, calling network matrix with no arguments renders the matrix; cells
+are empty until the source VM reports back. Only one matrix exists per
+namespace; starting a new one replaces the old.
+
+To delete the matrix and its posted cc commands:
+
+	clear network matrix`,
+		Patterns: []string{
+			"network matrix",
+			"network matrix <vlan,> <alias>",
+			"network matrix <tag,> <key> [value]",
+		},
+		Call: wrapBroadcastCLI(cliNetMatrix),
+		Suggest: wrapSuggest(func(ns *Namespace, val, prefix string) []string {
+			if val == "alias" {
+				return cliVLANSuggest(ns, prefix)
+			} else if val == "key" {
+				return cliTagSuggest(ns, prefix)
+			}
+			return nil
+		}),
+	},
+	{ // clear network matrix
+		HelpShort: "delete the reachability matrix",
+		HelpLong: `
+Deletes the current reachability matrix and its posted cc commands. See
+"help network matrix" for more information.`,
+		Patterns: []string{
+			"clear network matrix",
+		},
+		Call: wrapBroadcastCLI(cliNetMatrixClear),
+	},
+}
+
+func cliNetMatrix(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["vlan"] {
+		vlan, err := lookupVLAN(ns.Name, c.StringArgs["alias"])
+		if err != nil {
+			return err
+		}
+
+		match := func(vm VM) bool {
+			for _, nic := range vm.GetNetworks() {
+				if nic.VLAN == vlan {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		return ns.StartNetMatrix("vlan "+c.StringArgs["alias"], match)
+	}
+
+	if c.BoolArgs["tag"] {
+		key := c.StringArgs["key"]
+		value := c.StringArgs["value"]
+
+		target := "tag " + key
+		if value != "" {
+			target += "=" + value
+		}
+
+		match := func(vm VM) bool {
+			v := vm.Tag(key)
+			if value != "" {
+				return v == value
+			}
+
+			return v != ""
+		}
+
+		return ns.StartNetMatrix(target, match)
+	}
+
+	m := ns.netmatrix
+	if m == nil {
+		return nil
+	}
+
+	resp.Header = append([]string{m.Target}, m.VMs...)
+
+	for _, src := range m.VMs {
+		row := []string{src}
+
+		for _, dst := range m.VMs {
+			if src == dst {
+				row = append(row, "-")
+				continue
+			}
+
+			row = append(row, m.Status(ns, src, dst))
+		}
+
+		resp.Tabular = append(resp.Tabular, row)
+	}
+
+	return nil
+}
+
+func cliNetMatrixClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	return ns.ClearNetMatrix()
+}
@@ -0,0 +1,167 @@
+// Copyright 2015-2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sandia-minimega/minimega/v2/internal/ron"
+)
+
+// NetProbe describes a latency/throughput measurement between a pair of VMs.
+// Probes are deployed via the cc layer: ping measures latency and jitter
+// while iperf3 measures throughput, so both tools must be present in the
+// guest images. Results are collected from the cc responses once the clients
+// check back in.
+type NetProbe struct {
+	// Src and Dst are the names of the VMs being measured between
+	Src string
+	Dst string
+
+	// DstIP is the snooped IP of Dst that Src probes
+	DstIP string
+
+	// Duration of the measurement
+	Duration time.Duration
+
+	// cc command IDs for the probe commands, used to look up responses
+	pingID int
+	bwID   int
+}
+
+// parsers for the interesting bits of the ping and iperf3 output
+var (
+	// e.g. "rtt min/avg/max/mdev = 0.044/0.062/0.084/0.016 ms"
+	pingRTTRe = regexp.MustCompile(`rtt min/avg/max/mdev = [\d.]+/([\d.]+)/[\d.]+/([\d.]+) ms`)
+
+	// e.g. "[  5]   0.00-10.00  sec  1.10 GBytes   941 Mbits/sec                  receiver"
+	iperfRe = regexp.MustCompile(`([\d.]+ [KMG]?bits/sec).*receiver`)
+)
+
+// key identifies the probe in the namespace's probe map.
+func (n *NetProbe) key() string {
+	return fmt.Sprintf("%v:%v", n.Src, n.Dst)
+}
+
+// prefix returns the cc prefix used to track the probe's commands.
+func (n *NetProbe) prefix() string {
+	return fmt.Sprintf("netprobe-%v-%v", n.Src, n.Dst)
+}
+
+// StartNetProbe deploys a probe between the named VMs. The destination runs a
+// one-shot iperf3 server while the source pings and then connects to it.
+func (ns *Namespace) StartNetProbe(src, dst string, d time.Duration) error {
+	srcVM := ns.findNetProbeVM(src)
+	if srcVM == nil {
+		return vmNotFound(src)
+	}
+
+	dstVM := ns.findNetProbeVM(dst)
+	if dstVM == nil {
+		return vmNotFound(dst)
+	}
+
+	v4, _ := vmIPs(dstVM)
+	if len(v4) == 0 {
+		return fmt.Errorf("no known IP for vm %v -- is it up and snooped?", dst)
+	}
+
+	n := &NetProbe{
+		Src:      src,
+		Dst:      dst,
+		DstIP:    v4[0].String(),
+		Duration: d,
+	}
+
+	if _, ok := ns.netprobes[n.key()]; ok {
+		return fmt.Errorf("probe already exists: %v -> %v", src, dst)
+	}
+
+	seconds := int(d.Seconds())
+
+	// one-shot iperf3 server on the destination
+	ns.ccServer.NewCommand(&ron.Command{
+		Background: true,
+		Command:    []string{"iperf3", "-s", "-1"},
+		Prefix:     n.prefix(),
+		Filter:     &ron.Filter{UUID: dstVM.GetUUID()},
+	})
+
+	// ping for latency/jitter, then iperf3 for throughput
+	n.pingID = ns.ccServer.NewCommand(&ron.Command{
+		Command: []string{"ping", "-i", "0.2", "-c", fmt.Sprintf("%v", 5*seconds), n.DstIP},
+		Prefix:  n.prefix(),
+		Filter:  &ron.Filter{UUID: srcVM.GetUUID()},
+	})
+
+	n.bwID = ns.ccServer.NewCommand(&ron.Command{
+		Command: []string{"iperf3", "-c", n.DstIP, "-t", fmt.Sprintf("%v", seconds)},
+		Prefix:  n.prefix(),
+		Filter:  &ron.Filter{UUID: srcVM.GetUUID()},
+	})
+
+	ns.netprobes[n.key()] = n
+
+	return nil
+}
+
+// ClearNetProbes deletes probes and their posted commands. An empty name or
+// the wildcard clears all probes.
+func (ns *Namespace) ClearNetProbes(name string) error {
+	for _, n := range ns.netprobes {
+		if name != "" && name != Wildcard && n.key() != name {
+			continue
+		}
+
+		if err := ns.ccServer.DeleteCommands(n.prefix()); err != nil {
+			if !strings.HasPrefix(err.Error(), "no such prefix") {
+				return err
+			}
+		}
+
+		delete(ns.netprobes, n.key())
+	}
+
+	return nil
+}
+
+// Results parses latency, jitter, and throughput out of the probe's cc
+// responses. Fields that haven't been reported yet are empty.
+func (n *NetProbe) Results(ns *Namespace) (latency, jitter, throughput string) {
+	if r, err := ns.ccServer.GetResponse(n.pingID, true); err == nil {
+		if m := pingRTTRe.FindStringSubmatch(r); m != nil {
+			latency = m[1] + " ms"
+			jitter = m[2] + " ms"
+		}
+	}
+
+	if r, err := ns.ccServer.GetResponse(n.bwID, true); err == nil {
+		if m := iperfRe.FindStringSubmatch(r); m != nil {
+			throughput = m[1]
+		}
+	}
+
+	return
+}
+
+// findNetProbeVM finds a VM in the namespace by name, preferring local VMs
+// since their network info can be refreshed cheaply.
+func (ns *Namespace) findNetProbeVM(name string) VM {
+	if vm := ns.FindVM(name); vm != nil {
+		vm.UpdateNetworks()
+		return vm
+	}
+
+	for _, vm := range globalVMs(ns) {
+		if vm.GetName() == name {
+			return vm
+		}
+	}
+
+	return nil
+}
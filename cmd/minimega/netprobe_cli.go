@@ -0,0 +1,108 @@
+// Copyright 2015-2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var netprobeCLIHandlers = []minicli.Handler{
+	{ // netprobe
+		HelpShort: "measure latency and throughput between VM pairs",
+		HelpLong: `
+Deploy lightweight probes via the command and control layer to measure
+latency, jitter, and throughput between a pair of VMs, for example to verify
+that QoS and topology settings behave as intended:
+
+	netprobe client server 10s
+
+The destination VM runs a one-shot iperf3 server while the source VM pings it
+and then connects to it. ping and iperf3 must be present in the guest images
+and both VMs must be running the miniccc client. The optional duration
+controls how long each measurement runs (default 10s).
+
+Probes report once the clients have checked back in -- run netprobe without
+arguments to list probes and any results so far:
+
+	netprobe
+
+To delete probes and their posted commands, use "clear netprobe".`,
+		Patterns: []string{
+			"netprobe",
+			"netprobe <src vm> <dst vm> [duration]",
+		},
+		Call:    wrapBroadcastCLI(cliNetProbe),
+		Suggest: wrapVMSuggest(VM_ANY_STATE, false),
+	},
+	{ // clear netprobe
+		HelpShort: "delete netprobes",
+		HelpLong: `
+Deletes one or all netprobes and their posted cc commands. Probes are named
+by their VM pair, e.g. "client:server". See "help netprobe" for more
+information.`,
+		Patterns: []string{
+			"clear netprobe [name]",
+		},
+		Call: wrapBroadcastCLI(cliNetProbeClear),
+	},
+}
+
+func cliNetProbe(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if src := c.StringArgs["src"]; src != "" {
+		d := 10 * time.Second
+
+		if c.StringArgs["duration"] != "" {
+			v, err := time.ParseDuration(c.StringArgs["duration"])
+			if err != nil || v < time.Second {
+				return fmt.Errorf("invalid duration: `%v`", c.StringArgs["duration"])
+			}
+
+			d = v
+		}
+
+		return ns.StartNetProbe(src, c.StringArgs["dst"], d)
+	}
+
+	// no arguments, list the probes
+	resp.Header = []string{
+		"src",
+		"dst",
+		"ip",
+		"duration",
+		"latency",
+		"jitter",
+		"throughput",
+	}
+
+	resp.Tabular = [][]string{}
+
+	for _, n := range ns.netprobes {
+		latency, jitter, throughput := n.Results(ns)
+
+		resp.Tabular = append(resp.Tabular, []string{
+			n.Src,
+			n.Dst,
+			n.DstIP,
+			n.Duration.String(),
+			latency,
+			jitter,
+			throughput,
+		})
+	}
+
+	sort.Slice(resp.Tabular, func(i, j int) bool {
+		return resp.Tabular[i][0] < resp.Tabular[j][0]
+	})
+
+	return nil
+}
+
+func cliNetProbeClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	return ns.ClearNetProbes(c.StringArgs["name"])
+}
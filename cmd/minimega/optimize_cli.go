@@ -5,14 +5,16 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
-	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
 	"github.com/sandia-minimega/minimega/v2/pkg/ranges"
 )
 
@@ -139,8 +141,8 @@ func cliOptimizeKSM(ns *Namespace, c *minicli.Command, resp *minicli.Response) e
 
 func cliOptimizeHugePages(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	if v := c.StringArgs["path"]; v != "" {
-		if _, err := os.Stat(v); os.IsNotExist(err) {
-			log.Warn("file does not exist: %v", v)
+		if err := checkHugepagesMount(v); err != nil {
+			return err
 		}
 
 		ns.hugepagesMountPath = v
@@ -191,6 +193,35 @@ func cliOptimizeAffinity(ns *Namespace, c *minicli.Command, resp *minicli.Respon
 	return nil
 }
 
+// checkHugepagesMount ensures that path is a hugetlbfs mount according to
+// /proc/mounts so that a typo'd path fails here rather than at launch.
+func checkHugepagesMount(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[1] == filepath.Clean(path) && fields[2] == "hugetlbfs" {
+			return nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("not a hugetlbfs mount: %v", path)
+}
+
 func cliOptimizeClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	switch {
 	case c.BoolArgs["filter"]:
@@ -0,0 +1,109 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// portAllocator hands out TCP listener ports for VNC shims, container
+// consoles, and other per-VM services. When an administrator configures a
+// range via `ports range`, ports are allocated from that range so that
+// firewalls between operators and experiment hosts can be provisioned
+// deterministically. Without a range, the OS assigns ephemeral ports as
+// before. Allocations are recorded either way and reported via `ports`.
+type portAllocator struct {
+	sync.Mutex
+
+	// inclusive bounds of the configured range, zero when unset
+	low, high int
+
+	// active allocations, port -> description
+	allocations map[int]string
+}
+
+var reservedPorts = &portAllocator{allocations: map[int]string{}}
+
+// SetRange configures the allocation range. Existing allocations are
+// unaffected.
+func (p *portAllocator) SetRange(low, high int) error {
+	if low < 1 || high > 65535 || low > high {
+		return fmt.Errorf("invalid port range: %v-%v", low, high)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.low, p.high = low, high
+	return nil
+}
+
+// Range returns the configured allocation range, zeros when unset.
+func (p *portAllocator) Range() (int, int) {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.low, p.high
+}
+
+// Listen returns a TCP listener for the described service. With a range
+// configured, the lowest free port from the range is used, otherwise the OS
+// picks an ephemeral port. The allocation is recorded until Release is
+// called with the listener's port.
+func (p *portAllocator) Listen(desc string) (net.Listener, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.low == 0 {
+		l, err := net.Listen("tcp", "")
+		if err != nil {
+			return nil, err
+		}
+
+		p.allocations[l.Addr().(*net.TCPAddr).Port] = desc
+		return l, nil
+	}
+
+	for port := p.low; port <= p.high; port++ {
+		if _, ok := p.allocations[port]; ok {
+			continue
+		}
+
+		l, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
+		if err != nil {
+			// something outside our control owns the port, skip it
+			continue
+		}
+
+		p.allocations[port] = desc
+		return l, nil
+	}
+
+	return nil, fmt.Errorf("no ports available in range %v-%v", p.low, p.high)
+}
+
+// Release forgets the allocation for a port. The caller is responsible for
+// closing the listener.
+func (p *portAllocator) Release(port int) {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.allocations, port)
+}
+
+// Allocations returns a copy of the active allocations.
+func (p *portAllocator) Allocations() map[int]string {
+	p.Lock()
+	defer p.Unlock()
+
+	res := map[int]string{}
+	for port, desc := range p.allocations {
+		res[port] = desc
+	}
+
+	return res
+}
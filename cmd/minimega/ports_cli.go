@@ -0,0 +1,89 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var portsCLIHandlers = []minicli.Handler{
+	{ // ports
+		HelpShort: "control and list port allocations for VM services",
+		HelpLong: `Control and list the TCP ports allocated for VNC shims, container
+consoles, and other per-VM listeners.
+
+By default, listeners bind to ephemeral ports assigned by the OS. Setting a
+range restricts future allocations to that range so that firewalls between
+operators and experiment hosts can be provisioned deterministically:
+
+	ports range 40000 40999
+
+The range applies per host and only affects listeners created after it is
+set -- existing listeners keep their ports. Launching a VM fails if every
+port in the range is in use.
+
+Called with no arguments, ports lists the active allocations on each host:
+
+	ports
+
+To display the configured range:
+
+	ports range`,
+		Patterns: []string{
+			"ports",
+			"ports <range,>",
+			"ports <range,> <low> <high>",
+		},
+		Call: wrapBroadcastCLI(cliPorts),
+	},
+}
+
+func cliPorts(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["range"] {
+		if c.StringArgs["low"] == "" {
+			low, high := reservedPorts.Range()
+			if low == 0 {
+				resp.Response = "unset"
+			} else {
+				resp.Response = fmt.Sprintf("%v-%v", low, high)
+			}
+			return nil
+		}
+
+		low, err := strconv.Atoi(c.StringArgs["low"])
+		if err != nil {
+			return fmt.Errorf("expected integer low port: `%v`", c.StringArgs["low"])
+		}
+
+		high, err := strconv.Atoi(c.StringArgs["high"])
+		if err != nil {
+			return fmt.Errorf("expected integer high port: `%v`", c.StringArgs["high"])
+		}
+
+		return reservedPorts.SetRange(low, high)
+	}
+
+	allocations := reservedPorts.Allocations()
+
+	ports := []int{}
+	for port := range allocations {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	resp.Header = []string{"port", "description"}
+	for _, port := range ports {
+		resp.Tabular = append(resp.Tabular, []string{
+			strconv.Itoa(port),
+			allocations[port],
+		})
+	}
+
+	return nil
+}
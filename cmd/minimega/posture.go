@@ -0,0 +1,222 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sandia-minimega/minimega/v2/internal/ron"
+)
+
+// postureCheck is one item in the bundled collection script set, with a
+// command line per guest OS. The commands only use tools that stock Linux and
+// Windows images ship with.
+type postureCheck struct {
+	Name    string
+	Linux   []string
+	Windows []string
+}
+
+// postureChecks are the checks deployed by `posture collect`, in the order
+// they appear in reports.
+var postureChecks = []postureCheck{
+	{
+		Name:    "patches",
+		Linux:   []string{"sh", "-c", "uname -sr && (dpkg-query -W 2>/dev/null || rpm -qa 2>/dev/null)"},
+		Windows: []string{"wmic", "qfe", "get", "HotFixID"},
+	},
+	{
+		Name:    "services",
+		Linux:   []string{"sh", "-c", "ss -tuln 2>/dev/null || netstat -tuln"},
+		Windows: []string{"cmd", "/c", "netstat -ano | findstr LISTENING"},
+	},
+	{
+		Name:    "users",
+		Linux:   []string{"sh", "-c", "cut -d: -f1 /etc/passwd"},
+		Windows: []string{"net", "user"},
+	},
+}
+
+// PostureReport tracks the posture checks collected from one VM. The raw
+// outputs live in the cc responses and are normalized into JSON on demand,
+// see `posture json`.
+type PostureReport struct {
+	// VM name, UUID, and OS as reported by the cc client
+	VM   string
+	UUID string
+	OS   string
+
+	// cc command IDs, keyed by check name
+	ids map[string]int
+}
+
+// prefix returns the cc prefix used to track the report's commands.
+func (r *PostureReport) prefix() string {
+	return fmt.Sprintf("posture-%v", r.VM)
+}
+
+// CollectPosture deploys the collection script set to the named VM or, with
+// the wildcard, to every VM in the namespace with an active cc client. The
+// guest OS decides which variant of each check runs.
+func (ns *Namespace) CollectPosture(target string) error {
+	clients := ns.ccServer.GetClients()
+
+	var found bool
+
+	for _, vm := range globalVMs(ns) {
+		if target != Wildcard && vm.GetName() != target {
+			continue
+		}
+
+		client, ok := clients[vm.GetUUID()]
+		if !ok {
+			if target == Wildcard {
+				continue
+			}
+
+			return fmt.Errorf("no active cc client for vm %v", target)
+		}
+
+		found = true
+
+		// replace any previous report for the VM
+		if err := ns.clearPosture(vm.GetName()); err != nil {
+			return err
+		}
+
+		r := &PostureReport{
+			VM:   vm.GetName(),
+			UUID: vm.GetUUID(),
+			OS:   client.OS,
+			ids:  make(map[string]int),
+		}
+
+		for _, check := range postureChecks {
+			cmd := check.Linux
+			if strings.Contains(strings.ToLower(client.OS), "windows") {
+				cmd = check.Windows
+			}
+
+			r.ids[check.Name] = ns.ccServer.NewCommand(&ron.Command{
+				Command: cmd,
+				Prefix:  r.prefix(),
+				Filter:  &ron.Filter{UUID: r.UUID},
+			})
+		}
+
+		ns.postures[r.VM] = r
+	}
+
+	if !found {
+		if target == Wildcard {
+			return fmt.Errorf("no VMs with active cc clients")
+		}
+
+		return vmNotFound(target)
+	}
+
+	return nil
+}
+
+// ClearPostures deletes reports and their posted commands. An empty name or
+// the wildcard clears all reports.
+func (ns *Namespace) ClearPostures(name string) error {
+	for _, r := range ns.postures {
+		if name != "" && name != Wildcard && r.VM != name {
+			continue
+		}
+
+		if err := ns.clearPosture(r.VM); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ns *Namespace) clearPosture(name string) error {
+	r, ok := ns.postures[name]
+	if !ok {
+		return nil
+	}
+
+	if err := ns.ccServer.DeleteCommands(r.prefix()); err != nil {
+		if !strings.HasPrefix(err.Error(), "no such prefix") {
+			return err
+		}
+	}
+
+	delete(ns.postures, name)
+
+	return nil
+}
+
+// Results returns the normalized check outputs, one list of trimmed,
+// non-empty lines per check. Checks the VM hasn't reported yet are omitted.
+func (r *PostureReport) Results(ns *Namespace) map[string][]string {
+	res := make(map[string][]string)
+
+	for name, id := range r.ids {
+		raw, err := ns.ccServer.GetResponse(id, true)
+		if err != nil {
+			continue
+		}
+
+		var lines []string
+
+		for _, line := range strings.Split(raw, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+
+		if lines != nil {
+			res[name] = lines
+		}
+	}
+
+	return res
+}
+
+// JSON renders the report as normalized JSON, the same shape regardless of
+// the guest OS.
+func (r *PostureReport) JSON(ns *Namespace) (string, error) {
+	v := struct {
+		VM     string              `json:"vm"`
+		UUID   string              `json:"uuid"`
+		OS     string              `json:"os"`
+		Checks map[string][]string `json:"checks"`
+	}{
+		VM:     r.VM,
+		UUID:   r.UUID,
+		OS:     r.OS,
+		Checks: r.Results(ns),
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// sortedPostures returns the namespace's reports sorted by VM name.
+func (ns *Namespace) sortedPostures() []*PostureReport {
+	var res []*PostureReport
+
+	for _, r := range ns.postures {
+		res = append(res, r)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].VM < res[j].VM
+	})
+
+	return res
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var postureCLIHandlers = []minicli.Handler{
+	{ // posture
+		HelpShort: "collect security posture data from guests",
+		HelpLong: `
+Run a bundled collection script set on guests via the command and control
+layer and aggregate the results into normalized JSON per VM, for
+security-posture baselining of emulated enterprises. The checks cover patch
+level, listening services, and local users, with Linux and Windows variants
+chosen automatically based on the OS the cc client reports.
+
+To collect from one VM or from every VM with an active cc client:
+
+	posture collect server
+	posture collect all
+
+Collection is asynchronous; calling posture with no arguments shows which
+checks each VM has reported so far. Once collected, render a VM's report:
+
+	posture json server
+
+To delete reports and their posted cc commands:
+
+	clear posture [vm]`,
+		Patterns: []string{
+			"posture",
+			"posture <collect,> <vm or all>",
+			"posture <json,> <vm>",
+		},
+		Call:    wrapBroadcastCLI(cliPosture),
+		Suggest: wrapVMSuggest(VM_ANY_STATE, true),
+	},
+	{ // clear posture
+		HelpShort: "delete posture reports",
+		HelpLong: `
+Deletes one or all posture reports and their posted cc commands. See "help
+posture" for more information.`,
+		Patterns: []string{
+			"clear posture [vm]",
+		},
+		Call:    wrapBroadcastCLI(cliPostureClear),
+		Suggest: wrapVMSuggest(VM_ANY_STATE, true),
+	},
+}
+
+func cliPosture(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["collect"] {
+		target := c.StringArgs["vm"]
+		if target == "all" {
+			target = Wildcard
+		}
+
+		return ns.CollectPosture(target)
+	}
+
+	if c.BoolArgs["json"] {
+		r, ok := ns.postures[c.StringArgs["vm"]]
+		if !ok {
+			return fmt.Errorf("no posture report for vm %v", c.StringArgs["vm"])
+		}
+
+		out, err := r.JSON(ns)
+		if err != nil {
+			return err
+		}
+
+		resp.Response = out
+		return nil
+	}
+
+	resp.Header = []string{"vm", "os", "reported", "pending"}
+
+	for _, r := range ns.sortedPostures() {
+		results := r.Results(ns)
+
+		var reported, pending []string
+
+		for _, check := range postureChecks {
+			if _, ok := results[check.Name]; ok {
+				reported = append(reported, check.Name)
+			} else {
+				pending = append(pending, check.Name)
+			}
+		}
+
+		resp.Tabular = append(resp.Tabular, []string{
+			r.VM,
+			r.OS,
+			fmt.Sprintf("%v/%v", len(reported), len(postureChecks)),
+			strings.Join(pending, ","),
+		})
+	}
+
+	return nil
+}
+
+func cliPostureClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	return ns.ClearPostures(c.StringArgs["vm"])
+}
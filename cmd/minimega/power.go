@@ -0,0 +1,245 @@
+// Copyright 2015-2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// powerTimeLayout is the wall-clock format accepted by "vm schedule".
+const powerTimeLayout = "15:04"
+
+// powerTick is how often the scheduler checks for due events.
+const powerTick = 15 * time.Second
+
+// PowerEvent describes a daily scheduled start or stop for a group of VMs so
+// that diurnal activity patterns can run unattended across multi-day
+// experiments.
+type PowerEvent struct {
+	ID     int
+	Action string // "start" or "stop"
+	Target string // VM target or tag=key[:value] selector
+	At     string // wall-clock time in HH:MM, local time
+
+	// next is the next instant the event fires
+	next time.Time
+}
+
+// powerScheduler holds the scheduled power events for a namespace and the
+// goroutine that fires them.
+type powerScheduler struct {
+	mu sync.Mutex
+
+	events  map[int]*PowerEvent
+	counter *Counter
+
+	// done kills the ticker goroutine, nil when it is not running
+	done chan bool
+}
+
+func newPowerScheduler() *powerScheduler {
+	return &powerScheduler{
+		events:  make(map[int]*PowerEvent),
+		counter: NewCounter(),
+	}
+}
+
+// Destroy stops the ticker goroutine and the counter.
+func (s *powerScheduler) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		close(s.done)
+		s.done = nil
+	}
+
+	s.counter.Stop()
+}
+
+// nextPowerTime returns the first instant after now with the wall-clock time
+// of at.
+func nextPowerTime(now, at time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.Add(24 * time.Hour)
+	}
+
+	return next
+}
+
+// SchedulePower adds a daily start or stop event for VMs matching target.
+func (ns *Namespace) SchedulePower(action, target, at string) error {
+	t, err := time.ParseInLocation(powerTimeLayout, at, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid time: `%v`, expected HH:MM", at)
+	}
+
+	p := &PowerEvent{
+		ID:     ns.power.counter.Next(),
+		Action: action,
+		Target: target,
+		At:     t.Format(powerTimeLayout),
+		next:   nextPowerTime(time.Now(), t),
+	}
+
+	ns.power.mu.Lock()
+	defer ns.power.mu.Unlock()
+
+	ns.power.events[p.ID] = p
+
+	if ns.power.done == nil {
+		ns.power.done = make(chan bool)
+		go ns.runPower(ns.power.done)
+	}
+
+	log.Info("scheduled vm %v %v daily at %v", p.Action, p.Target, p.At)
+
+	return nil
+}
+
+// ClearPowerSchedule deletes scheduled power events. An empty ID or the
+// wildcard deletes all events.
+func (ns *Namespace) ClearPowerSchedule(id string) error {
+	ns.power.mu.Lock()
+	defer ns.power.mu.Unlock()
+
+	if id == "" || id == Wildcard {
+		ns.power.events = make(map[int]*PowerEvent)
+	} else {
+		v, err := strconv.Atoi(id)
+		if err != nil {
+			return fmt.Errorf("invalid schedule id: `%v`", id)
+		}
+
+		if _, ok := ns.power.events[v]; !ok {
+			return fmt.Errorf("no such schedule id: %v", v)
+		}
+
+		delete(ns.power.events, v)
+	}
+
+	// no more events to fire, kill the ticker goroutine
+	if len(ns.power.events) == 0 && ns.power.done != nil {
+		close(ns.power.done)
+		ns.power.done = nil
+	}
+
+	return nil
+}
+
+// PowerScheduleInfo returns a copy of the namespace's scheduled power events.
+func (ns *Namespace) PowerScheduleInfo() []PowerEvent {
+	ns.power.mu.Lock()
+	defer ns.power.mu.Unlock()
+
+	res := []PowerEvent{}
+
+	for _, p := range ns.power.events {
+		res = append(res, *p)
+	}
+
+	return res
+}
+
+// runPower fires due events until done is closed.
+func (ns *Namespace) runPower(done chan bool) {
+	ticker := time.NewTicker(powerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			for _, p := range ns.duePowerEvents(now) {
+				ns.firePowerEvent(p)
+			}
+		}
+	}
+}
+
+// duePowerEvents returns the events due at now and advances them to their
+// next firing time.
+func (ns *Namespace) duePowerEvents(now time.Time) []*PowerEvent {
+	ns.power.mu.Lock()
+	defer ns.power.mu.Unlock()
+
+	var res []*PowerEvent
+
+	for _, p := range ns.power.events {
+		if !p.next.After(now) {
+			res = append(res, p)
+
+			for !p.next.After(now) {
+				p.next = p.next.Add(24 * time.Hour)
+			}
+		}
+	}
+
+	return res
+}
+
+// firePowerEvent starts or stops the VMs matching the event's target. Errors
+// are logged rather than surfaced since there is no caller to report them to.
+func (ns *Namespace) firePowerEvent(p *PowerEvent) {
+	target, err := ns.expandPowerTarget(p.Target)
+	if err != nil {
+		log.Error("scheduled vm %v %v: %v", p.Action, p.Target, err)
+		return
+	}
+
+	log.Info("firing scheduled vm %v for %v", p.Action, p.Target)
+
+	switch p.Action {
+	case "start":
+		err = ns.Start(target)
+	case "stop":
+		err = ns.VMs.Stop(target)
+	}
+
+	if err != nil {
+		log.Error("scheduled vm %v %v: %v", p.Action, p.Target, err)
+	}
+}
+
+// expandPowerTarget expands a tag=key[:value] selector into a list of
+// matching VM names. Other targets are returned unchanged for VMs.Apply to
+// parse.
+func (ns *Namespace) expandPowerTarget(target string) (string, error) {
+	if !strings.HasPrefix(target, "tag=") {
+		return target, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(target, "tag="), ":", 2)
+
+	var names []string
+
+	ns.VMs.Apply(Wildcard, func(vm VM, _ bool) (bool, error) {
+		tag := vm.Tag(parts[0])
+		if tag == "" {
+			return false, nil
+		}
+
+		if len(parts) == 2 && tag != parts[1] {
+			return false, nil
+		}
+
+		names = append(names, vm.GetName())
+		return true, nil
+	})
+
+	if len(names) == 0 {
+		return "", fmt.Errorf("no VMs match %v", target)
+	}
+
+	return strings.Join(names, ","), nil
+}
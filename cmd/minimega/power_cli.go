@@ -0,0 +1,92 @@
+// Copyright 2015-2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var powerCLIHandlers = []minicli.Handler{
+	{ // vm schedule
+		HelpShort: "schedule daily start/stop of VM groups",
+		HelpLong: `
+Schedule daily start and stop times for groups of VMs so that diurnal
+enterprise activity patterns run unattended during multi-day experiments. The
+schedule is maintained by minimega per namespace and fires every day at the
+given wall-clock time (HH:MM, in the host's local time).
+
+VMs may be selected with the same targets as "vm start", or by tag using a
+tag=key[:value] selector which is re-evaluated each time the event fires. For
+example, to power workstations on at 08:00 and off at 17:00:
+
+	vm schedule start tag=role:workstation 08:00
+	vm schedule stop tag=role:workstation 17:00
+
+Called with no arguments, vm schedule lists the scheduled events along with
+their IDs. To delete an event by ID, or all events:
+
+	clear vm schedule 0
+	clear vm schedule
+
+Stopping pauses the VMs rather than powering them off, matching "vm stop", so
+the morning start resumes them in place. Errors at firing time, such as a tag
+matching no VMs, are recorded in the minimega log.`,
+		Patterns: []string{
+			"vm schedule",
+			"vm schedule <start,> <vm target> <time>",
+			"vm schedule <stop,> <vm target> <time>",
+		},
+		Call:    wrapBroadcastCLI(cliVMSchedule),
+		Suggest: wrapVMSuggest(VM_ANY_STATE, true),
+	},
+	{ // clear vm schedule
+		HelpShort: "remove scheduled start/stop events",
+		HelpLong: `
+Remove a scheduled VM start/stop event by ID, or all events when no ID is
+given. See "vm schedule" for more information.`,
+		Patterns: []string{
+			"clear vm schedule [id]",
+		},
+		Call: wrapBroadcastCLI(func(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+			return ns.ClearPowerSchedule(c.StringArgs["id"])
+		}),
+	},
+}
+
+func cliVMSchedule(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	action := ""
+	if c.BoolArgs["start"] {
+		action = "start"
+	} else if c.BoolArgs["stop"] {
+		action = "stop"
+	}
+
+	if action != "" {
+		return ns.SchedulePower(action, c.StringArgs["vm"], c.StringArgs["time"])
+	}
+
+	// list the scheduled events
+	resp.Header = []string{"id", "action", "target", "time", "next"}
+
+	events := ns.PowerScheduleInfo()
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+
+	for _, p := range events {
+		resp.Tabular = append(resp.Tabular, []string{
+			strconv.Itoa(p.ID),
+			p.Action,
+			p.Target,
+			p.At,
+			p.next.Format("2006-01-02 15:04"),
+		})
+	}
+
+	return nil
+}
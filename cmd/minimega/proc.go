@@ -6,6 +6,10 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	proc "github.com/c9s/goprocinfo/linux"
@@ -26,10 +30,39 @@ type ProcStats struct {
 	*proc.ProcessStat  // embed
 	*proc.ProcessStatm // embed
 
+	// VCPUs are the QEMU vCPU threads, keyed by thread ID. Only populated
+	// for KVM VMs.
+	VCPUs map[int]*VCPUStats
+
 	// time at beginning and end of data collection
 	Begin, End time.Time
 }
 
+// VCPUStats is a snapshot of a single QEMU vCPU thread.
+type VCPUStats struct {
+	// vCPU index, parsed from the thread name
+	ID int
+
+	// tics executed in user and kernel mode, from the per-thread stat file
+	Utime, Stime uint64
+
+	// cumulative time spent waiting to run in nanoseconds, from the
+	// per-thread schedstat file. The guest sees this as steal time.
+	WaitSum uint64
+
+	// time at beginning and end of data collection
+	Begin, End time.Time
+}
+
+// VCPUUsage is per-vCPU utilization computed between two snapshots.
+type VCPUUsage struct {
+	ID int
+
+	// CPU and Steal are fractions of the sampling window the vCPU spent
+	// executing and waiting to execute, respectively.
+	CPU, Steal float64
+}
+
 type VMProcStats struct {
 	Name, Namespace string
 
@@ -106,6 +139,40 @@ func (p *VMProcStats) CPU() float64 {
 	return p.cpuHelper(ProcCPU)
 }
 
+// VCPUs computes per-vCPU utilization and steal time between the two
+// snapshots, sorted by vCPU index.
+func (p *VMProcStats) VCPUs() []VCPUUsage {
+	var res []VCPUUsage
+
+	for pid, v := range p.A {
+		v2, ok := p.B[pid]
+		if !ok {
+			continue
+		}
+
+		for tid, a := range v.VCPUs {
+			b, ok := v2.VCPUs[tid]
+			if !ok || a.ID != b.ID {
+				continue
+			}
+
+			d := b.End.Sub(a.Begin).Seconds()
+
+			res = append(res, VCPUUsage{
+				ID:    a.ID,
+				CPU:   float64((b.Utime+b.Stime)-(a.Utime+a.Stime)) / ClkTck / d,
+				Steal: float64(b.WaitSum-a.WaitSum) / 1e9 / d,
+			})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	return res
+}
+
 func (p *VMProcStats) GuestCPU() float64 {
 	return p.cpuHelper(ProcGuestCPU)
 }
@@ -133,6 +200,70 @@ func GetProcStats(pid int) (*ProcStats, error) {
 	return p, nil
 }
 
+// GetVCPUStats enumerates the QEMU vCPU threads for the given PID and reads
+// their per-thread stats, keyed by thread ID. QEMU names vCPU threads
+// `CPU <id>/KVM`.
+func GetVCPUStats(pid int) (map[int]*VCPUStats, error) {
+	dirs, err := ioutil.ReadDir(fmt.Sprintf("/proc/%v/task", pid))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tasks: %v", err)
+	}
+
+	res := map[int]*VCPUStats{}
+
+	for _, dir := range dirs {
+		tid, err := strconv.Atoi(dir.Name())
+		if err != nil {
+			continue
+		}
+
+		base := fmt.Sprintf("/proc/%v/task/%v", pid, tid)
+
+		comm, err := ioutil.ReadFile(base + "/comm")
+		if err != nil {
+			// thread exited, skip it
+			continue
+		}
+
+		name := strings.TrimSpace(string(comm))
+		if !strings.HasPrefix(name, "CPU ") {
+			continue
+		}
+
+		id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "CPU "), "/KVM"))
+		if err != nil {
+			continue
+		}
+
+		v := &VCPUStats{
+			ID:    id,
+			Begin: time.Now(),
+		}
+
+		stat, err := proc.ReadProcessStat(base + "/stat")
+		if err != nil {
+			return nil, fmt.Errorf("unable to read thread stat: %v", err)
+		}
+		v.Utime, v.Stime = stat.Utime, stat.Stime
+
+		// schedstat is `<runtime> <waitsum> <timeslices>`, in nanoseconds
+		schedstat, err := ioutil.ReadFile(base + "/schedstat")
+		if err != nil {
+			return nil, fmt.Errorf("unable to read thread schedstat: %v", err)
+		}
+
+		if f := strings.Fields(string(schedstat)); len(f) == 3 {
+			v.WaitSum, _ = strconv.ParseUint(f[1], 10, 64)
+		}
+
+		v.End = time.Now()
+
+		res[tid] = v
+	}
+
+	return res, nil
+}
+
 // ProcCPU computes CPU % between two snapshots of proc
 func ProcCPU(p, p2 *ProcStats) float64 {
 	// compute number of tics used in window by process
@@ -0,0 +1,155 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// userPolicy restricts what a user may do over the command socket. Policies
+// are keyed by the username resolved from the connection's peer credentials.
+type userPolicy struct {
+	// Namespaces the user may target, empty means any
+	Namespaces map[string]bool
+
+	// ReadOnly rejects commands that modify state, see Command.IsReadOnly
+	ReadOnly bool
+}
+
+var rbac = struct {
+	sync.Mutex
+
+	// policies keyed by username
+	policies map[string]*userPolicy
+}{
+	policies: map[string]*userPolicy{},
+}
+
+// rbacPolicy returns the policy for a user, creating an empty one if needed.
+// The caller must hold the rbac lock.
+func rbacPolicy(username string) *userPolicy {
+	p := rbac.policies[username]
+	if p == nil {
+		p = &userPolicy{Namespaces: map[string]bool{}}
+		rbac.policies[username] = p
+	}
+
+	return p
+}
+
+// peerUser resolves the username on the other end of a unix socket via
+// SO_PEERCRED. Returns "" when the peer cannot be identified.
+func peerUser(c net.Conn) string {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return ""
+	}
+
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		log.Error("unable to get raw conn: %v", err)
+		return ""
+	}
+
+	var cred *syscall.Ucred
+	var err2 error
+
+	err = raw.Control(func(fd uintptr) {
+		cred, err2 = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err == nil {
+		err = err2
+	}
+	if err != nil {
+		log.Error("unable to get peer credentials: %v", err)
+		return ""
+	}
+
+	if u, err := user.LookupId(strconv.Itoa(int(cred.Uid))); err == nil {
+		return u.Username
+	}
+
+	return strconv.Itoa(int(cred.Uid))
+}
+
+// rbacCheck enforces the policy for a user against a command, returning an
+// error when the command should be rejected. Root and users without a policy
+// are unrestricted so that administrators cannot lock themselves out.
+func rbacCheck(username string, cmd *minicli.Command) error {
+	if username == "" || username == "root" || cmd.Nop {
+		return nil
+	}
+
+	rbac.Lock()
+	p := rbac.policies[username]
+	rbac.Unlock()
+
+	if p == nil {
+		return nil
+	}
+
+	if p.ReadOnly && !cmd.IsReadOnly() {
+		return fmt.Errorf("user %v may only run read-only commands", username)
+	}
+
+	if len(p.Namespaces) == 0 {
+		return nil
+	}
+
+	// figure out the namespace the command targets -- an explicit
+	// `namespace <name> ...` wins, otherwise the command acts on the active
+	// namespace
+	target := GetNamespace().Name
+	for c := cmd; c != nil; c = c.Subcommand {
+		if strings.HasPrefix(c.Original, "namespace ") && c.StringArgs["name"] != "" {
+			target = c.StringArgs["name"]
+		}
+	}
+
+	if !p.Namespaces[target] {
+		return fmt.Errorf("user %v is not allowed to use namespace %v", username, target)
+	}
+
+	return nil
+}
+
+// rbacTabular returns the policies in tabular form.
+func rbacTabular() [][]string {
+	rbac.Lock()
+	defer rbac.Unlock()
+
+	res := [][]string{}
+
+	for username, p := range rbac.policies {
+		namespaces := []string{}
+		for namespace := range p.Namespaces {
+			namespaces = append(namespaces, namespace)
+		}
+		sort.Strings(namespaces)
+
+		allowed := Wildcard
+		if len(namespaces) > 0 {
+			allowed = strings.Join(namespaces, ",")
+		}
+
+		res = append(res, []string{
+			username,
+			allowed,
+			strconv.FormatBool(p.ReadOnly),
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var rbacCLIHandlers = []minicli.Handler{
+	{ // rbac
+		HelpShort: "control per-user policies for the command socket",
+		HelpLong: `
+Control per-user policies for clients attached to the command socket,
+turning the shared control socket into a multi-user control plane. Clients
+are identified by the peer credentials (SO_PEERCRED) of their unix socket
+connection, so policies key on local usernames.
+
+Users without a policy, and root, are unrestricted so that administrators
+cannot lock themselves out. Once a policy exists for a user, it applies to
+every command they send via "minimega -attach" or "minimega -e".
+
+To restrict a user to specific namespaces (commands targeting any other
+namespace, including via "namespace <name> (command)", are rejected):
+
+	rbac allow alice red
+	rbac allow alice red-staging
+
+To restrict a user to commands that only report state:
+
+	rbac read-only bob true
+
+Called with no arguments, rbac lists the policies on each host. Policies are
+per host -- set them on every host that users attach to, e.g. via "mesh send
+all rbac ...".`,
+		Patterns: []string{
+			"rbac",
+			"rbac <allow,> <user> <namespace>",
+			"rbac <read-only,> <user> [true,false]",
+		},
+		Call: wrapSimpleCLI(cliRBAC),
+	},
+	{ // clear rbac
+		HelpShort: "clear rbac policies",
+		HelpLong: `
+Clear the policy for one user, or all policies. Users without a policy are
+unrestricted. See "help rbac" for more information.`,
+		Patterns: []string{
+			"clear rbac [user]",
+		},
+		Call: wrapSimpleCLI(cliRBACClear),
+	},
+}
+
+func cliRBAC(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	switch {
+	case c.BoolArgs["allow"]:
+		rbac.Lock()
+		defer rbac.Unlock()
+
+		p := rbacPolicy(c.StringArgs["user"])
+		p.Namespaces[c.StringArgs["namespace"]] = true
+	case c.BoolArgs["read-only"]:
+		rbac.Lock()
+		defer rbac.Unlock()
+
+		if c.BoolArgs["true"] || c.BoolArgs["false"] {
+			rbacPolicy(c.StringArgs["user"]).ReadOnly = c.BoolArgs["true"]
+		} else if p := rbac.policies[c.StringArgs["user"]]; p != nil {
+			resp.Response = strconv.FormatBool(p.ReadOnly)
+		} else {
+			resp.Response = "false"
+		}
+	default:
+		// must want to display the policies
+		resp.Header = []string{"user", "namespaces", "read-only"}
+		resp.Tabular = rbacTabular()
+	}
+
+	return nil
+}
+
+func cliRBACClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	rbac.Lock()
+	defer rbac.Unlock()
+
+	if username := c.StringArgs["user"]; username != "" {
+		delete(rbac.policies, username)
+		return nil
+	}
+
+	rbac.policies = map[string]*userPolicy{}
+	return nil
+}
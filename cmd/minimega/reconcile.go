@@ -0,0 +1,286 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+const (
+	// meshLivenessTick is how often the mesh membership is compared against
+	// the hosts that namespaces have scheduled VMs on.
+	meshLivenessTick = 10 * time.Second
+
+	// meshLivenessMisses is how many consecutive ticks a host must be absent
+	// from the mesh before its VMs are marked lost, so that a transient drop
+	// (e.g. a mesh MSA timeout during heavy load) doesn't declare VMs dead.
+	meshLivenessMisses = 3
+)
+
+// reconciler tracks which VMs the scheduler placed on which hosts so that VMs
+// on a host that drops out of the mesh can be identified and relaunched
+// elsewhere. One per namespace, see `ns reconcile`. It has its own lock since
+// the liveness monitor updates it outside the command flow.
+type reconciler struct {
+	mu sync.Mutex // guards below
+
+	// launches records the launch configs the scheduler sent to each host
+	launches map[string][]*QueuedVMs
+
+	// lost holds the launch records for hosts that dropped out of the mesh
+	lost map[string][]*QueuedVMs
+}
+
+func newReconciler() *reconciler {
+	return &reconciler{
+		launches: make(map[string][]*QueuedVMs),
+		lost:     make(map[string][]*QueuedVMs),
+	}
+}
+
+// recordLaunch remembers what the scheduler sent to a host so that the VMs
+// can be relaunched from the same configs if the host disappears.
+func (n *Namespace) recordLaunch(host string, q *QueuedVMs) {
+	r := n.reconcile
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// copy so that later edits to the queued names don't change the record
+	q2 := *q
+	q2.Names = append([]string{}, q.Names...)
+
+	r.launches[host] = append(r.launches[host], &q2)
+}
+
+// markLost moves the launch records for a dead host into the lost set and
+// fires vm-lost hooks for each VM that was scheduled there. Hook failures are
+// logged since there is nothing to veto -- the host is already gone.
+func (n *Namespace) markLost(host string) {
+	r := n.reconcile
+
+	r.mu.Lock()
+	qs := r.launches[host]
+	if len(qs) == 0 {
+		r.mu.Unlock()
+		return
+	}
+
+	delete(r.launches, host)
+	r.lost[host] = append(r.lost[host], qs...)
+	r.mu.Unlock()
+
+	total := 0
+	for _, q := range qs {
+		total += len(q.Names)
+	}
+
+	log.Warn("namespace %v: host %v dropped out of the mesh, marking %v VMs as lost", n.Name, host, total)
+
+	for _, q := range qs {
+		for _, name := range q.Names {
+			data := &hookData{
+				Event:     HookVMLost,
+				Name:      name,
+				Namespace: n.Name,
+				Type:      q.VMType.String(),
+				State:     "LOST",
+				Tags:      q.VMConfig.Tags,
+			}
+
+			if err := n.RunHooks(HookVMLost, data); err != nil {
+				log.Error("%v: %v", name, err)
+			}
+		}
+	}
+}
+
+// markFound moves the launch records for a host back out of the lost set,
+// called when a host that was presumed dead rejoins the mesh. The VMs were
+// likely running the whole time, e.g. across a network partition.
+func (n *Namespace) markFound(host string) {
+	r := n.reconcile
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	qs := r.lost[host]
+	if len(qs) == 0 {
+		return
+	}
+
+	log.Info("namespace %v: host %v rejoined the mesh, unmarking its lost VMs", n.Name, host)
+
+	delete(r.lost, host)
+	r.launches[host] = append(r.launches[host], qs...)
+}
+
+// LostVMs returns the VMs marked lost in tabular form, sorted by host and
+// then name.
+func (n *Namespace) LostVMs() [][]string {
+	r := n.reconcile
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := [][]string{}
+
+	for host, qs := range r.lost {
+		for _, q := range qs {
+			for _, name := range q.Names {
+				res = append(res, []string{host, name, q.VMType.String(), "LOST"})
+			}
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i][0] != res[j][0] {
+			return res[i][0] < res[j][0]
+		}
+		return res[i][1] < res[j][1]
+	})
+
+	return res
+}
+
+// Reconcile removes hosts that dropped out of the mesh from the namespace,
+// requeues the VMs that were scheduled on them from their recorded launch
+// configs, and schedules them onto the remaining hosts. Returns the number of
+// VMs relaunched.
+func (n *Namespace) Reconcile() (int, error) {
+	r := n.reconcile
+
+	r.mu.Lock()
+	hosts := []string{}
+	for host := range r.lost {
+		hosts = append(hosts, host)
+	}
+	r.mu.Unlock()
+
+	if len(hosts) == 0 {
+		return 0, nil
+	}
+
+	// VMs that the ledger has for a dead host may have been rescheduled to a
+	// reachable host in the meantime -- don't relaunch those
+	running := map[string]bool{}
+	for _, vm := range globalVMs(n) {
+		running[vm.GetName()] = true
+	}
+
+	total := 0
+
+	r.mu.Lock()
+	for _, host := range hosts {
+		delete(n.Hosts, host)
+
+		for _, q := range r.lost[host] {
+			names := []string{}
+			for _, name := range q.Names {
+				if !running[name] {
+					names = append(names, name)
+				}
+			}
+
+			if len(names) == 0 {
+				continue
+			}
+
+			q.Names = names
+			total += len(names)
+			n.queue = append(n.queue, q)
+		}
+
+		delete(r.lost, host)
+	}
+	r.mu.Unlock()
+
+	if total == 0 {
+		return 0, nil
+	}
+
+	// the assignment, if any, predates the host removals
+	n.assignment = nil
+
+	return total, n.Schedule(false)
+}
+
+// meshLivenessStart launches the monitor that compares mesh membership
+// against the hosts each namespace has VMs on, marking VMs on vanished hosts
+// as lost.
+func meshLivenessStart() {
+	go func() {
+		// consecutive ticks each host has been missing from the mesh
+		misses := map[string]int{}
+
+		for range time.Tick(meshLivenessTick) {
+			meshLivenessSweep(misses)
+		}
+	}()
+}
+
+// meshLivenessSweep runs a single liveness check, updating the per-host miss
+// counts and telling namespaces about hosts that died or came back.
+func meshLivenessSweep(misses map[string]int) {
+	alive := map[string]bool{hostname: true}
+	for host := range meshageNode.Mesh() {
+		alive[host] = true
+	}
+
+	namespaceLock.Lock()
+	all := []*Namespace{}
+	for _, ns := range namespaces {
+		all = append(all, ns)
+	}
+	namespaceLock.Unlock()
+
+	// hosts any namespace still cares about
+	tracked := map[string]bool{}
+
+	for _, ns := range all {
+		r := ns.reconcile
+
+		r.mu.Lock()
+		for host := range r.launches {
+			tracked[host] = true
+		}
+		for host := range r.lost {
+			tracked[host] = true
+		}
+		r.mu.Unlock()
+	}
+
+	for host := range tracked {
+		if alive[host] {
+			delete(misses, host)
+
+			for _, ns := range all {
+				ns.markFound(host)
+			}
+
+			continue
+		}
+
+		misses[host] += 1
+		if misses[host] < meshLivenessMisses {
+			continue
+		}
+
+		for _, ns := range all {
+			ns.markLost(host)
+		}
+	}
+
+	// don't let counts for forgotten hosts accumulate
+	for host := range misses {
+		if !tracked[host] {
+			delete(misses, host)
+		}
+	}
+}
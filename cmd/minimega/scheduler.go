@@ -21,6 +21,16 @@ type Scheduler struct {
 	// colocated are VMs that need to be scheduled with another VM, indexed by
 	// the name of the VM to be colocated with
 	colocated map[string][]*QueuedVMs
+
+	// memOvercommit is the maximum ratio of committed memory to total memory
+	// allowed per host, zero means unlimited. When memStrict is set,
+	// exceeding the ratio aborts the schedule instead of logging a warning.
+	// See `ns mem-overcommit`.
+	memOvercommit float64
+	memStrict     bool
+
+	// hosts that we have already warned about overcommitting
+	memWarned map[string]bool
 }
 
 // hostSortBy defines the ordering of hosts based on some notion of load
@@ -45,8 +55,21 @@ func (s *Scheduler) Schedule() (map[string][]*QueuedVMs, error) {
 
 	if len(s.hosts) == 1 {
 		log.Warn("only one host in namespace, scheduling all VMs on it")
+
+		// still track what we are committing so that the overcommit check
+		// applies to single host namespaces too
+		host := s.hosts[0]
+		for _, q := range s.queue {
+			for range q.Names {
+				host.increment(q.VMConfig)
+			}
+		}
+		if err := s.checkMemCommit(host); err != nil {
+			return nil, err
+		}
+
 		res := map[string][]*QueuedVMs{
-			s.hosts[0].Name: s.queue,
+			host.Name: s.queue,
 		}
 		return res, nil
 	}
@@ -179,6 +202,10 @@ func (s *Scheduler) add(host *HostStats, name string, q *QueuedVMs) error {
 	// update commit based on this VM's specs
 	host.increment(q.VMConfig)
 
+	if err := s.checkMemCommit(host); err != nil {
+		return err
+	}
+
 	// schedule all floating VMs on this host as well
 	for _, q := range s.colocated[name] {
 		for _, name2 := range q.Names {
@@ -206,6 +233,33 @@ func (s *Scheduler) add(host *HostStats, name string, q *QueuedVMs) error {
 	return nil
 }
 
+// checkMemCommit tests a host's committed memory against the overcommit
+// limit, returning an error when strict and warning once per host otherwise.
+func (s *Scheduler) checkMemCommit(host *HostStats) error {
+	if s.memOvercommit == 0 || host.MemTotal <= 0 {
+		return nil
+	}
+
+	ratio := float64(host.MemCommit) / float64(host.MemTotal)
+	if ratio <= s.memOvercommit {
+		return nil
+	}
+
+	if s.memStrict {
+		return fmt.Errorf("memory commit on %v is %.2fx total memory, exceeds limit of %.2fx", host.Name, ratio, s.memOvercommit)
+	}
+
+	if s.memWarned == nil {
+		s.memWarned = map[string]bool{}
+	}
+	if !s.memWarned[host.Name] {
+		s.memWarned[host.Name] = true
+		log.Warn("memory commit on %v is %.2fx total memory, exceeds limit of %.2fx", host.Name, ratio, s.memOvercommit)
+	}
+
+	return nil
+}
+
 func (by hostSortBy) Sort(hosts []*HostStats) {
 	h := &hostSorter{
 		hosts: hosts,
@@ -39,7 +39,8 @@ that does not return.`,
 Execute a command under the credentials of the running user.
 
 Commands run in the background and control returns immediately. Any output is
-logged at the "info" level.`,
+logged at the "info" level and retained per job, see "jobs" to list, read
+output from, or kill background jobs.`,
 		Patterns: []string{
 			"background <command>...",
 		},
@@ -84,6 +85,32 @@ Get the standard error of a background command.
 		},
 		Call: cliBackgroundError,
 	},
+	{ // jobs
+		HelpShort: "manage background jobs",
+		HelpLong: `
+Manage commands started with "background". Without arguments, jobs lists all
+background jobs, running or finished:
+
+	jobs
+
+To print the output a job has produced so far, including while it is still
+running:
+
+	jobs output <id>
+
+To kill a running job:
+
+	jobs kill <id>
+
+Output is kept per job until the job is cleared with "clear background-status"
+or flushed to make room for new jobs.`,
+		Patterns: []string{
+			"jobs",
+			"jobs <output,> <id>",
+			"jobs <kill,> <id>",
+		},
+		Call: cliJobs,
+	},
 	{ //clear background-status
 		HelpShort: "Clear background-status information",
 		Patterns: []string{
@@ -139,6 +166,30 @@ type BackgroundProcess struct {
 	TimeEnd   time.Time
 	Stdout    string
 	Stderr    string
+
+	// live output buffers so that "jobs output" works while the process is
+	// still running
+	out, err *syncBuf
+}
+
+// syncBuf is a bytes.Buffer that is safe to read while the process writes
+type syncBuf struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *syncBuf) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buf.Write(p)
+}
+
+func (s *syncBuf) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buf.String()
 }
 
 func (bp BackgroundProcess) ToTabular() []string {
@@ -201,22 +252,24 @@ func cliBackground(c *minicli.Command, respChan chan<- minicli.Responses) {
 		Dir:  "",
 	}
 
-	var sOut bytes.Buffer //stdout buffer
-	var sErr bytes.Buffer //stderr buffer
+	sOut := &syncBuf{} //stdout buffer
+	sErr := &syncBuf{} //stderr buffer
 
 	backgroundProcessesRWLock.Lock()
 
 	id := backgroundProcessNextID
 
 	bgWriter := &BackgroundWriter{ProcessID: id}
-	cmd.Stdout = io.MultiWriter(&sOut, bgWriter)
-	cmd.Stderr = io.MultiWriter(&sErr, bgWriter)
+	cmd.Stdout = io.MultiWriter(sOut, bgWriter)
+	cmd.Stderr = io.MultiWriter(sErr, bgWriter)
 
 	bp := &BackgroundProcess{
 		ID:        id,
 		Command:   cmd,
 		Running:   true,
 		TimeStart: time.Now(),
+		out:       sOut,
+		err:       sErr,
 	}
 	backgroundProcessNextID += 1
 	backgroundProcesses[bp.ID] = bp
@@ -367,9 +420,69 @@ func cliBackgroundError(c *minicli.Command, respChan chan<- minicli.Responses) {
 
 }
 
+// cliJobs handles the jobs subcommands: listing jobs, reading a job's output
+// (even while it runs), and killing a running job.
+func cliJobs(c *minicli.Command, respChan chan<- minicli.Responses) {
+	if !c.BoolArgs["output"] && !c.BoolArgs["kill"] {
+		cliBackgroundStatusAll(respChan)
+		return
+	}
+
+	idInt, err := strconv.ParseInt(c.StringArgs["id"], 10, 64)
+	if err != nil {
+		respChan <- errResp(err)
+		return
+	}
+
+	backgroundProcessesRWLock.RLock()
+	entry, ok := backgroundProcesses[int(idInt)]
+	backgroundProcessesRWLock.RUnlock()
+
+	if !ok {
+		respChan <- errResp(fmt.Errorf("provided id does not exist in background process table"))
+		return
+	}
+
+	if c.BoolArgs["kill"] {
+		backgroundProcessesRWLock.RLock()
+		running := entry.Running
+		backgroundProcessesRWLock.RUnlock()
+
+		if !running || entry.Command.Process == nil {
+			respChan <- errResp(fmt.Errorf("job %v is not running", entry.ID))
+			return
+		}
+
+		if err := entry.Command.Process.Kill(); err != nil {
+			respChan <- errResp(err)
+			return
+		}
+
+		respChan <- minicli.Responses{&minicli.Response{
+			Host:     hostname,
+			Response: fmt.Sprintf("killed job %v", entry.ID),
+		}}
+		return
+	}
+
+	// combine the output streams, labelling stderr when present
+	out := entry.out.String()
+	if err := entry.err.String(); err != "" {
+		if out != "" {
+			out += "\n"
+		}
+		out += "[stderr]\n" + err
+	}
+
+	respChan <- minicli.Responses{&minicli.Response{
+		Host:     hostname,
+		Response: out,
+	}}
+}
+
 func cliClearBackgroundStatus(c *minicli.Command, respChan chan<- minicli.Responses) {
 	backgroundProcessesRWLock.Lock()
-	backgroundProcessesRWLock.Unlock()
+	defer backgroundProcessesRWLock.Unlock()
 
 	var keys []int
 	for key, entry := range backgroundProcesses {
@@ -399,7 +512,7 @@ func cliClearBackgroundStatus(c *minicli.Command, respChan chan<- minicli.Respon
 // flush any statuses over the max length, sorted by ending time
 func flushOldBackgroundStatus() {
 	backgroundProcessesRWLock.Lock()
-	backgroundProcessesRWLock.Unlock()
+	defer backgroundProcessesRWLock.Unlock()
 
 	if len(backgroundProcesses) < backgroundProcessMaxLen {
 		return
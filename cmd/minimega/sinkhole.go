@@ -0,0 +1,330 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+
+	"github.com/miekg/dns"
+)
+
+// seconds between the NTP epoch (1900) and the Unix epoch (1970)
+const ntpEpochOffset = 2208988800
+
+// sinkhole answers all DNS or NTP traffic on a VLAN with configurable
+// responses, so that malware detonation enclaves that must never reach the
+// real Internet still see plausible services. The sinkhole listens on a host
+// tap on the VLAN and redirects the service's port to itself, so guests that
+// use the sinkhole IP as their resolver, time server, or default gateway are
+// answered no matter which server they actually asked for.
+type sinkhole struct {
+	// Service is "dns" or "ntp"
+	Service string
+
+	// VLAN alias the sinkhole is serving
+	VLAN string
+
+	// Tap created on the default bridge for the sinkhole
+	Tap string
+
+	// IP (in CIDR form) assigned to the tap
+	IP string
+
+	// Response is the IP answered for every DNS query, or the fixed time
+	// reported by NTP. Empty means the tap's own IP or the host clock.
+	Response string
+
+	// fixed time to report, parsed from Response for NTP sinkholes
+	when time.Time
+
+	dnsUDP *dns.Server
+	dnsTCP *dns.Server
+	ntp    *net.UDPConn
+
+	// iptables redirect rules we added, replayed with -D on teardown
+	redirects [][]string
+}
+
+func (s *sinkhole) key() string {
+	return fmt.Sprintf("%v:%v", s.Service, s.VLAN)
+}
+
+// StartSinkhole creates a sinkhole for the given service on a VLAN. The CIDR
+// is assigned to a new host tap on the default bridge. For DNS, response is
+// the IP answered for every query, defaulting to the sinkhole itself. For
+// NTP, response is an optional fixed RFC 3339 time to report instead of the
+// host clock, useful for detonating time-triggered samples.
+func (ns *Namespace) StartSinkhole(service, alias string, vlan int, cidr, response string) error {
+	s := &sinkhole{
+		Service:  service,
+		VLAN:     alias,
+		IP:       cidr,
+		Response: response,
+	}
+
+	if _, ok := ns.sinkholes[s.key()]; ok {
+		return fmt.Errorf("sinkhole already exists: %v", s.key())
+	}
+
+	ip, _, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("expected CIDR (e.g. 10.0.0.1/24): %v", err)
+	}
+
+	switch service {
+	case "dns":
+		if response == "" {
+			s.Response = ip.String()
+		}
+
+		if net.ParseIP(s.Response) == nil {
+			return fmt.Errorf("invalid response IP: `%v`", s.Response)
+		}
+	case "ntp":
+		if response != "" {
+			s.when, err = time.Parse(time.RFC3339, response)
+			if err != nil {
+				return fmt.Errorf("expected RFC 3339 time (e.g. 2020-01-02T15:04:05Z): %v", err)
+			}
+		}
+	}
+
+	tap, err := hostTapCreate("", "", vlan)
+	if err != nil {
+		return err
+	}
+	ns.Taps[tap] = true
+	s.Tap = tap
+
+	destroy := func() {
+		s.stop()
+		if err := hostTapDelete(ns, tap); err != nil {
+			log.Error("zombie sinkhole tap -- %v %v", tap, err)
+		}
+	}
+
+	if out, err := privProcessWrapper("ip", "addr", "add", "dev", tap, cidr); err != nil {
+		destroy()
+		return fmt.Errorf("ip error %v: `%v`", err, out)
+	}
+
+	if err := s.listen(ip); err != nil {
+		destroy()
+		return err
+	}
+
+	if err := s.redirect(); err != nil {
+		destroy()
+		return err
+	}
+
+	ns.sinkholes[s.key()] = s
+
+	return nil
+}
+
+// ClearSinkholes tears down sinkholes and their taps and redirect rules. An
+// empty name or the wildcard clears all sinkholes.
+func (ns *Namespace) ClearSinkholes(name string) error {
+	var errs []error
+
+	for _, s := range ns.sinkholes {
+		if name != "" && name != Wildcard && s.key() != name {
+			continue
+		}
+
+		s.stop()
+
+		if err := hostTapDelete(ns, s.Tap); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", s.Tap, err))
+		}
+
+		delete(ns.sinkholes, s.key())
+	}
+
+	return makeErrSlice(errs)
+}
+
+// listen starts the sinkhole's servers on the given IP.
+func (s *sinkhole) listen(ip net.IP) error {
+	switch s.Service {
+	case "dns":
+		addr := net.JoinHostPort(ip.String(), "53")
+
+		mux := dns.NewServeMux()
+		mux.HandleFunc(".", s.handleDNS)
+
+		s.dnsUDP = &dns.Server{Addr: addr, Net: "udp", Handler: mux}
+		s.dnsTCP = &dns.Server{Addr: addr, Net: "tcp", Handler: mux}
+
+		for _, server := range []*dns.Server{s.dnsUDP, s.dnsTCP} {
+			go func(server *dns.Server) {
+				if err := server.ListenAndServe(); err != nil {
+					log.Error("dns sinkhole (%v): %v", server.Net, err)
+				}
+			}(server)
+		}
+
+		return nil
+	case "ntp":
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: 123})
+		if err != nil {
+			return fmt.Errorf("ntp sinkhole listen: %v", err)
+		}
+
+		s.ntp = conn
+		go s.serveNTP()
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown sinkhole service: %v", s.Service)
+}
+
+// stop shuts down the sinkhole's servers and deletes its redirect rules.
+func (s *sinkhole) stop() {
+	for _, server := range []*dns.Server{s.dnsUDP, s.dnsTCP} {
+		if server != nil {
+			if err := server.Shutdown(); err != nil {
+				log.Error("dns sinkhole (%v): %v", server.Net, err)
+			}
+		}
+	}
+	s.dnsUDP, s.dnsTCP = nil, nil
+
+	if s.ntp != nil {
+		s.ntp.Close()
+		s.ntp = nil
+	}
+
+	for _, rule := range s.redirects {
+		args := append([]string{"iptables", "-t", "nat", "-D"}, rule...)
+		if out, err := privProcessWrapper(args...); err != nil {
+			log.Error("removing sinkhole redirect %v: `%v`", err, out)
+		}
+	}
+	s.redirects = nil
+}
+
+// redirect installs iptables rules that redirect the service's port on the
+// sinkhole's tap to the sinkhole itself, regardless of the destination IP
+// that the guest asked for.
+func (s *sinkhole) redirect() error {
+	rules := [][]string{}
+
+	switch s.Service {
+	case "dns":
+		rules = append(rules,
+			[]string{"PREROUTING", "-i", s.Tap, "-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-ports", "53"},
+			[]string{"PREROUTING", "-i", s.Tap, "-p", "tcp", "--dport", "53", "-j", "REDIRECT", "--to-ports", "53"},
+		)
+	case "ntp":
+		rules = append(rules,
+			[]string{"PREROUTING", "-i", s.Tap, "-p", "udp", "--dport", "123", "-j", "REDIRECT", "--to-ports", "123"},
+		)
+	}
+
+	for _, rule := range rules {
+		args := append([]string{"iptables", "-t", "nat", "-A"}, rule...)
+		if out, err := privProcessWrapper(args...); err != nil {
+			return fmt.Errorf("sinkhole redirect %v: `%v`", err, out)
+		}
+
+		s.redirects = append(s.redirects, rule)
+	}
+
+	return nil
+}
+
+// handleDNS answers every query with the configured response IP.
+func (s *sinkhole) handleDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	ip := net.ParseIP(s.Response)
+
+	for _, q := range r.Question {
+		log.Debug("dns sinkhole query: %v", q.Name)
+
+		hdr := dns.RR_Header{
+			Name:  q.Name,
+			Class: dns.ClassINET,
+			Ttl:   dnsTTL,
+		}
+
+		switch q.Qtype {
+		case dns.TypeA:
+			if v4 := ip.To4(); v4 != nil {
+				hdr.Rrtype = dns.TypeA
+				m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: v4})
+			}
+		case dns.TypeAAAA:
+			if ip.To4() == nil {
+				hdr.Rrtype = dns.TypeAAAA
+				m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: ip})
+			}
+		}
+	}
+
+	w.WriteMsg(m)
+}
+
+// serveNTP answers NTP client requests with the host clock or the configured
+// fixed time. Runs until the sinkhole's socket is closed.
+func (s *sinkhole) serveNTP() {
+	buf := make([]byte, 512)
+
+	for {
+		n, addr, err := s.ntp.ReadFromUDP(buf)
+		if err != nil {
+			// expected when the sinkhole is stopped
+			log.Debug("ntp sinkhole exited: %v", err)
+			return
+		}
+
+		// NTP packets are at least 48 bytes
+		if n < 48 {
+			continue
+		}
+
+		now := time.Now()
+		if !s.when.IsZero() {
+			now = s.when
+		}
+
+		res := make([]byte, 48)
+
+		// LI = 0, version copied from the client, mode = 4 (server)
+		res[0] = buf[0]&0x38 | 4
+		res[1] = 2 // stratum
+
+		// reference, receive, and transmit timestamps are all "now" and
+		// the originate timestamp echoes the client's transmit timestamp
+		ntpTimestamp(res[16:], now)
+		copy(res[24:32], buf[40:48])
+		ntpTimestamp(res[32:], now)
+		ntpTimestamp(res[40:], now)
+
+		if _, err := s.ntp.WriteToUDP(res, addr); err != nil {
+			log.Debug("ntp sinkhole write: %v", err)
+		}
+	}
+}
+
+// ntpTimestamp writes t into b as a 64-bit NTP timestamp.
+func ntpTimestamp(b []byte, t time.Time) {
+	secs := uint64(t.Unix()) + ntpEpochOffset
+	frac := uint64(t.Nanosecond()) << 32 / 1e9
+
+	for i := 0; i < 4; i++ {
+		b[i] = byte(secs >> (24 - 8*i))
+		b[4+i] = byte(frac >> (24 - 8*i))
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"sort"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var sinkholeCLIHandlers = []minicli.Handler{
+	{ // sinkhole
+		HelpShort: "answer DNS or NTP traffic on a VLAN",
+		HelpLong: `
+Run built-in sinkhole services that answer DNS or NTP traffic on a VLAN with
+configurable responses, so that malware detonation enclaves that must never
+reach the real Internet still see plausible services.
+
+Each sinkhole creates a host tap on the default bridge carrying the given IP
+(in CIDR form) and redirects its service's port on that tap to itself, so it
+answers no matter which server the guest actually asked for. Guests reach the
+sinkhole when it is their configured resolver or time server, or for
+hard-coded servers (e.g. 8.8.8.8), when the sinkhole IP is their default
+gateway.
+
+The DNS sinkhole answers every query with the optional response IP,
+defaulting to the sinkhole itself so that detonated samples connect straight
+back into the enclave:
+
+	sinkhole dns DMZ 10.0.0.254/24
+	sinkhole dns DMZ 10.0.0.254/24 10.0.0.100
+
+The NTP sinkhole reports the host clock, or an optional fixed RFC 3339 time,
+useful for detonating time-triggered samples:
+
+	sinkhole ntp DMZ 10.0.0.254/24
+	sinkhole ntp DMZ 10.0.0.254/24 2030-01-01T00:00:00Z
+
+When run without arguments, sinkhole lists the running sinkholes. Sinkholes
+are named by service and VLAN, e.g. "dns:DMZ". To stop one or all sinkholes:
+
+	clear sinkhole dns:DMZ
+	clear sinkhole`,
+		Patterns: []string{
+			"sinkhole",
+			"sinkhole <dns,> <vlan> <ip> [response]",
+			"sinkhole <ntp,> <vlan> <ip> [time]",
+		},
+		Call: wrapSimpleCLI(cliSinkhole),
+		Suggest: wrapSuggest(func(ns *Namespace, val, prefix string) []string {
+			if val == "vlan" {
+				return cliVLANSuggest(ns, prefix)
+			}
+			return nil
+		}),
+	},
+	{ // clear sinkhole
+		HelpShort: "stop sinkhole services",
+		HelpLong: `
+Stops one or all sinkholes, deleting their taps and redirect rules. See "help
+sinkhole" for more information.`,
+		Patterns: []string{
+			"clear sinkhole [name]",
+		},
+		Call: wrapSimpleCLI(cliSinkholeClear),
+	},
+}
+
+func cliSinkhole(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["dns"] || c.BoolArgs["ntp"] {
+		service := "dns"
+		response := c.StringArgs["response"]
+
+		if c.BoolArgs["ntp"] {
+			service = "ntp"
+			response = c.StringArgs["time"]
+		}
+
+		alias := c.StringArgs["vlan"]
+
+		vlan, err := lookupVLAN(ns.Name, alias)
+		if err != nil {
+			return err
+		}
+
+		return ns.StartSinkhole(service, alias, vlan, c.StringArgs["ip"], response)
+	}
+
+	resp.Header = []string{"service", "vlan", "tap", "ip", "response"}
+
+	keys := []string{}
+	for k := range ns.sinkholes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := ns.sinkholes[k]
+
+		resp.Tabular = append(resp.Tabular, []string{
+			s.Service,
+			s.VLAN,
+			s.Tap,
+			s.IP,
+			s.Response,
+		})
+	}
+
+	return nil
+}
+
+func cliSinkholeClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	return ns.ClearSinkholes(c.StringArgs["name"])
+}
@@ -35,6 +35,19 @@ type HostStats struct {
 	Uptime        time.Duration
 }
 
+// HostTopStats is a summary of per-host counters useful for spotting
+// overloaded nodes during large launches. Available over the API via the
+// Data field of `host top` responses.
+type HostTopStats struct {
+	Name     string
+	Load     string
+	MemTotal int
+	MemFree  int
+	VMs      int
+	Taps     int
+	IOWait   float64
+}
+
 var hostCLIHandlers = []minicli.Handler{
 	{ // host
 		HelpShort: "report information about hosts",
@@ -72,12 +85,39 @@ All VM-based stats are computed across namespaces.`,
 			"host <vms,>",
 			"host <vmlimit,>",
 		},
-		Call: wrapBroadcastCLI(cliHost),
+		Call:     wrapBroadcastCLI(cliHost),
+		ReadOnly: true,
+	},
+	{ // host top
+		HelpShort: "view host resource utilization",
+		HelpLong: `
+View a continuously-updating style summary of resource utilization for hosts
+in the current namespace:
+
+- load    : system load average
+- memtotal: total memory in MB
+- memfree : free memory in MB
+- vms     : number of VMs across namespaces
+- taps    : number of taps across namespaces
+- iowait  : percentage of CPU time spent waiting on IO
+
+The optional duration specifies the length of the sampling window in seconds
+for the iowait measurement. The command will block for at least this long
+while it measures usage. The default duration is one second. To watch for
+overloaded nodes during a large launch, pair with the shell's watch command:
+
+	minimega -e .columns name,load,iowait host top`,
+		Patterns: []string{
+			"host <top,> [duration]",
+		},
+		Call:     wrapBroadcastCLI(cliHostTop),
+		ReadOnly: true,
 	},
 }
 
 func init() {
 	gob.Register(&HostStats{})
+	gob.Register(&HostTopStats{})
 }
 
 func (s *HostStats) IsFull() bool {
@@ -125,6 +165,24 @@ var hostInfoKeys = []string{
 	"cpucommit", "memcommit", "netcommit", "uptime",
 }
 
+// Column metadata for the host info fields so that clients can sort and
+// render values correctly
+var hostInfoColumns = map[string]minicli.Column{
+	"name":      {ID: "name", Type: "string"},
+	"cpus":      {ID: "cpus", Type: "int"},
+	"load":      {ID: "load", Type: "string"},
+	"memused":   {ID: "memused", Type: "int", Unit: "MB"},
+	"memtotal":  {ID: "memtotal", Type: "int", Unit: "MB"},
+	"rx":        {ID: "rx", Type: "float", Unit: "MB/s"},
+	"tx":        {ID: "tx", Type: "float", Unit: "MB/s"},
+	"vms":       {ID: "vms", Type: "int"},
+	"vmlimit":   {ID: "vmlimit", Type: "int"},
+	"cpucommit": {ID: "cpucommit", Type: "int"},
+	"memcommit": {ID: "memcommit", Type: "int", Unit: "MB"},
+	"netcommit": {ID: "netcommit", Type: "int"},
+	"uptime":    {ID: "uptime", Type: "duration"},
+}
+
 func cliHost(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	stats := NewHostStats()
 	resp.Data = stats
@@ -141,12 +199,155 @@ func cliHost(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	row := []string{}
 	for _, k := range resp.Header {
 		row = append(row, stats.Print(k))
+		resp.Columns = append(resp.Columns, hostInfoColumns[k])
 	}
 	resp.Tabular = [][]string{row}
 
 	return nil
 }
 
+func cliHostTop(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	d := time.Second
+	if c.StringArgs["duration"] != "" {
+		v, err := strconv.Atoi(c.StringArgs["duration"])
+		if err != nil {
+			return err
+		}
+
+		d = time.Duration(v) * time.Second
+	}
+
+	stats := NewHostTopStats(d)
+	resp.Data = stats
+
+	resp.Header = []string{
+		"load",
+		"memtotal",
+		"memfree",
+		"vms",
+		"taps",
+		"iowait",
+	}
+
+	resp.Columns = []minicli.Column{
+		{ID: "load", Type: "string"},
+		{ID: "memtotal", Type: "int", Unit: "MB"},
+		{ID: "memfree", Type: "int", Unit: "MB"},
+		{ID: "vms", Type: "int"},
+		{ID: "taps", Type: "int"},
+		{ID: "iowait", Type: "float", Unit: "%"},
+	}
+
+	resp.Tabular = [][]string{
+		[]string{
+			stats.Load,
+			strconv.Itoa(stats.MemTotal),
+			strconv.Itoa(stats.MemFree),
+			strconv.Itoa(stats.VMs),
+			strconv.Itoa(stats.Taps),
+			fmt.Sprintf("%.2f", stats.IOWait),
+		},
+	}
+
+	return nil
+}
+
+// NewHostTopStats populates HostTopStats with fields spanning all namespaces,
+// sampling iowait over the given duration.
+func NewHostTopStats(d time.Duration) *HostTopStats {
+	h := HostTopStats{
+		Name: hostname,
+	}
+
+	var err error
+
+	h.Load, err = hostLoad()
+	if err != nil {
+		log.Error("unable to compute load: %v", err)
+	}
+
+	var used int
+	h.MemTotal, used, err = hostStatsMemory()
+	if err != nil {
+		log.Error("unable to compute memory stats: %v", err)
+	}
+	h.MemFree = h.MemTotal - used
+
+	h.Taps = bridges.TapCount()
+
+	h.IOWait, err = hostIOWait(d)
+	if err != nil {
+		log.Error("unable to compute iowait: %v", err)
+	}
+
+	namespaceLock.Lock()
+	defer namespaceLock.Unlock()
+
+	for _, ns := range namespaces {
+		h.VMs += ns.VMs.Count()
+	}
+
+	return &h
+}
+
+// hostIOWait computes the percentage of CPU time spent waiting on IO by
+// sampling /proc/stat over the given duration.
+func hostIOWait(d time.Duration) (float64, error) {
+	sample := func() (idle, iowait, total uint64, err error) {
+		data, err := ioutil.ReadFile("/proc/stat")
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		for _, line := range strings.Split(string(data), "\n") {
+			f := strings.Fields(line)
+			// aggregate cpu line looks something like
+			// 	cpu  4705 150 1120 16250 520 29 35 0 0 0
+			if len(f) < 6 || f[0] != "cpu" {
+				continue
+			}
+
+			for i, v := range f[1:] {
+				c, err := strconv.ParseUint(v, 10, 64)
+				if err != nil {
+					return 0, 0, 0, fmt.Errorf("cannot parse /proc/stat: %v", err)
+				}
+
+				total += c
+
+				switch i {
+				case 3:
+					idle = c
+				case 4:
+					iowait = c
+				}
+			}
+
+			return idle, iowait, total, nil
+		}
+
+		return 0, 0, 0, errors.New("could not find cpu line in /proc/stat")
+	}
+
+	_, iowait, total, err := sample()
+	if err != nil {
+		return 0, err
+	}
+
+	time.Sleep(d)
+
+	_, iowait2, total2, err := sample()
+	if err != nil {
+		return 0, err
+	}
+
+	if total2 == total {
+		return 0, nil
+	}
+
+	return 100 * float64(iowait2-iowait) / float64(total2-total), nil
+}
+
 func hostLoad() (string, error) {
 	load, err := ioutil.ReadFile("/proc/loadavg")
 	if err != nil {
@@ -0,0 +1,163 @@
+// Copyright 2015-2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sandia-minimega/minimega/v2/internal/ron"
+)
+
+// TrafficGroup describes protonuke instances running across a set of tagged
+// VMs so that background traffic can be started, stopped, and retuned as a
+// single experiment resource rather than via ad-hoc cc commands.
+type TrafficGroup struct {
+	// Name of the group
+	Name string
+
+	// Tag is the key:value VM tag that selects which clients belong to the
+	// group
+	Tag string
+
+	// Args are the protonuke arguments (protocols, target hosts, ...)
+	Args []string
+
+	// Rate is the mean time between protonuke actions (protonuke's -u flag)
+	Rate time.Duration
+
+	// Running is set while the group has a posted launch command
+	Running bool
+}
+
+// prefix returns the cc prefix used to track the group's commands.
+func (t *TrafficGroup) prefix() string {
+	return fmt.Sprintf("traffic-%v", t.Name)
+}
+
+// filter returns the client filter for the group's tag.
+func (t *TrafficGroup) filter() *ron.Filter {
+	parts := strings.SplitN(t.Tag, ":", 2)
+
+	filter := &ron.Filter{
+		Tags: map[string]string{},
+	}
+
+	if len(parts) == 1 {
+		filter.Tags[parts[0]] = ""
+	} else {
+		filter.Tags[parts[0]] = parts[1]
+	}
+
+	return filter
+}
+
+// StartTraffic posts the cc commands to launch protonuke on all the clients in
+// the group. protonuke is assumed to be present in the guest image, the same
+// assumption that the router API makes for minirouter.
+func (ns *Namespace) StartTraffic(name string) error {
+	t, ok := ns.trafficGroups[name]
+	if !ok {
+		return fmt.Errorf("unknown traffic group: %v", name)
+	}
+
+	if t.Running {
+		return fmt.Errorf("traffic group already running: %v", name)
+	}
+
+	args := []string{"protonuke", "-u", t.Rate.String()}
+	args = append(args, t.Args...)
+
+	cmd := &ron.Command{
+		Background: true,
+		Command:    args,
+		Prefix:     t.prefix(),
+		Filter:     t.filter(),
+	}
+	ns.ccServer.NewCommand(cmd)
+
+	t.Running = true
+
+	return nil
+}
+
+// StopTraffic kills protonuke on all the clients in the group and removes the
+// group's posted commands so that rebooted clients don't relaunch it.
+func (ns *Namespace) StopTraffic(name string) error {
+	t, ok := ns.trafficGroups[name]
+	if !ok {
+		return fmt.Errorf("unknown traffic group: %v", name)
+	}
+
+	if !t.Running {
+		return fmt.Errorf("traffic group not running: %v", name)
+	}
+
+	// remove the launch command first so that it cannot race with the kill
+	if err := ns.ccServer.DeleteCommands(t.prefix()); err != nil {
+		if !strings.HasPrefix(err.Error(), "no such prefix") {
+			return err
+		}
+	}
+
+	cmd := &ron.Command{
+		KillAll: "protonuke",
+		Prefix:  t.prefix(),
+		Filter:  t.filter(),
+	}
+	ns.ccServer.NewCommand(cmd)
+
+	t.Running = false
+
+	return nil
+}
+
+// SetTrafficRate updates the mean time between actions for the group,
+// restarting protonuke on the clients if the group is running. protonuke
+// cannot be retuned in-place so a restart is the only way to apply the new
+// rate.
+func (ns *Namespace) SetTrafficRate(name string, rate time.Duration) error {
+	t, ok := ns.trafficGroups[name]
+	if !ok {
+		return fmt.Errorf("unknown traffic group: %v", name)
+	}
+
+	restart := t.Running
+
+	if restart {
+		if err := ns.StopTraffic(name); err != nil {
+			return err
+		}
+	}
+
+	t.Rate = rate
+
+	if restart {
+		return ns.StartTraffic(name)
+	}
+
+	return nil
+}
+
+// ClearTraffic stops and deletes the named group or all groups if name is
+// empty or the wildcard.
+func (ns *Namespace) ClearTraffic(name string) error {
+	for _, t := range ns.trafficGroups {
+		if name != "" && name != Wildcard && t.Name != name {
+			continue
+		}
+
+		if t.Running {
+			if err := ns.StopTraffic(t.Name); err != nil {
+				return err
+			}
+		}
+
+		delete(ns.trafficGroups, t.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2015-2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
+)
+
+var trafficCLIHandlers = []minicli.Handler{
+	{ // traffic
+		HelpShort: "control background traffic generation",
+		HelpLong: `
+Deploy and centrally control protonuke instances across tagged VMs so that
+background traffic is a first-class experiment resource. Groups of VMs are
+selected by a key:value VM tag (see "vm tag") and all the clients in a group
+are controlled together.
+
+To define a group, provide a name, a tag, and the protonuke arguments to use.
+For example, to generate http and https traffic towards 10.0.0.1 from all VMs
+tagged with role:client:
+
+	traffic add web role:client -http -https 10.0.0.1
+
+Groups do not start generating traffic until started:
+
+	traffic start web
+	traffic stop web
+
+The rate for a group is the mean time between protonuke actions (protonuke's
+-u flag, default 1s). Changing the rate of a running group restarts protonuke
+on the clients since protonuke cannot be retuned in-place:
+
+	traffic rate web 100ms
+
+When run without arguments, traffic prints all defined groups. To stop and
+delete groups, use "clear traffic".
+
+protonuke must be present in the guest image, similar to the requirement that
+the router API places on minirouter. VMs running the miniccc client pick up
+the commands over the existing command and control plumbing.`,
+		Patterns: []string{
+			"traffic",
+			"traffic <add,> <name> <tag> <args>...",
+			"traffic <start,> <name>",
+			"traffic <stop,> <name>",
+			"traffic <rate,> <name> <rate>",
+		},
+		Call: wrapBroadcastCLI(cliTraffic),
+	},
+	{ // clear traffic
+		HelpShort: "stop and delete traffic groups",
+		HelpLong: `
+Stops and deletes one or all background traffic groups. See "help traffic" for
+more information.`,
+		Patterns: []string{
+			"clear traffic [name]",
+		},
+		Call: wrapBroadcastCLI(cliTrafficClear),
+	},
+}
+
+func cliTraffic(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	name := c.StringArgs["name"]
+
+	switch {
+	case c.BoolArgs["add"]:
+		if _, ok := ns.trafficGroups[name]; ok {
+			return fmt.Errorf("traffic group already exists: %v", name)
+		}
+
+		if !validName.MatchString(name) {
+			return fmt.Errorf("%v: `%v`", validNameErr, name)
+		}
+
+		ns.trafficGroups[name] = &TrafficGroup{
+			Name: name,
+			Tag:  c.StringArgs["tag"],
+			Args: c.ListArgs["args"],
+			Rate: time.Second,
+		}
+
+		return nil
+	case c.BoolArgs["start"]:
+		return ns.StartTraffic(name)
+	case c.BoolArgs["stop"]:
+		return ns.StopTraffic(name)
+	case c.BoolArgs["rate"]:
+		rate, err := time.ParseDuration(c.StringArgs["rate"])
+		if err != nil || rate <= 0 {
+			return fmt.Errorf("invalid rate: `%v`", c.StringArgs["rate"])
+		}
+
+		return ns.SetTrafficRate(name, rate)
+	}
+
+	// no sub command, list the groups
+	resp.Header = []string{
+		"name",
+		"tag",
+		"args",
+		"rate",
+		"running",
+	}
+
+	resp.Tabular = [][]string{}
+
+	for _, t := range ns.trafficGroups {
+		resp.Tabular = append(resp.Tabular, []string{
+			t.Name,
+			t.Tag,
+			strings.Join(t.Args, " "),
+			t.Rate.String(),
+			strconv.FormatBool(t.Running),
+		})
+	}
+
+	sort.Slice(resp.Tabular, func(i, j int) bool {
+		return resp.Tabular[i][0] < resp.Tabular[j][0]
+	})
+
+	return nil
+}
+
+func cliTrafficClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	return ns.ClearTraffic(c.StringArgs["name"])
+}
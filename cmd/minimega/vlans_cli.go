@@ -6,6 +6,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -26,8 +27,18 @@ known VLAN aliases. The following subcommands are supported:
 
 range		- view or set the VLAN range
 add   		- add an alias
+delete		- delete an alias, auditing connected interfaces
 blacklist 	- view or create blacklisted VLAN
 
+"vlans delete" removes a single alias, freeing the VLAN for reuse. Unlike
+"clear vlans", it first identifies all VM interfaces on the VLAN across the
+mesh and refuses to remove the alias while any remain, listing them instead.
+With "force-detach", the interfaces are disconnected from the VLAN before the
+alias is removed:
+
+	vlans delete LAN
+	vlans delete LAN force-detach
+
 Note: this command is namespace aware so, for example, adding a range applies
 to all *new* VLAN aliases in the current namespace.`,
 		Patterns: []string{
@@ -35,6 +46,7 @@ to all *new* VLAN aliases in the current namespace.`,
 			"vlans <range,>",
 			"vlans <range,> <min> <max>",
 			"vlans <add,> <alias> <vlan>",
+			"vlans <delete,> <alias> [force-detach,]",
 			"vlans <blacklist,> [vlan]",
 		},
 		Call: wrapSimpleCLI(cliVLANs),
@@ -58,6 +70,7 @@ want a completely clean slate.`,
 
 var vlansCLISubHandlers = map[string]wrappedCLIFunc{
 	"add":       cliVLANsAdd,
+	"delete":    cliVLANsDelete,
 	"range":     cliVLANsRange,
 	"blacklist": cliVLANsBlacklist,
 }
@@ -97,6 +110,64 @@ func cliVLANsAdd(ns *Namespace, c *minicli.Command, resp *minicli.Response) erro
 	return err
 }
 
+func cliVLANsDelete(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	alias := c.StringArgs["alias"]
+
+	vlan, err := vlans.GetVLAN(ns.Name, alias)
+	if err != nil {
+		return err
+	}
+
+	// audit all the interfaces on the VLAN across the mesh
+	type connected struct {
+		name string
+		pos  int
+	}
+
+	attached := []connected{}
+
+	for _, vm := range globalVMs(ns) {
+		for i, nic := range vm.GetNetworks() {
+			if nic.VLAN == vlan {
+				attached = append(attached, connected{vm.GetName(), i})
+			}
+		}
+	}
+
+	if len(attached) > 0 && !c.BoolArgs["force-detach"] {
+		names := []string{}
+		for _, v := range attached {
+			names = append(names, fmt.Sprintf("%v:%v", v.name, v.pos))
+		}
+
+		return fmt.Errorf("vlan %v still has connected interfaces: %v, use force-detach to disconnect them", alias, strings.Join(names, " "))
+	}
+
+	// disconnect the interfaces before freeing the alias so that nothing can
+	// snoop traffic on a reallocated VLAN
+	for _, v := range attached {
+		// let `vm net disconnect` fan out across the mesh to find the VM
+		cmd := minicli.MustCompilef("vm net disconnect %q %v", v.name, v.pos)
+
+		for resps := range runCommands(cmd) {
+			for _, r := range resps {
+				if r.Error != "" {
+					return fmt.Errorf("detach %v:%v: %v", v.name, v.pos, r.Error)
+				}
+			}
+		}
+	}
+
+	vlans.Delete(ns.Name, alias)
+	mustWrite(filepath.Join(*f_base, "vlans"), vlanInfo())
+
+	if len(attached) > 0 {
+		resp.Response = fmt.Sprintf("detached %v interfaces", len(attached))
+	}
+
+	return nil
+}
+
 func cliVLANsRange(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	// ranges are special if we're in the default namespace -- a range gets set
 	// globally for all namespaces.
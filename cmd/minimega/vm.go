@@ -31,12 +31,14 @@ const (
 	_ VMType = iota
 	KVM
 	CONTAINER
+	FIRECRACKER
 )
 
 type VM interface {
 	GetID() int           // GetID returns the VM's per-host unique ID
 	GetPID() int          // GetPID returns the VM's PID
 	GetName() string      // GetName returns the VM's per-host unique name
+	SetName(string)       // SetName updates the VM's name, see "vm rename"
 	GetNamespace() string // GetNamespace returns the VM's namespace name
 	GetHost() string      // GetHost returns the hostname that the VM is running on
 	GetState() VMState
@@ -144,11 +146,14 @@ var vmInfo = []string{
 	"memory",
 	// kvm fields
 	"vcpus", "disks", "snapshot", "initrd", "kernel", "cdrom", "save",
-	"append", "serial-ports", "virtio-ports", "vnc_port", "usb-use-xhci",
-	"tpm-socket", "bidirectional-copy-paste",
+	"append", "serial-ports", "virtio-ports", "vnc_port", "vnc_tx",
+	"vnc_rx", "qmp_status",
+	"usb-use-xhci", "tpm-socket", "bidirectional-copy-paste",
 	// container fields
 	"filesystem", "hostname", "init", "preinit", "fifo", "volume",
 	"console_port",
+	// firecracker fields
+	"firecracker-kernel", "firecracker-rootfs", "firecracker-append",
 	// more generic fields (tags can be huge so throw it at the end)
 	"tags",
 }
@@ -168,6 +173,7 @@ func init() {
 	gob.Register([]VM{})
 	gob.Register(&KvmVM{})
 	gob.Register(&ContainerVM{})
+	gob.Register(&FirecrackerVM{})
 }
 
 func NewVM(name, namespace string, vmType VMType, config VMConfig) (VM, error) {
@@ -176,6 +182,8 @@ func NewVM(name, namespace string, vmType VMType, config VMConfig) (VM, error) {
 		return NewKVM(name, namespace, config)
 	case CONTAINER:
 		return NewContainer(name, namespace, config)
+	case FIRECRACKER:
+		return NewFirecracker(name, namespace, config)
 	}
 
 	return nil, errors.New("unknown VM type")
@@ -212,13 +220,14 @@ func NewBaseVM(name, namespace string, config VMConfig) *BaseVM {
 	// 0.016% chance of collisions when running 10K VMs (one interface/VM).
 	for i := range vm.Networks {
 		if vm.Networks[i].MAC == "" {
-			vm.Networks[i].MAC = randomMac()
+			vm.Networks[i].MAC = vm.generateMac()
 		}
 	}
 
 	vm.kill = make(chan bool)
 
-	vm.instancePath = filepath.Join(*f_base, strconv.Itoa(vm.ID))
+	// should never create -- the namespace launched this VM
+	vm.instancePath = GetOrCreateNamespace(namespace).InstancePath(vm.ID)
 
 	vm.State = VM_BUILDING
 	vm.LaunchTime = time.Now()
@@ -258,6 +267,8 @@ func (s VMType) String() string {
 		return "kvm"
 	case CONTAINER:
 		return "container"
+	case FIRECRACKER:
+		return "firecracker"
 	default:
 		return "???"
 	}
@@ -269,6 +280,8 @@ func ParseVMType(s string) (VMType, error) {
 		return KVM, nil
 	case "container":
 		return CONTAINER, nil
+	case "firecracker":
+		return FIRECRACKER, nil
 	default:
 		return 0, errors.New("invalid VMType")
 	}
@@ -294,6 +307,19 @@ func (vm *BaseVM) GetName() string {
 	return vm.Name
 }
 
+// SetName updates the vm's name and the name file in the instance path.
+// Callers should ensure that the new name is unique within the namespace.
+func (vm *BaseVM) SetName(name string) {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	log.Info("renaming vm %v: %v -> %v", vm.ID, vm.Name, name)
+
+	vm.Name = name
+
+	mustWrite(vm.path("name"), vm.Name)
+}
+
 func (vm *BaseVM) GetNamespace() string {
 	return vm.Namespace
 }
@@ -674,6 +700,33 @@ func (vm *BaseVM) SetCCActive(active bool) {
 	vm.lock.Lock()
 	defer vm.lock.Unlock()
 
+	if active && !vm.ActiveCC {
+		// first cc checkin means the guest has booted far enough to run
+		// programs -- fire any boot-ready hooks. Build the metadata from the
+		// fields directly since we hold the VM's lock.
+		ns := GetOrCreateNamespace(vm.Namespace)
+
+		if len(ns.Hooks[HookBootReady]) > 0 {
+			data := &hookData{
+				Event:     HookBootReady,
+				Name:      vm.Name,
+				Namespace: vm.Namespace,
+				Type:      vm.Type.String(),
+				State:     vm.State.String(),
+				Tags:      map[string]string{},
+			}
+			for k, v := range vm.Tags {
+				data.Tags[k] = v
+			}
+
+			go func() {
+				if err := ns.RunHooks(HookBootReady, data); err != nil {
+					log.Error("%v: %v", data.Name, err)
+				}
+			}()
+		}
+	}
+
 	vm.ActiveCC = active
 }
 
@@ -834,10 +887,19 @@ func (vm *BaseVM) Info(field string) (string, error) {
 		}
 	case "ip":
 		for _, v := range vm.Networks {
+			if v.IP4 == "" && v.IP4Static != "" {
+				// show the static plan until we snoop an address
+				vals = append(vals, trimMask(v.IP4Static))
+				continue
+			}
 			vals = append(vals, v.IP4)
 		}
 	case "ip6":
 		for _, v := range vm.Networks {
+			if v.IP6 == "" && v.IP6Static != "" {
+				vals = append(vals, trimMask(v.IP6Static))
+				continue
+			}
 			vals = append(vals, v.IP6)
 		}
 	case "qos":
@@ -62,6 +62,8 @@ Additional fields are available for KVM-based VMs:
 - serial        : number of serial ports
 - virtio-serial : number of virtio ports
 - vnc_port      : port for VNC shim
+- vnc_tx        : bytes sent to VNC clients
+- vnc_rx        : bytes received from VNC clients
 - usb-use-xhci  : usb controller (true = xhci; false = ehci)
 - tpm-socket	: path of emulated tpm socket
 
@@ -87,7 +89,8 @@ Display information about all VMs:
 		Patterns: []string{
 			"vm info [summary,]",
 		},
-		Call: wrapBroadcastCLI(cliVMInfo),
+		Call:     wrapBroadcastCLI(cliVMInfo),
+		ReadOnly: true,
 	},
 	{ // vm launch
 		HelpShort: "launch virtual machines in a paused state",
@@ -101,6 +104,7 @@ supported VM types are:
 
 - kvm : QEMU-based vms
 - container: Linux containers
+- firecracker: firecracker-based microVMs
 
 If you supply a name instead of a number of VMs, one VM with that name will be
 launched. You may also supply a range expression to launch VMs with a specific
@@ -119,11 +123,20 @@ example:
 
 If queueing is enabled (see "ns"), VMs will be queued for launching until "vm
 launch" is called with no additional arguments. This allows the scheduler to
-better allocate resources across the cluster.`,
+better allocate resources across the cluster.
+
+"vm launch canary" launches and starts a single VM from the queue and waits
+for it to check in via cc before launching the rest, avoiding a lengthy
+launch of many VMs from a broken image. The optional timeout is in seconds
+and defaults to 300. If the canary quits, errors out, or does not check in
+before the timeout, the remaining VMs are left queued. The image must run
+miniccc for the check-in to occur; the remaining VMs launch paused as usual.`,
 		Patterns: []string{
 			"vm launch",
+			"vm launch <canary,> [timeout]",
 			"vm launch <kvm,> <name or count> [config]",
 			"vm launch <container,> <name or count> [config]",
+			"vm launch <firecracker,> <name or count> [config]",
 		},
 		Call: wrapSimpleCLI(cliVMLaunch),
 	},
@@ -240,6 +253,46 @@ See "vm start" for a full description of allowable targets.`,
 		Call:    wrapVMTargetCLI(cliVMHotplug),
 		Suggest: wrapVMSuggest(VM_ANY_STATE, true),
 	},
+	{ // vm usb
+		HelpShort: "attach and detach host USB devices",
+		HelpLong: `
+Pass host USB devices, such as hardware tokens and other peripherals, through
+to a launched VM using qemu's usb-host device. A device may be identified by
+its vendor and product IDs, as shown by lsusb:
+
+	vm usb attach foo 0951:1666
+
+or by its host bus and port, which pins a specific physical plug even when
+identical devices are present:
+
+	vm usb attach foo 3.2
+
+Ports behind a hub are specified with a dotted path such as 3.2.4. While
+attached, the device is unavailable to the host and to other VMs.
+
+The attach command assigns a device ID, shown by "vm usb". To detach the
+device attached above:
+
+	vm usb detach foo 0
+
+To detach all devices, use ID "all":
+
+	vm usb detach foo all
+
+Note: attaching requires access to the device nodes under /dev/bus/usb, so
+minimega must typically run as root. Devices attach to the USB 2.0 bus unless
+the VM was configured with "vm config usb-use-xhci" (the default), in which
+case the xHCI bus is used and all device speeds are supported.
+
+See "vm start" for a full description of allowable targets.`,
+		Patterns: []string{
+			"vm usb",
+			"vm usb <attach,> <vm target> <device>",
+			"vm usb <detach,> <vm target> <device id or all>",
+		},
+		Call:    wrapVMTargetCLI(cliVMUSB),
+		Suggest: wrapVMSuggest(VM_ANY_STATE, true),
+	},
 	{ // vm net
 		HelpShort: "add, disconnect, or move network connections",
 		HelpLong: `
@@ -333,6 +386,23 @@ and a JSON string, and returns the JSON encoded response. For example:
 		Call:    wrapVMTargetCLI(cliVMQmp),
 		Suggest: wrapVMSuggest(VM_ANY_STATE, false),
 	},
+	{ // vm crashreport
+		HelpShort: "view the crash report for a VM",
+		HelpLong: `
+View the crash report for a VM whose QEMU process exited unexpectedly. The
+report is written to "crash.report" in the VM's instance path when the crash is
+detected and includes the exit status, QEMU's stderr, a tail of the QMP log,
+and the location of the core dump, if one was captured.
+
+If "ns auto-screenshot" is enabled, a screenshot of the console from shortly
+before the VM went away is saved as "crash.png" in the instance path and
+referenced in the report.`,
+		Patterns: []string{
+			"vm crashreport <vm name>",
+		},
+		Call:    wrapVMTargetCLI(cliVMCrashReport),
+		Suggest: wrapVMSuggest(VM_ANY_STATE, false),
+	},
 	{ // vm screenshot
 		HelpShort: "take a screenshot of a running vm",
 		HelpLong: `
@@ -446,6 +516,23 @@ See "vm start" for a full description of allowable targets.`,
 		Call:    wrapVMTargetCLI(cliVMCdrom),
 		Suggest: wrapVMSuggest(VM_ANY_STATE, true),
 	},
+	{ // vm rename
+		HelpShort: "rename a VM",
+		HelpLong: `
+Rename a VM. The new name must be unique within the namespace and follows the
+same rules as names for "vm launch". The VM may be in any state -- it keeps
+its ID, UUID, tags, and cc connection:
+
+	vm rename old-name new-name
+
+The name file in the VM's instance path is updated to match. Active vnc
+recordings and playbacks keep the name the VM had when they started.`,
+		Patterns: []string{
+			"vm rename <vm name> <new name>",
+		},
+		Call:    wrapVMTargetCLI(cliVMRename),
+		Suggest: wrapVMSuggest(VM_ANY_STATE, false),
+	},
 	{ // vm tag
 		HelpShort: "display or set a tag for the specified VM",
 		HelpLong: `
@@ -468,8 +555,15 @@ To read a tag:
 			"vm tag <vm target> [key or all]",  // get
 			"vm tag <vm target> <key> <value>", // set
 		},
-		Call:    wrapVMTargetCLI(cliVMTag),
-		Suggest: wrapVMSuggest(VM_ANY_STATE, true),
+		Call: wrapVMTargetCLI(cliVMTag),
+		Suggest: wrapSuggest(func(ns *Namespace, val, prefix string) []string {
+			if val == "vm" {
+				return cliVMSuggest(ns, prefix, VM_ANY_STATE, true)
+			} else if val == "key" {
+				return cliTagSuggest(ns, prefix)
+			}
+			return nil
+		}),
 	},
 	{ // clear vm tag
 		HelpShort: "remove tags from a VM",
@@ -495,8 +589,15 @@ Clear all tags from all VMs:
 			"clear vm tag",
 			"clear vm tag <vm target> [tag]",
 		},
-		Call:    wrapVMTargetCLI(cliClearVMTag),
-		Suggest: wrapVMSuggest(VM_ANY_STATE, true),
+		Call: wrapVMTargetCLI(cliClearVMTag),
+		Suggest: wrapSuggest(func(ns *Namespace, val, prefix string) []string {
+			if val == "vm" {
+				return cliVMSuggest(ns, prefix, VM_ANY_STATE, true)
+			} else if val == "tag" {
+				return cliTagSuggest(ns, prefix)
+			}
+			return nil
+		}),
 	},
 	{ // clear vm net bond
 		HelpShort: "remove net bonds from a VM",
@@ -531,7 +632,32 @@ Returned columns include:
 		Patterns: []string{
 			"vm top [duration]",
 		},
-		Call: wrapBroadcastCLI(cliVMTop),
+		Call:     wrapBroadcastCLI(cliVMTop),
+		ReadOnly: true,
+	},
+	{ // vm vcpu
+		HelpShort: "view per-vCPU utilization for KVM VMs",
+		HelpLong: `
+View per-vCPU utilization for KVM VMs, one row per vCPU thread. This is
+measured from the host by sampling the QEMU vCPU threads.
+
+The optional duration specifies the length of the sampling window in seconds.
+The default duration is one second.
+
+The columns are:
+
+- name : name of the VM
+- vcpu : vCPU index within the guest
+- cpu  : host CPU usage (%) for the vCPU thread
+- steal: time (%) the vCPU thread spent runnable but waiting for a host CPU
+
+High steal with low cpu indicates host contention rather than a guest-bound
+workload.`,
+		Patterns: []string{
+			"vm vcpu [duration]",
+		},
+		Call:     wrapBroadcastCLI(cliVMVCPU),
+		ReadOnly: true,
 	},
 }
 
@@ -549,7 +675,7 @@ func cliVMApply(ns *Namespace, c *minicli.Command, resp *minicli.Response) error
 	case c.BoolArgs["stop"]:
 		return ns.VMs.Stop(c.StringArgs["vm"])
 	case c.BoolArgs["kill"]:
-		return ns.VMs.Kill(c.StringArgs["vm"])
+		return ns.Kill(c.StringArgs["vm"])
 	case c.BoolArgs["flush"]:
 		if len(c.StringArgs["vm"]) == 0 {
 			return ns.VMs.FlushAll(ns.ccServer)
@@ -609,6 +735,38 @@ func cliVMCdrom(ns *Namespace, c *minicli.Command, resp *minicli.Response) error
 	return unreachable()
 }
 
+func cliVMRename(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	name := c.StringArgs["new"]
+
+	vm := ns.FindVM(c.StringArgs["vm"])
+	if vm == nil {
+		return vmNotFound(c.StringArgs["vm"])
+	}
+
+	if !validName.MatchString(name) {
+		return fmt.Errorf("%v: `%v`", validNameErr, name)
+	}
+
+	// look for name conflicts across the namespace, including queued VMs
+	for _, vm2 := range globalVMs(ns) {
+		if vm2.GetName() == name {
+			return fmt.Errorf("vm already exists with name `%s`", name)
+		}
+	}
+
+	for _, q := range ns.queue {
+		for _, n := range q.Names {
+			if n == name {
+				return fmt.Errorf("vm already exists with name `%s`", name)
+			}
+		}
+	}
+
+	vm.SetName(name)
+
+	return nil
+}
+
 func cliVMTag(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	target := c.StringArgs["vm"]
 
@@ -722,6 +880,20 @@ func cliVMLaunch(ns *Namespace, c *minicli.Command, resp *minicli.Response) erro
 		return fmt.Errorf("cannot run `%s` via meshage", c.Original)
 	}
 
+	if c.BoolArgs["canary"] {
+		timeout := 5 * time.Minute
+		if c.StringArgs["timeout"] != "" {
+			v, err := strconv.Atoi(c.StringArgs["timeout"])
+			if err != nil {
+				return err
+			}
+
+			timeout = time.Duration(v) * time.Second
+		}
+
+		return ns.ScheduleCanary(timeout)
+	}
+
 	// adding VM to queue
 	if len(c.StringArgs) > 0 {
 		// create a local copy of the current or specified VMConfig
@@ -774,6 +946,23 @@ func cliVMQmp(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	return nil
 }
 
+func cliVMCrashReport(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	vm, err := ns.FindKvmVM(c.StringArgs["vm"])
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(vm.path("crash.report"))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no crash report for vm %v", vm.Name)
+	} else if err != nil {
+		return err
+	}
+
+	resp.Response = string(data)
+	return nil
+}
+
 func cliVMScreenshot(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	file := c.StringArgs["filename"]
 
@@ -953,6 +1142,79 @@ func cliVMHotplug(ns *Namespace, c *minicli.Command, resp *minicli.Response) err
 	})
 }
 
+func cliVMUSB(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	target := c.StringArgs["vm"]
+
+	if c.BoolArgs["attach"] {
+		spec := c.StringArgs["device"]
+
+		return ns.VMs.Apply(target, func(vm VM, wild bool) (bool, error) {
+			if kvm, ok := vm.(*KvmVM); ok {
+				return true, kvm.USBAttach(spec)
+			}
+
+			return false, nil
+		})
+	} else if c.BoolArgs["detach"] {
+		device := c.StringArgs["device"]
+
+		id, err := strconv.Atoi(device)
+		if err != nil && device != Wildcard {
+			return fmt.Errorf("invalid device: `%v`", device)
+		}
+
+		return ns.VMs.Apply(target, func(vm VM, wild bool) (bool, error) {
+			kvm, ok := vm.(*KvmVM)
+			if !ok {
+				return false, nil
+			}
+
+			if device == Wildcard {
+				err := kvm.USBDetachAll()
+				if wild && err != nil && err.Error() == "no usb devices to detach" {
+					// suppress error if more than one target
+					err = nil
+				}
+				return true, err
+			}
+
+			err := kvm.USBDetach(id)
+			if wild && err != nil && err.Error() == "no such usb device" {
+				// suppress error if more than one target
+				err = nil
+			}
+
+			return true, err
+		})
+	}
+
+	resp.Header = []string{"name", "id", "device"}
+
+	// synchronizes appends to resp.Tabular
+	var mu sync.Mutex
+
+	return ns.VMs.Apply(Wildcard, func(vm VM, wild bool) (bool, error) {
+		kvm, ok := vm.(*KvmVM)
+		if !ok {
+			return false, nil
+		}
+
+		name := vm.GetName()
+		res := kvm.USBInfo()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		for k, v := range res {
+			resp.Tabular = append(resp.Tabular, []string{
+				name, strconv.Itoa(k), v,
+			})
+		}
+
+		return true, nil
+	})
+}
+
 func cliVMNetMod(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	target := c.StringArgs["vm"]
 
@@ -1144,6 +1406,19 @@ func cliVMTop(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 		"tx",
 	}
 
+	resp.Columns = []minicli.Column{
+		{ID: "name", Type: "string"},
+		{ID: "virt", Type: "int", Unit: "MB"},
+		{ID: "res", Type: "int", Unit: "MB"},
+		{ID: "shr", Type: "int", Unit: "MB"},
+		{ID: "cpu", Type: "float", Unit: "%"},
+		{ID: "vcpu", Type: "float", Unit: "%"},
+		{ID: "time", Type: "duration"},
+		{ID: "procs", Type: "int"},
+		{ID: "rx", Type: "float", Unit: "MB/s"},
+		{ID: "tx", Type: "float", Unit: "MB/s"},
+	}
+
 	fmtMB := func(i uint64) string {
 		return strconv.FormatUint(i/(uint64(1)<<20), 10)
 	}
@@ -1168,6 +1443,45 @@ func cliVMTop(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	return nil
 }
 
+func cliVMVCPU(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	d := time.Second
+	if c.StringArgs["duration"] != "" {
+		v, err := strconv.Atoi(c.StringArgs["duration"])
+		if err != nil {
+			return err
+		}
+
+		d = time.Duration(v) * time.Second
+	}
+
+	resp.Header = []string{
+		"name",
+		"vcpu",
+		"cpu",
+		"steal",
+	}
+
+	resp.Columns = []minicli.Column{
+		{ID: "name", Type: "string"},
+		{ID: "vcpu", Type: "int"},
+		{ID: "cpu", Type: "float", Unit: "%"},
+		{ID: "steal", Type: "float", Unit: "%"},
+	}
+
+	for _, s := range ns.ProcStats(d) {
+		for _, v := range s.VCPUs() {
+			resp.Tabular = append(resp.Tabular, []string{
+				s.Name,
+				strconv.Itoa(v.ID),
+				fmt.Sprintf("%.2f", v.CPU*100),
+				fmt.Sprintf("%.2f", v.Steal*100),
+			})
+		}
+	}
+
+	return nil
+}
+
 // cliVMSuggest takes a prefix that could be the start of a VM name
 // and makes suggestions for VM names that have a common prefix. mask
 // can be used to only complete for VMs that are in a particular state (e.g.
@@ -1191,3 +1505,26 @@ func cliVMSuggest(ns *Namespace, prefix string, mask VMState, wild bool) []strin
 
 	return res
 }
+
+// cliTagSuggest takes a prefix that could be the start of a tag key and makes
+// suggestions based on the tags set on VMs in the namespace. Returns a list
+// of suggestions.
+func cliTagSuggest(ns *Namespace, prefix string) []string {
+	keys := map[string]bool{}
+
+	for _, vm := range globalVMs(ns) {
+		for k := range vm.GetTags() {
+			keys[k] = true
+		}
+	}
+
+	res := []string{}
+
+	for k := range keys {
+		if strings.HasPrefix(k, prefix) {
+			res = append(res, k)
+		}
+	}
+
+	return res
+}
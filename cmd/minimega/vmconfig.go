@@ -2,7 +2,7 @@
 // Under the terms of Contract DE-AC04-94AL85000 with Sandia Corporation,
 // the U.S. Government retains certain rights in this software.
 //
-//go:generate ../../bin/vmconfiger -type BaseConfig,KVMConfig,ContainerConfig
+//go:generate ../../bin/vmconfiger -type BaseConfig,KVMConfig,ContainerConfig,FirecrackerConfig
 
 package main
 
@@ -27,6 +27,7 @@ type VMConfig struct {
 	BaseConfig
 	KVMConfig
 	ContainerConfig
+	FirecrackerConfig
 }
 
 func NewVMConfig() VMConfig {
@@ -91,6 +92,18 @@ type BaseConfig struct {
 	// Default: true
 	Backchannel bool
 
+	// Generate MAC addresses for unassigned interfaces using OUIs registered
+	// to a specific hardware vendor instead of a randomly chosen prefix. The
+	// value is matched case-insensitively against registered vendor names, so
+	// "dell", "cisco", or "intel" all work. Useful when passive
+	// fingerprinting tools in an experiment should see believable hardware.
+	// Explicitly configured MAC addresses are not affected.
+	//
+	// For example, to make VMs look like Dell hardware:
+	//
+	// 	vm config mac-vendor dell
+	MacVendor string `validate:"validMacVendor"`
+
 	// Networks for the VM, handler is not generated by vmconfiger.
 	Networks NetConfigs
 
@@ -106,22 +119,25 @@ type BaseConfig struct {
 
 func (old VMConfig) Copy() VMConfig {
 	return VMConfig{
-		BaseConfig:      old.BaseConfig.Copy(),
-		KVMConfig:       old.KVMConfig.Copy(),
-		ContainerConfig: old.ContainerConfig.Copy(),
+		BaseConfig:        old.BaseConfig.Copy(),
+		KVMConfig:         old.KVMConfig.Copy(),
+		ContainerConfig:   old.ContainerConfig.Copy(),
+		FirecrackerConfig: old.FirecrackerConfig.Copy(),
 	}
 }
 
 func (vm VMConfig) String(namespace string) string {
 	return vm.BaseConfig.String(namespace) +
 		vm.KVMConfig.String() +
-		vm.ContainerConfig.String()
+		vm.ContainerConfig.String() +
+		vm.FirecrackerConfig.String()
 }
 
 func (vm *VMConfig) Clear(mask string) {
 	vm.BaseConfig.Clear(mask)
 	vm.KVMConfig.Clear(mask)
 	vm.ContainerConfig.Clear(mask)
+	vm.FirecrackerConfig.Clear(mask)
 }
 
 func (vm *VMConfig) WriteConfig(w io.Writer) error {
@@ -129,6 +145,7 @@ func (vm *VMConfig) WriteConfig(w io.Writer) error {
 		vm.BaseConfig.WriteConfig,
 		vm.KVMConfig.WriteConfig,
 		vm.ContainerConfig.WriteConfig,
+		vm.FirecrackerConfig.WriteConfig,
 	}
 
 	for _, fn := range funcs {
@@ -146,6 +163,8 @@ func (vm *VMConfig) ReadConfig(r io.ReadSeeker, ns string) error {
 	vm.KVMConfig.ReadConfig(r, ns)
 	r.Seek(0, io.SeekStart)
 	vm.ContainerConfig.ReadConfig(r, ns)
+	r.Seek(0, io.SeekStart)
+	vm.FirecrackerConfig.ReadConfig(r, ns)
 
 	return nil
 }
@@ -366,6 +385,58 @@ func validSchedule(vmConfig VMConfig, s string) error {
 	return nil
 }
 
+// vmConfigFields renders a config with WriteConfig and maps each "vm config"
+// field to its value. Fields left at their defaults are absent since
+// WriteConfig skips them. Fields written more than once, such as tags, have
+// their values joined.
+func vmConfigFields(v VMConfig) (map[string]string, error) {
+	var buf bytes.Buffer
+
+	if err := v.WriteConfig(&buf); err != nil {
+		return nil, err
+	}
+
+	res := map[string]string{}
+
+	for _, line := range strings.Split(buf.String(), "\n") {
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) < 4 || fields[0] != "vm" || fields[1] != "config" {
+			continue
+		}
+
+		if prev, ok := res[fields[2]]; ok {
+			res[fields[2]] = prev + ", " + fields[3]
+		} else {
+			res[fields[2]] = fields[3]
+		}
+	}
+
+	return res, nil
+}
+
+func validMacVendor(vmConfig VMConfig, s string) error {
+	if s == "" || len(macVendorPrefixes(s)) > 0 {
+		return nil
+	}
+
+	return fmt.Errorf("no registered OUIs match vendor: `%v`", s)
+}
+
+// generateMac generates a random MAC address, drawing the prefix from the
+// configured mac-vendor OUIs when one is set.
+func (vm *BaseConfig) generateMac() string {
+	if vm.MacVendor != "" {
+		if prefixes := macVendorPrefixes(vm.MacVendor); len(prefixes) > 0 {
+			return randomMacPrefix(prefixes)
+		}
+
+		// validMacVendor checked the vendor when it was configured
+		log.Warn("no registered OUIs match vendor `%v`, using random prefix", vm.MacVendor)
+	}
+
+	return randomMac()
+}
+
 func validColocate(vmConfig VMConfig, s string) error {
 	if vmConfig.Schedule != "" && s != "" {
 		return errors.New("cannot specify colocate and schedule in the same config")
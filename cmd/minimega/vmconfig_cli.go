@@ -5,13 +5,75 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 
 	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
 )
 
+// vmConfigMutation records a command that changed the namespace's vm config
+// along with the config as it was beforehand, for `vm config undo`.
+type vmConfigMutation struct {
+	command string
+	prev    VMConfig
+}
+
+// vmConfigHistorySize is the number of config mutations retained per
+// namespace.
+const vmConfigHistorySize = 128
+
+// recordVMConfigHistory wraps the Call of each handler so that commands that
+// change the namespace's vm config are recorded in its config history.
+func recordVMConfigHistory(handlers []minicli.Handler) []minicli.Handler {
+	for i := range handlers {
+		fn := handlers[i].Call
+
+		handlers[i].Call = func(c *minicli.Command, respChan chan<- minicli.Responses) {
+			// don't record history and undo themselves
+			if c.BoolArgs["history"] || c.BoolArgs["undo"] {
+				fn(c, respChan)
+				return
+			}
+
+			ns := GetNamespace()
+			prev := ns.vmConfig.Copy()
+
+			fn(c, respChan)
+
+			if !vmConfigChanged(prev, ns.vmConfig) {
+				return
+			}
+
+			ns.configHistory = append(ns.configHistory, vmConfigMutation{
+				command: c.Original,
+				prev:    prev,
+			})
+			if len(ns.configHistory) > vmConfigHistorySize {
+				ns.configHistory = ns.configHistory[len(ns.configHistory)-vmConfigHistorySize:]
+			}
+		}
+	}
+
+	return handlers
+}
+
+// vmConfigChanged compares two configs by their non-default fields.
+func vmConfigChanged(a, b VMConfig) bool {
+	var b1, b2 bytes.Buffer
+
+	if err := a.WriteConfig(&b1); err != nil {
+		return false
+	}
+	if err := b.WriteConfig(&b2); err != nil {
+		return false
+	}
+
+	return b1.String() != b2.String()
+}
+
 // vmconfigCLIHandlers are special cases that are not worth generating via
 // vmconfiger.
 var vmconfigCLIHandlers = []minicli.Handler{
@@ -38,6 +100,22 @@ To clone the configuration of an existing VM:
 
 	vm config clone <vm name>
 
+To see what a long interactive session has changed before launching, diff the
+current configuration against the defaults, or against a saved configuration:
+
+	vm config diff
+	vm config diff <config name>
+
+The diff lists only the fields whose values differ, one per row.
+
+The config commands run in this session that changed the configuration are
+recorded. To review them, or to roll back the last n changes (one if n is
+omitted):
+
+	vm config history
+	vm config undo
+	vm config undo 3
+
 Clone reparses the original network "vm config net". If the cloned VM was
 configured with a static MAC, the VM config will not be launchable. Clone also
 clears the UUID.
@@ -49,6 +127,9 @@ remove saved configurations.`,
 			"vm config <save,> <name>",
 			"vm config <restore,> [name]",
 			"vm config <clone,> <vm name>",
+			"vm config <diff,> [name]",
+			"vm config <history,>",
+			"vm config <undo,> [n]",
 		},
 		Suggest: wrapVMSuggest(VM_ANY_STATE, false),
 		Call:    wrapSimpleCLI(cliVMConfig),
@@ -74,9 +155,17 @@ snapshot image file. If you wish to copy and use the snapshot image file
 cleanly, you can flush the disk cache manually via the QMP command socket, or
 specify a different cache mode such as "writeback".
 
+Optionally, you may specify a LUKS key file with luks=<path> for qcow2 images
+created with inline LUKS encryption, so that images holding sensitive data
+stay encrypted at rest on shared cluster storage. The key file holds the
+passphrase and is passed to QEMU as a secret object; it must be readable on
+every host the VM may be scheduled on and should live outside the shared
+files directory. In snapshot mode, the snapshot image is encrypted with the
+same key.
+
 The order is:
 
-	<path>,<interface>,<cache mode>
+	<path>,<interface>,<cache mode>,luks=<key file>
 
 Examples:
 
@@ -93,6 +182,10 @@ To attach a disk using the "ide" interface with the "unsafe" cache mode:
 
 	vm config disk linux_disk.qcow2,ide,unsafe
 
+To attach a LUKS-encrypted disk:
+
+	vm config disk secure.qcow2,virtio,luks=/etc/minimega/keys/secure.key
+
 Disk images launched in snapshot mode may safely be used for multiple VMs.
 
 Calling vm config disks with no arguments prints the current configuration.
@@ -126,6 +219,16 @@ The order is:
 
 	<bridge>,<VLAN>,<MAC>,<driver>,<qinq>
 
+In addition to the positional fields, a netspec may include ip=, ip6=, and
+hostname= fields, in any position, to record a static address plan for the
+interface. The addresses may optionally include a CIDR mask. When the VM
+launches, the plan is pushed to any running dnsmasq servers (see "dnsmasq") so
+that the guest receives its addresses over DHCP and the hostname resolves via
+DNS. The planned addresses are also shown in "vm info" until an address is
+snooped from the guest. For example:
+
+	vm config net LAN,ip=10.0.0.5/24,ip6=fd00::5/64,hostname=web-1
+
 Examples:
 
 To connect a VM to VLANs 1 and 5:
@@ -307,6 +410,9 @@ func cliVMConfig(ns *Namespace, c *minicli.Command, resp *minicli.Response) erro
 		case *ContainerVM:
 			ns.vmConfig.BaseConfig = vm.BaseConfig.Copy()
 			ns.vmConfig.ContainerConfig = vm.ContainerConfig.Copy()
+		case *FirecrackerVM:
+			ns.vmConfig.BaseConfig = vm.BaseConfig.Copy()
+			ns.vmConfig.FirecrackerConfig = vm.FirecrackerConfig.Copy()
 		}
 
 		// clear UUID since we can't launch VMs with the same UUID
@@ -329,6 +435,95 @@ func cliVMConfig(ns *Namespace, c *minicli.Command, resp *minicli.Response) erro
 		}
 
 		return ns.processVMBonds(bonds)
+	} else if c.BoolArgs["diff"] {
+		other := NewVMConfig()
+		label := "default"
+
+		if name, ok := c.StringArgs["name"]; ok {
+			saved, ok := ns.savedVMConfig[name]
+			if !ok {
+				return fmt.Errorf("config %v does not exist", name)
+			}
+
+			other = saved.Copy()
+			label = name
+		}
+
+		curr, err := vmConfigFields(ns.vmConfig)
+		if err != nil {
+			return err
+		}
+
+		prev, err := vmConfigFields(other)
+		if err != nil {
+			return err
+		}
+
+		// collect the fields present in either config
+		fields := map[string]bool{}
+		for k := range curr {
+			fields[k] = true
+		}
+		for k := range prev {
+			fields[k] = true
+		}
+
+		names := []string{}
+		for k := range fields {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		resp.Header = []string{"field", "current", label}
+
+		for _, k := range names {
+			if curr[k] == prev[k] {
+				continue
+			}
+
+			a, b := curr[k], prev[k]
+			if a == "" {
+				a = "(default)"
+			}
+			if b == "" {
+				b = "(default)"
+			}
+
+			resp.Tabular = append(resp.Tabular, []string{k, a, b})
+		}
+
+		return nil
+	} else if c.BoolArgs["history"] {
+		for _, m := range ns.configHistory {
+			resp.Response += fmt.Sprintln(m.command)
+		}
+
+		return nil
+	} else if c.BoolArgs["undo"] {
+		n := 1
+
+		if c.StringArgs["n"] != "" {
+			var err error
+			n, err = strconv.Atoi(c.StringArgs["n"])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of changes to undo: %v", c.StringArgs["n"])
+			}
+		}
+
+		if n > len(ns.configHistory) {
+			return fmt.Errorf("only %v changes to undo", len(ns.configHistory))
+		}
+
+		// restore the config from before the oldest undone change
+		undone := ns.configHistory[len(ns.configHistory)-n:]
+		ns.vmConfig = undone[0].prev.Copy()
+		ns.configHistory = ns.configHistory[:len(ns.configHistory)-n]
+
+		for _, m := range undone {
+			resp.Response += fmt.Sprintf("undid `%v`\n", m.command)
+		}
+
+		return nil
 	}
 
 	// Print the config
@@ -1,4 +1,4 @@
-// Code generated by "vmconfiger -type BaseConfig,KVMConfig,ContainerConfig"; DO NOT EDIT
+// Code generated by "vmconfiger -type BaseConfig,KVMConfig,ContainerConfig,FirecrackerConfig"; DO NOT EDIT
 
 package main
 
@@ -39,6 +39,42 @@ Note: this configuration only applies to containers and must be specified.
 			return nil
 		}),
 	},
+	{
+		HelpShort: "configures layers",
+		HelpLong: `Add additional read-only layers on top of the filesystem for launching
+a container. Layers are overlaid in the order they are specified, so
+later layers shadow files in earlier layers and in the base
+filesystem. The layers are composed with overlayfs, so layering
+requires snapshot mode; each VM still gets its own writable overlay in
+the instance path.
+
+For example, to share a base rootfs between VMs and add a webserver
+install on top:
+
+	vm config filesystem /containerfs/base
+	vm config layers /containerfs/apache
+
+Note: this configuration only applies to containers.
+`,
+		Patterns: []string{
+			"vm config layers [value]...",
+		},
+
+		Call: wrapSimpleCLI(func(ns *Namespace, c *minicli.Command, r *minicli.Response) error {
+			if len(c.ListArgs) == 0 {
+				if len(ns.vmConfig.Layers) == 0 {
+					return nil
+				}
+
+				r.Response = fmt.Sprintf("%v", ns.vmConfig.Layers)
+				return nil
+			}
+
+			ns.vmConfig.Layers = c.ListArgs["value"]
+
+			return nil
+		}),
+	},
 	{
 		HelpShort: "configures hostname",
 		HelpLong: `Set a hostname for a container before launching the init program. If not
@@ -212,6 +248,103 @@ Default: empty map
 			return nil
 		}),
 	},
+	{
+		HelpShort: "configures firecracker",
+		HelpLong: `Set the firecracker binary name to invoke. Relative paths are ok.
+
+Note: this configuration only applies to firecracker-based VMs.
+
+Default: "firecracker"
+`,
+		Patterns: []string{
+			"vm config firecracker [value]",
+		},
+
+		Call: wrapSimpleCLI(func(ns *Namespace, c *minicli.Command, r *minicli.Response) error {
+			if len(c.StringArgs) == 0 {
+				r.Response = ns.vmConfig.FirecrackerPath
+				return nil
+			}
+
+			v := checkPath(c.StringArgs["value"])
+
+			ns.vmConfig.FirecrackerPath = v
+
+			return nil
+		}),
+	},
+	{
+		HelpShort: "configures firecracker-kernel",
+		HelpLong: `Attach an uncompressed kernel image (vmlinux) to a firecracker VM.
+Firecracker always boots directly from a kernel -- there is no BIOS.
+
+Note: this configuration only applies to firecracker-based VMs.
+`,
+		Patterns: []string{
+			"vm config firecracker-kernel [value]",
+		},
+
+		Call: wrapSimpleCLI(func(ns *Namespace, c *minicli.Command, r *minicli.Response) error {
+			if len(c.StringArgs) == 0 {
+				r.Response = ns.vmConfig.FirecrackerKernelPath
+				return nil
+			}
+
+			v := checkPath(c.StringArgs["value"])
+
+			ns.vmConfig.FirecrackerKernelPath = v
+
+			return nil
+		}),
+	},
+	{
+		HelpShort: "configures firecracker-rootfs",
+		HelpLong: `Attach a root filesystem image (e.g. raw ext4) to a firecracker VM.
+Filesystems launched in snapshot mode are copied to the VM's instance
+directory so a single image may safely be used for many VMs.
+
+Note: this configuration only applies to firecracker-based VMs.
+`,
+		Patterns: []string{
+			"vm config firecracker-rootfs [value]",
+		},
+
+		Call: wrapSimpleCLI(func(ns *Namespace, c *minicli.Command, r *minicli.Response) error {
+			if len(c.StringArgs) == 0 {
+				r.Response = ns.vmConfig.FirecrackerRootfsPath
+				return nil
+			}
+
+			v := checkPath(c.StringArgs["value"])
+
+			ns.vmConfig.FirecrackerRootfsPath = v
+
+			return nil
+		}),
+	},
+	{
+		HelpShort: "configures firecracker-append",
+		HelpLong: `Set the kernel command line for a firecracker VM.
+
+Note: this configuration only applies to firecracker-based VMs.
+
+Default: "console=ttyS0 reboot=k panic=1 pci=off"
+`,
+		Patterns: []string{
+			"vm config firecracker-append [value]",
+		},
+
+		Call: wrapSimpleCLI(func(ns *Namespace, c *minicli.Command, r *minicli.Response) error {
+			if len(c.StringArgs) == 0 {
+				r.Response = ns.vmConfig.FirecrackerAppend
+				return nil
+			}
+
+			ns.vmConfig.FirecrackerAppend = c.StringArgs["value"]
+
+			return nil
+		}),
+	},
 	{
 		HelpShort: "configures qemu",
 		HelpLong: `Set the QEMU binary name to invoke. Relative paths are ok.
@@ -377,6 +510,38 @@ Default: "host"
 			return nil
 		}),
 	},
+	{
+		HelpShort: "configures cpu-flags",
+		HelpLong: `Add or remove CPUID feature flags on the virtual CPU, as a
+comma-separated list of flags prefixed with '+' to enable or '-' to
+disable. The flags are appended to the -cpu argument. For example, to
+enable AES-NI and hide the VMware IO port:
+
+	vm config cpu-flags +aes,-vmport
+
+See 'qemu -cpu help' for the full list of flags.
+
+Note: this configuration only applies to KVM-based VMs.
+`,
+		Patterns: []string{
+			"vm config cpu-flags [value]",
+		},
+
+		Call: wrapSimpleCLI(func(ns *Namespace, c *minicli.Command, r *minicli.Response) error {
+			if len(c.StringArgs) == 0 {
+				r.Response = ns.vmConfig.CpuFlags
+				return nil
+			}
+
+			if err := validCpuFlags(ns.vmConfig, c.StringArgs["value"]); err != nil {
+				return err
+			}
+
+			ns.vmConfig.CpuFlags = c.StringArgs["value"]
+
+			return nil
+		}),
+	},
 	{
 		HelpShort: "configures sockets",
 		HelpLong: `Set the number of CPU sockets. If unspecified, QEMU will calculate
@@ -483,6 +648,67 @@ Note: this configuration only applies to KVM-based VMs.
 			return nil
 		}),
 	},
+	{
+		HelpShort: "configures arch",
+		HelpLong: `Set the guest architecture. Supported values are "x86_64" and
+"aarch64". When unset, guests match the host architecture.
+
+Setting "aarch64" on an x86 host switches the QEMU binary to
+qemu-system-aarch64 (unless 'vm config qemu' was changed from its
+default), selects the "virt" machine type unless 'vm config machine'
+is set, and provides a USB bus via xHCI since "virt" has none built
+in. Cross-architecture guests run under TCG emulation rather than KVM
+acceleration and default to the "max" CPU model instead of "host".
+
+aarch64 guests have no default BIOS -- point 'vm config firmware' at
+an EDK2 image.
+
+Note: this configuration only applies to KVM-based VMs.
+`,
+		Patterns: []string{
+			"vm config arch [value]",
+		},
+
+		Call: wrapSimpleCLI(func(ns *Namespace, c *minicli.Command, r *minicli.Response) error {
+			if len(c.StringArgs) == 0 {
+				r.Response = ns.vmConfig.Arch
+				return nil
+			}
+
+			if err := validArch(ns.vmConfig, c.StringArgs["value"]); err != nil {
+				return err
+			}
+
+			ns.vmConfig.Arch = c.StringArgs["value"]
+
+			return nil
+		}),
+	},
+	{
+		HelpShort: "configures firmware",
+		HelpLong: `Attach a firmware image (e.g. EDK2/OVMF) to boot the guest with,
+passed to QEMU via -bios. Required for aarch64 guests, which have no
+default BIOS; typically QEMU_EFI.fd from the edk2-aarch64 package.
+
+Note: this configuration only applies to KVM-based VMs.
+`,
+		Patterns: []string{
+			"vm config firmware [value]",
+		},
+
+		Call: wrapSimpleCLI(func(ns *Namespace, c *minicli.Command, r *minicli.Response) error {
+			if len(c.StringArgs) == 0 {
+				r.Response = ns.vmConfig.FirmwarePath
+				return nil
+			}
+
+			v := checkPath(c.StringArgs["value"])
+
+			ns.vmConfig.FirmwarePath = v
+
+			return nil
+		}),
+	},
 	{
 		HelpShort: "configures serial-ports",
 		HelpLong: `Specify the serial ports that will be created for the VM to use. Serial
@@ -659,7 +885,7 @@ socket at the path provided
 	{
 		HelpShort: "configures bidirectional-copy-paste",
 		HelpLong: `Enables bidirectional copy paste instead of basic pasting into VM.
-Requries QEMU 6.1+ compiled with spice and for spice-vdagent to be installed on VM.
+Requires QEMU 6.1+ compiled with qemu-vdagent chardev and for spice-vdagent to be installed on VM.
 
 Default: false
 `,
@@ -677,6 +903,71 @@ Default: false
 			return nil
 		}),
 	},
+	{
+		HelpShort: "configures nested",
+		HelpLong: `Enable nested virtualization by exposing the hardware virtualization
+extensions (vmx on Intel, svm on AMD) to the virtual CPU. Needed for
+experiments that run hypervisors inside VMs. Requires nested support
+to be enabled in the host's kvm_intel or kvm_amd module -- launching
+will fail if it is not.
+
+Note: this configuration only applies to KVM-based VMs.
+
+Default: false
+`,
+		Patterns: []string{
+			"vm config nested [true,false]",
+		},
+		Call: wrapSimpleCLI(func(ns *Namespace, c *minicli.Command, r *minicli.Response) error {
+			if len(c.BoolArgs) == 0 {
+				r.Response = strconv.FormatBool(ns.vmConfig.Nested)
+				return nil
+			}
+
+			ns.vmConfig.Nested = c.BoolArgs["true"]
+
+			return nil
+		}),
+	},
+	{
+		HelpShort: "configures rtc-base",
+		HelpLong: `Set the base date-time for the guest's real-time clock instead of
+syncing it to the host clock at launch. Accepts either a date-time in
+the format "2006-01-02T15:04:05", which the guest clock starts at, or a
+signed offset from the host clock such as "-240h" or "30m", which is
+resolved when the VM launches. The guest clock then advances normally
+from that base, so date-dependent behaviors such as certificate expiry
+can be tested without changing the host clock.
+
+Examples:
+
+To start the guest clock ten days in the past:
+	vm config rtc-base -240h
+
+To start the guest clock at a specific instant:
+	vm config rtc-base 2030-01-01T00:00:00
+
+Note: this configuration only applies to KVM-based VMs.
+`,
+		Patterns: []string{
+			"vm config rtc-base [value]",
+		},
+
+		Call: wrapSimpleCLI(func(ns *Namespace, c *minicli.Command, r *minicli.Response) error {
+			if len(c.StringArgs) == 0 {
+				r.Response = ns.vmConfig.RtcBase
+				return nil
+			}
+
+			if err := validRtcBase(ns.vmConfig, c.StringArgs["value"]); err != nil {
+				return err
+			}
+
+			ns.vmConfig.RtcBase = c.StringArgs["value"]
+
+			return nil
+		}),
+	},
 	{
 		HelpShort: "configures qemu-append",
 		HelpLong: `Add additional arguments to be passed to the QEMU instance. For example:
@@ -901,6 +1192,38 @@ Default: true
 			return nil
 		}),
 	},
+	{
+		HelpShort: "configures mac-vendor",
+		HelpLong: `Generate MAC addresses for unassigned interfaces using OUIs registered
+to a specific hardware vendor instead of a randomly chosen prefix. The
+value is matched case-insensitively against registered vendor names, so
+"dell", "cisco", or "intel" all work. Useful when passive
+fingerprinting tools in an experiment should see believable hardware.
+Explicitly configured MAC addresses are not affected.
+
+For example, to make VMs look like Dell hardware:
+
+	vm config mac-vendor dell
+`,
+		Patterns: []string{
+			"vm config mac-vendor [value]",
+		},
+
+		Call: wrapSimpleCLI(func(ns *Namespace, c *minicli.Command, r *minicli.Response) error {
+			if len(c.StringArgs) == 0 {
+				r.Response = ns.vmConfig.MacVendor
+				return nil
+			}
+
+			if err := validMacVendor(ns.vmConfig, c.StringArgs["value"]); err != nil {
+				return err
+			}
+
+			ns.vmConfig.MacVendor = c.StringArgs["value"]
+
+			return nil
+		}),
+	},
 	{
 		HelpShort: "configures tags",
 		HelpLong: `Set tags in the same manner as "vm tag". These tags will apply to all
@@ -946,6 +1269,7 @@ Default: empty map
 		Patterns: []string{
 			"clear vm config",
 			"clear vm config <append,>",
+			"clear vm config <arch,>",
 			"clear vm config <backchannel,>",
 			"clear vm config <bidirectional-copy-paste,>",
 			"clear vm config <bonds,>",
@@ -954,22 +1278,32 @@ Default: empty map
 			"clear vm config <colocate,>",
 			"clear vm config <cores,>",
 			"clear vm config <coschedule,>",
+			"clear vm config <cpu-flags,>",
 			"clear vm config <disks,>",
 			"clear vm config <fifos,>",
 			"clear vm config <filesystem,>",
+			"clear vm config <firecracker-append,>",
+			"clear vm config <firecracker-kernel,>",
+			"clear vm config <firecracker,>",
+			"clear vm config <firecracker-rootfs,>",
+			"clear vm config <firmware,>",
 			"clear vm config <hostname,>",
 			"clear vm config <init,>",
 			"clear vm config <initrd,>",
 			"clear vm config <kernel,>",
+			"clear vm config <layers,>",
+			"clear vm config <mac-vendor,>",
 			"clear vm config <machine,>",
 			"clear vm config <memory,>",
 			"clear vm config <state,>",
 			"clear vm config <migrate,>",
+			"clear vm config <nested,>",
 			"clear vm config <networks,>",
 			"clear vm config <preinit,>",
 			"clear vm config <qemu-append,>",
 			"clear vm config <qemu-override,>",
 			"clear vm config <qemu,>",
+			"clear vm config <rtc-base,>",
 			"clear vm config <schedule,>",
 			"clear vm config <serial-ports,>",
 			"clear vm config <snapshot,>",
@@ -1025,6 +1359,9 @@ func (v *BaseConfig) Info(field string) (string, error) {
 	if field == "backchannel" {
 		return strconv.FormatBool(v.Backchannel), nil
 	}
+	if field == "mac-vendor" {
+		return v.MacVendor, nil
+	}
 	if field == "networks" {
 		return fmt.Sprintf("%v", v.Networks), nil
 	}
@@ -1063,6 +1400,9 @@ func (v *BaseConfig) Clear(mask string) {
 	if mask == Wildcard || mask == "backchannel" {
 		v.Backchannel = true
 	}
+	if mask == Wildcard || mask == "mac-vendor" {
+		v.MacVendor = ""
+	}
 	if mask == Wildcard || mask == "networks" {
 		v.Networks = NetConfigs{}
 	}
@@ -1099,6 +1439,9 @@ func (v *BaseConfig) WriteConfig(w io.Writer) error {
 	if v.Backchannel != true {
 		fmt.Fprintf(w, "vm config backchannel %t\n", v.Backchannel)
 	}
+	if v.MacVendor != "" {
+		fmt.Fprintf(w, "vm config mac-vendor %v\n", v.MacVendor)
+	}
 	if err := v.Networks.WriteConfig(w); err != nil {
 		return err
 	}
@@ -1142,6 +1485,8 @@ func (v *BaseConfig) ReadConfig(r io.Reader, ns string) error {
 			v.Coschedule, _ = strconv.ParseInt(config[1], 10, 64)
 		case "backchannel":
 			v.Backchannel, _ = strconv.ParseBool(config[1])
+		case "mac-vendor":
+			v.MacVendor = config[1]
 		case "networks":
 			v.ReadFieldConfig(strings.NewReader(line), "networks", ns)
 		case "bonds":
@@ -1162,6 +1507,9 @@ func (v *ContainerConfig) Info(field string) (string, error) {
 	if field == "filesystem" {
 		return v.FilesystemPath, nil
 	}
+	if field == "layers" {
+		return fmt.Sprintf("%v", v.Layers), nil
+	}
 	if field == "hostname" {
 		return v.Hostname, nil
 	}
@@ -1185,6 +1533,9 @@ func (v *ContainerConfig) Clear(mask string) {
 	if mask == Wildcard || mask == "filesystem" {
 		v.FilesystemPath = ""
 	}
+	if mask == Wildcard || mask == "layers" {
+		v.Layers = nil
+	}
 	if mask == Wildcard || mask == "hostname" {
 		v.Hostname = ""
 	}
@@ -1206,6 +1557,9 @@ func (v *ContainerConfig) WriteConfig(w io.Writer) error {
 	if v.FilesystemPath != "" {
 		fmt.Fprintf(w, "vm config filesystem %v\n", v.FilesystemPath)
 	}
+	if len(v.Layers) > 0 {
+		fmt.Fprintf(w, "vm config layers %v\n", quoteJoin(v.Layers, " "))
+	}
 	if v.Hostname != "" {
 		fmt.Fprintf(w, "vm config hostname %v\n", v.Hostname)
 	}
@@ -1241,6 +1595,8 @@ func (v *ContainerConfig) ReadConfig(r io.Reader, ns string) error {
 		switch field {
 		case "filesystem":
 			v.FilesystemPath = config[1]
+		case "layers":
+			v.Layers = strings.Fields(config[1])
 		case "hostname":
 			v.Hostname = config[1]
 		case "init":
@@ -1261,6 +1617,87 @@ func (v *ContainerConfig) ReadConfig(r io.Reader, ns string) error {
 	return nil
 }
 
+func (v *FirecrackerConfig) Info(field string) (string, error) {
+	if field == "firecracker" {
+		return v.FirecrackerPath, nil
+	}
+	if field == "firecracker-kernel" {
+		return v.FirecrackerKernelPath, nil
+	}
+	if field == "firecracker-rootfs" {
+		return v.FirecrackerRootfsPath, nil
+	}
+	if field == "firecracker-append" {
+		return v.FirecrackerAppend, nil
+	}
+
+	return "", fmt.Errorf("invalid info field: %v", field)
+}
+
+func (v *FirecrackerConfig) Clear(mask string) {
+	if mask == Wildcard || mask == "firecracker" {
+		v.FirecrackerPath = "firecracker"
+	}
+	if mask == Wildcard || mask == "firecracker-kernel" {
+		v.FirecrackerKernelPath = ""
+	}
+	if mask == Wildcard || mask == "firecracker-rootfs" {
+		v.FirecrackerRootfsPath = ""
+	}
+	if mask == Wildcard || mask == "firecracker-append" {
+		v.FirecrackerAppend = "console=ttyS0 reboot=k panic=1 pci=off"
+	}
+}
+
+func (v *FirecrackerConfig) WriteConfig(w io.Writer) error {
+	if v.FirecrackerPath != "firecracker" {
+		fmt.Fprintf(w, "vm config firecracker %v\n", v.FirecrackerPath)
+	}
+	if v.FirecrackerKernelPath != "" {
+		fmt.Fprintf(w, "vm config firecracker-kernel %v\n", v.FirecrackerKernelPath)
+	}
+	if v.FirecrackerRootfsPath != "" {
+		fmt.Fprintf(w, "vm config firecracker-rootfs %v\n", v.FirecrackerRootfsPath)
+	}
+	if v.FirecrackerAppend != "console=ttyS0 reboot=k panic=1 pci=off" {
+		fmt.Fprintf(w, "vm config firecracker-append %v\n", v.FirecrackerAppend)
+	}
+
+	return nil
+}
+
+func (v *FirecrackerConfig) ReadConfig(r io.Reader, ns string) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if !strings.HasPrefix(line, "vm config") {
+			continue
+		}
+
+		config := strings.Fields(line)[2:]
+		field := config[0]
+
+		switch field {
+		case "firecracker":
+			v.FirecrackerPath = config[1]
+		case "firecracker-kernel":
+			v.FirecrackerKernelPath = config[1]
+		case "firecracker-rootfs":
+			v.FirecrackerRootfsPath = config[1]
+		case "firecracker-append":
+			v.FirecrackerAppend = config[1]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (v *KVMConfig) Info(field string) (string, error) {
 	if field == "qemu" {
 		return v.QemuPath, nil
@@ -1280,6 +1717,9 @@ func (v *KVMConfig) Info(field string) (string, error) {
 	if field == "cpu" {
 		return v.CPU, nil
 	}
+	if field == "cpu-flags" {
+		return v.CpuFlags, nil
+	}
 	if field == "sockets" {
 		return strconv.FormatUint(v.Sockets, 10), nil
 	}
@@ -1292,6 +1732,12 @@ func (v *KVMConfig) Info(field string) (string, error) {
 	if field == "machine" {
 		return v.Machine, nil
 	}
+	if field == "arch" {
+		return v.Arch, nil
+	}
+	if field == "firmware" {
+		return v.FirmwarePath, nil
+	}
 	if field == "serial-ports" {
 		return strconv.FormatUint(v.SerialPorts, 10), nil
 	}
@@ -1316,6 +1762,12 @@ func (v *KVMConfig) Info(field string) (string, error) {
 	if field == "bidirectional-copy-paste" {
 		return strconv.FormatBool(v.BidirectionalCopyPaste), nil
 	}
+	if field == "nested" {
+		return strconv.FormatBool(v.Nested), nil
+	}
+	if field == "rtc-base" {
+		return v.RtcBase, nil
+	}
 	if field == "qemu-append" {
 		return fmt.Sprintf("%v", v.QemuAppend), nil
 	}
@@ -1345,6 +1797,9 @@ func (v *KVMConfig) Clear(mask string) {
 	if mask == Wildcard || mask == "cpu" {
 		v.CPU = "host"
 	}
+	if mask == Wildcard || mask == "cpu-flags" {
+		v.CpuFlags = ""
+	}
 	if mask == Wildcard || mask == "sockets" {
 		v.Sockets = 0
 	}
@@ -1357,6 +1812,12 @@ func (v *KVMConfig) Clear(mask string) {
 	if mask == Wildcard || mask == "machine" {
 		v.Machine = ""
 	}
+	if mask == Wildcard || mask == "arch" {
+		v.Arch = ""
+	}
+	if mask == Wildcard || mask == "firmware" {
+		v.FirmwarePath = ""
+	}
 	if mask == Wildcard || mask == "serial-ports" {
 		v.SerialPorts = 0
 	}
@@ -1381,6 +1842,12 @@ func (v *KVMConfig) Clear(mask string) {
 	if mask == Wildcard || mask == "bidirectional-copy-paste" {
 		v.BidirectionalCopyPaste = false
 	}
+	if mask == Wildcard || mask == "nested" {
+		v.Nested = false
+	}
+	if mask == Wildcard || mask == "rtc-base" {
+		v.RtcBase = ""
+	}
 	if mask == Wildcard || mask == "qemu-append" {
 		v.QemuAppend = nil
 	}
@@ -1403,11 +1870,14 @@ func (v *KVMConfig) WriteConfig(w io.Writer) error {
 		fmt.Fprintf(w, "vm config cdrom %v\n", v.CdromPath)
 	}
 	if v.MigratePath != "" {
-		fmt.Fprintf(w, "vm config state %v\n", v.MigratePath)
+		fmt.Fprintf(w, "vm config migrate %v\n", v.MigratePath)
 	}
 	if v.CPU != "host" {
 		fmt.Fprintf(w, "vm config cpu %v\n", v.CPU)
 	}
+	if v.CpuFlags != "" {
+		fmt.Fprintf(w, "vm config cpu-flags %v\n", v.CpuFlags)
+	}
 	if v.Sockets != 0 {
 		fmt.Fprintf(w, "vm config sockets %v\n", v.Sockets)
 	}
@@ -1420,6 +1890,12 @@ func (v *KVMConfig) WriteConfig(w io.Writer) error {
 	if v.Machine != "" {
 		fmt.Fprintf(w, "vm config machine %v\n", v.Machine)
 	}
+	if v.Arch != "" {
+		fmt.Fprintf(w, "vm config arch %v\n", v.Arch)
+	}
+	if v.FirmwarePath != "" {
+		fmt.Fprintf(w, "vm config firmware %v\n", v.FirmwarePath)
+	}
 	if v.SerialPorts != 0 {
 		fmt.Fprintf(w, "vm config serial-ports %v\n", v.SerialPorts)
 	}
@@ -1444,6 +1920,12 @@ func (v *KVMConfig) WriteConfig(w io.Writer) error {
 	if v.BidirectionalCopyPaste != false {
 		fmt.Fprintf(w, "vm config bidirectional-copy-paste %t\n", v.BidirectionalCopyPaste)
 	}
+	if v.Nested != false {
+		fmt.Fprintf(w, "vm config nested %t\n", v.Nested)
+	}
+	if v.RtcBase != "" {
+		fmt.Fprintf(w, "vm config rtc-base %v\n", v.RtcBase)
+	}
 	if len(v.QemuAppend) > 0 {
 		fmt.Fprintf(w, "vm config qemu-append %v\n", quoteJoin(v.QemuAppend, " "))
 	}
@@ -1482,6 +1964,8 @@ func (v *KVMConfig) ReadConfig(r io.Reader, ns string) error {
 			v.MigratePath = config[1]
 		case "cpu":
 			v.CPU = config[1]
+		case "cpu-flags":
+			v.CpuFlags = config[1]
 		case "sockets":
 			v.Sockets, _ = strconv.ParseUint(config[1], 10, 64)
 		case "cores":
@@ -1490,6 +1974,10 @@ func (v *KVMConfig) ReadConfig(r io.Reader, ns string) error {
 			v.Threads, _ = strconv.ParseUint(config[1], 10, 64)
 		case "machine":
 			v.Machine = config[1]
+		case "arch":
+			v.Arch = config[1]
+		case "firmware":
+			v.FirmwarePath = config[1]
 		case "serial-ports":
 			v.SerialPorts, _ = strconv.ParseUint(config[1], 10, 64)
 		case "virtio-ports":
@@ -1506,6 +1994,10 @@ func (v *KVMConfig) ReadConfig(r io.Reader, ns string) error {
 			v.TpmSocketPath = config[1]
 		case "bidirectional-copy-paste":
 			v.BidirectionalCopyPaste, _ = strconv.ParseBool(config[1])
+		case "nested":
+			v.Nested, _ = strconv.ParseBool(config[1])
+		case "rtc-base":
+			v.RtcBase = config[1]
 		case "qemu-append":
 			v.QemuAppend = strings.Fields(config[1])
 		case "qemu-override":
@@ -304,6 +304,10 @@ func (vms *VMs) Launch(requestor, namespace string, q *QueuedVMs) <-chan error {
 					errs <- err
 					return
 				}
+
+				// push any static address plan to running dnsmasq servers so
+				// that the guest picks it up via DHCP
+				dnsmasqRegisterStatics(vm.GetNetworks())
 			}(name)
 		}
 
@@ -327,22 +331,6 @@ func (vms *VMs) Stop(target string) error {
 	})
 }
 
-// Kill VMs matching target
-func (vms *VMs) Kill(target string) error {
-	return vms.Apply(target, func(vm VM, _ bool) (bool, error) {
-		if vm.GetState()&VM_KILLABLE == 0 {
-			return false, nil
-		}
-
-		if err := vm.Kill(); err != nil {
-			log.Error("unleash the zombie VM: %v", err)
-			return true, err
-		}
-
-		return true, nil
-	})
-}
-
 // Flush VMs matching target.
 func (vms *VMs) Flush(target string, cc *ron.Server) error {
 	var mapLock sync.Mutex
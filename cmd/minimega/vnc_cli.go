@@ -8,8 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
 )
@@ -49,6 +51,15 @@ step command will immediately move to the next event contained in the playback
 file. Use the getstep command to view the current vnc event. Calling stop will
 end a playback.
 
+Long playbacks can be fast-forwarded with the seek command. Events inside the
+skipped window are played immediately rather than dropped so that the input
+stream stays intact. Seeking while paused takes effect on continue:
+
+	vnc seek vm-0 5m
+
+Run vnc without arguments to see the progress (events played/total), elapsed
+time, and remaining duration of each playback.
+
 VNC playback also supports injecting mouse/keyboard events in the format found
 in the playback file. Injected commands must omit the time delta as they are
 sent immediately:
@@ -59,6 +70,16 @@ New playback files can be injected as well:
 
 	vnc inject vm-0 LoadFile,foo.kb
 
+Playbacks on separate VMs can be synchronized with barriers so that recordings
+of coordinated multi-operator behavior replay in lockstep. A barrier line
+names the barrier and the number of playbacks expected at it -- each playback
+that reaches the barrier waits until all of the expected playbacks arrive:
+
+	0:Barrier,logged-in,3
+
+Barriers with the same name rendezvous across playback files, so starting each
+file with a barrier starts the playbacks in lockstep.
+
 Comments in the playback file are logged at the info level. An example is given
 below.
 
@@ -69,6 +90,7 @@ below.
 			"vnc <stop,> <vm target>",
 			"vnc <pause,> <vm target>",
 			"vnc <continue,> <vm target>",
+			"vnc <seek,> <vm target> <duration>",
 			"vnc <step,> <vm target>",
 			"vnc <getstep,> <vm target>",
 			"vnc <inject,> <vm target> <cmd>",
@@ -76,14 +98,68 @@ below.
 		Call:    wrapVMTargetCLI(cliVNCPlay),
 		Suggest: wrapVMSuggest(VM_ANY_STATE, false),
 	},
+	{
+		HelpShort: "build and list named vnc macros",
+		HelpLong: `
+Build and list named macros of vnc events. Macros are stored server-side and
+can be played on any VM with "vnc macro play", making it easy to reuse common
+input sequences such as login sequences or browsing loops.
+
+Events use the same format as playback files with an optional leading delay.
+Multiple events can be added at once and are appended in order:
+
+	vnc macro add login KeyEvent,true,u 50ms:KeyEvent,false,u
+
+Run vnc macro without arguments to list the defined macros. Macros are
+deleted with "clear vnc macro".`,
+		Patterns: []string{
+			"vnc macro",
+			"vnc macro <add,> <name> <event>...",
+		},
+		Call: wrapBroadcastCLI(cliVNCMacro),
+	},
+	{
+		HelpShort: "play a vnc macro",
+		HelpLong: `
+Play a named macro on the specified VM(s). See "vnc macro" for defining
+macros and "vm start" for a full description of the allowable targets.
+
+The optional jitter adds a random delay of up to the given duration before
+each event so that the replayed input has human-like timing rather than the
+machine-perfect timing of the recording:
+
+	vnc macro play login vm-0 150ms
+
+The random delays are resampled on every play.`,
+		Patterns: []string{
+			"vnc macro <play,> <name> <vm target> [jitter]",
+		},
+		Call:    wrapVMTargetCLI(cliVNCMacroPlay),
+		Suggest: wrapVMSuggest(VM_ANY_STATE, false),
+	},
 	{
 		HelpShort: "reset VNC state",
 		HelpLong: `
-Resets the state for VNC recordings. See "help vnc" for more information.`,
+Resets the state for VNC recordings and playbacks, including any defined
+macros. To delete a single macro, specify its name:
+
+	clear vnc macro login
+
+See "help vnc" for more information.`,
 		Patterns: []string{
 			"clear vnc",
+			"clear vnc <macro,> [name]",
 		},
-		Call: wrapBroadcastCLI(func(ns *Namespace, _ *minicli.Command, _ *minicli.Response) error {
+		Call: wrapBroadcastCLI(func(ns *Namespace, c *minicli.Command, _ *minicli.Response) error {
+			if c.BoolArgs["macro"] {
+				name := c.StringArgs["name"]
+				if name == Wildcard {
+					name = ""
+				}
+
+				return ns.Player.DeleteMacros(name)
+			}
+
 			ns.Recorder.Clear()
 			ns.Player.Clear()
 			return nil
@@ -110,6 +186,16 @@ func cliVNCPlay(ns *Namespace, c *minicli.Command, resp *minicli.Response) error
 
 	target := c.StringArgs["vm"]
 
+	var seek time.Duration
+	if c.BoolArgs["seek"] {
+		v, err := time.ParseDuration(c.StringArgs["duration"])
+		if err != nil || v <= 0 {
+			return fmt.Errorf("invalid duration: `%v`", c.StringArgs["duration"])
+		}
+
+		seek = v
+	}
+
 	// synchronize adding rows to resp.Tabular for getstep
 	var mu sync.Mutex
 	if c.BoolArgs["getstep"] {
@@ -138,6 +224,8 @@ func cliVNCPlay(ns *Namespace, c *minicli.Command, resp *minicli.Response) error
 			return true, ns.Player.Pause(id)
 		case c.BoolArgs["continue"]:
 			return true, ns.Player.Continue(id)
+		case c.BoolArgs["seek"]:
+			return true, ns.Player.Seek(id, seek)
 		case c.BoolArgs["step"]:
 			return true, ns.Player.Step(id)
 		case c.BoolArgs["getstep"]:
@@ -161,6 +249,56 @@ func cliVNCPlay(ns *Namespace, c *minicli.Command, resp *minicli.Response) error
 	})
 }
 
+func cliVNCMacro(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	if c.BoolArgs["add"] {
+		name := c.StringArgs["name"]
+
+		for _, e := range c.ListArgs["event"] {
+			if err := ns.Player.AddMacroEvent(name, e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// no arguments, list the macros
+	resp.Header = []string{"name", "events", "duration"}
+	resp.Tabular = ns.Player.MacroInfo()
+
+	sort.Slice(resp.Tabular, func(i, j int) bool {
+		return resp.Tabular[i][0] < resp.Tabular[j][0]
+	})
+
+	return nil
+}
+
+func cliVNCMacroPlay(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
+	var jitter time.Duration
+
+	if c.StringArgs["jitter"] != "" {
+		v, err := time.ParseDuration(c.StringArgs["jitter"])
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid jitter: `%v`", c.StringArgs["jitter"])
+		}
+
+		jitter = v
+	}
+
+	name := c.StringArgs["name"]
+
+	return ns.Apply(c.StringArgs["vm"], func(vm VM, _ bool) (bool, error) {
+		kvm, ok := vm.(*KvmVM)
+		if !ok {
+			return false, nil
+		}
+
+		rhost := fmt.Sprintf("%v:%v", kvm.GetHost(), kvm.VNCPort)
+
+		return true, ns.Player.PlayMacro(kvm.GetName(), rhost, name, jitter)
+	})
+}
+
 func cliVNCRecord(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	fname := c.StringArgs["filename"]
 	// Ensure that relative paths are always relative to /files/
@@ -193,7 +331,7 @@ func cliVNCRecord(ns *Namespace, c *minicli.Command, resp *minicli.Response) err
 
 // List all active recordings and playbacks
 func cliVNCList(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
-	resp.Header = []string{"name", "type", "time", "filename"}
+	resp.Header = []string{"name", "type", "state", "progress", "elapsed", "remaining", "filename"}
 
 	resp.Tabular = append(resp.Tabular, ns.Recorder.Info()...)
 	resp.Tabular = append(resp.Tabular, ns.Player.Info()...)
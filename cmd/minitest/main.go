@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/sandia-minimega/minimega/v2/pkg/miniclient"
@@ -31,6 +33,8 @@ the U.S. Government retains certain rights in this software.`
 var skippedExtensions = []string{
 	".got",
 	".want",
+	".setup",
+	".teardown",
 }
 
 var (
@@ -58,6 +62,22 @@ func (c Client) mustRunCommands(file string) string {
 
 // runCommands reads and runs all the commands from a file. Return the
 // concatenation of all the Responses or an error.
+//
+// Lines starting with "#" followed by a known keyword are directives rather
+// than commands:
+//
+//	# expect <regex>        assert the previous command's output matches
+//	# expect-json <path> [value]
+//	                        assert a dotted path exists in the previous
+//	                        command's output, parsed as JSON, optionally
+//	                        with the given value
+//	# on <host>             run subsequent commands on host via `mesh send`,
+//	                        "# on local" reverts to the local node
+//
+// Directives produce no output of their own unless an assertion fails, in
+// which case an "A: " line shows up in the output so that it causes a
+// mismatch against the .want file. Any other "#" line is echoed and run as
+// before, so existing tests that use comments are unaffected.
 func (c Client) runCommands(file string) (string, error) {
 	f, err := os.Open(file)
 	if err != nil {
@@ -67,15 +87,34 @@ func (c Client) runCommands(file string) (string, error) {
 	var b bytes.Buffer
 	s := bufio.NewScanner(f)
 
+	// output of the most recent command, for assertions
+	var last string
+
+	// host to run commands on via `mesh send`, empty means local
+	var host string
+
 	for s.Scan() {
 		cmd := s.Text()
 
+		if isDirective(cmd) {
+			if msg := runDirective(cmd, last, &host); msg != "" {
+				fmt.Fprintf(&b, "A: %v\n", msg)
+			}
+			continue
+		}
+
 		if len(cmd) > 0 {
 			fmt.Fprintf(&b, "## %v\n", cmd)
+
+			if host != "" && !strings.HasPrefix(cmd, "#") {
+				cmd = fmt.Sprintf("mesh send %v %v", host, cmd)
+			}
 		} else {
 			b.WriteString("\n")
 		}
 
+		var out bytes.Buffer
+
 		for resps := range c.Run(cmd) {
 			var errs []string
 			for _, resp := range resps.Resp {
@@ -86,15 +125,21 @@ func (c Client) runCommands(file string) (string, error) {
 
 			if len(errs) > 0 {
 				sort.Strings(errs)
-				b.WriteString(strings.Join(errs, "\n"))
-				b.WriteString("\n")
+				out.WriteString(strings.Join(errs, "\n"))
+				out.WriteString("\n")
 			}
 
 			if len(resps.Rendered) > 0 {
-				b.WriteString(resps.Rendered)
-				b.WriteString("\n")
+				out.WriteString(resps.Rendered)
+				out.WriteString("\n")
 			}
 		}
+
+		// comments don't produce output so assertions skip over them
+		if len(cmd) > 0 && !strings.HasPrefix(cmd, "#") {
+			last = out.String()
+		}
+		b.Write(out.Bytes())
 	}
 
 	if err := s.Err(); err != nil {
@@ -104,6 +149,108 @@ func (c Client) runCommands(file string) (string, error) {
 	return b.String(), nil
 }
 
+// isDirective returns whether line is a "#" directive rather than a comment.
+func isDirective(line string) bool {
+	if !strings.HasPrefix(line, "#") {
+		return false
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "#"))
+	if len(fields) == 0 {
+		return false
+	}
+
+	switch fields[0] {
+	case "expect", "expect-json", "on":
+		return true
+	}
+
+	return false
+}
+
+// runDirective processes a single "#" directive against the output of the
+// previous command, returning a message if an assertion failed. host is
+// updated by the "on" directive.
+func runDirective(line, last string, host *string) string {
+	fields := strings.Fields(strings.TrimPrefix(line, "#"))
+
+	switch fields[0] {
+	case "expect":
+		if len(fields) < 2 {
+			return "expect requires a regex"
+		}
+
+		pattern := strings.Join(fields[1:], " ")
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Sprintf("invalid regex `%v`: %v", pattern, err)
+		}
+
+		if !re.MatchString(last) {
+			return fmt.Sprintf("output does not match `%v`", pattern)
+		}
+	case "expect-json":
+		if len(fields) < 2 {
+			return "expect-json requires a path"
+		}
+
+		got, err := jsonPath(last, fields[1])
+		if err != nil {
+			return fmt.Sprintf("%v: %v", fields[1], err)
+		}
+
+		if len(fields) > 2 {
+			want := strings.Join(fields[2:], " ")
+			if got != want {
+				return fmt.Sprintf("%v: got `%v`, want `%v`", fields[1], got, want)
+			}
+		}
+	case "on":
+		if len(fields) != 2 {
+			return "on requires a host"
+		}
+
+		if fields[1] == "local" {
+			*host = ""
+		} else {
+			*host = fields[1]
+		}
+	}
+
+	return ""
+}
+
+// jsonPath parses s as JSON and walks the dot-separated path of object keys
+// and array indices, returning the value found as a string.
+func jsonPath(s, path string) (string, error) {
+	var v interface{}
+
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return "", fmt.Errorf("output is not JSON: %v", err)
+	}
+
+	for _, k := range strings.Split(path, ".") {
+		switch v2 := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = v2[k]; !ok {
+				return "", fmt.Errorf("no such key: %v", k)
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(v2) {
+				return "", fmt.Errorf("invalid index: %v", k)
+			}
+			v = v2[i]
+		default:
+			return "", fmt.Errorf("cannot index %T with %v", v, k)
+		}
+	}
+
+	return fmt.Sprintf("%v", v), nil
+}
+
 // write s to f
 func writeString(f, s string) {
 	if err := ioutil.WriteFile(f, []byte(s), os.FileMode(0644)); err != nil {
@@ -182,9 +329,20 @@ func (c Client) runTests(dir string) {
 			c.mustRunCommands(prolog)
 		}
 
+		// run the test's own fixture, if it has one
+		if _, err := os.Stat(abs + ".setup"); err == nil {
+			log.Debug("running setup: %v", abs+".setup")
+			c.mustRunCommands(abs + ".setup")
+		}
+
 		log.Info("running commands from %s", name)
 		got := c.mustRunCommands(abs)
 
+		if _, err := os.Stat(abs + ".teardown"); err == nil {
+			log.Debug("running teardown: %v", abs+".teardown")
+			c.mustRunCommands(abs + ".teardown")
+		}
+
 		if epilog != "" {
 			log.Debug("running epilog: %v", epilog)
 			c.mustRunCommands(epilog)
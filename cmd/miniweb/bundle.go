@@ -0,0 +1,171 @@
+// Copyright 2017-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sandia-minimega/minimega/v2/pkg/miniclient"
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// bundleHandler streams a tarball of the namespace's artifacts -- state
+// listings, screenshots of running VMs, and captures stored in the files
+// directory -- so that everything for an experiment can be pulled in one
+// request:
+//
+//	/bundle.tar.gz
+func bundleHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("bundle request: %v", r.URL)
+
+	name := NewCommand(r).Namespace
+	if name == "" {
+		name = "minimega"
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-artifacts.tar.gz\"", name))
+
+	gz := gzip.NewWriter(w)
+	defer gz.Close()
+
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	now := time.Now()
+
+	add := func(name string, data []byte) {
+		if len(data) == 0 {
+			return
+		}
+
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			log.Error("unable to write %v: %v", name, err)
+			return
+		}
+
+		if _, err := tw.Write(data); err != nil {
+			log.Error("unable to write %v: %v", name, err)
+		}
+	}
+
+	// rendered output of a command, as the CLI would have printed it
+	rendered := func(command string) []byte {
+		cmd := NewCommand(r)
+		cmd.Command = command
+
+		var res string
+		for resps := range run(cmd) {
+			res += resps.Rendered
+		}
+
+		return []byte(res)
+	}
+
+	add("vms.txt", rendered("vm info"))
+	add("namespace.txt", rendered("ns"))
+	add("vlans.txt", rendered("vlans"))
+	add("captures.txt", rendered("capture"))
+	add("history.txt", rendered("history"))
+
+	// screenshots of running KVM VMs
+	cmd := NewCommand(r)
+	cmd.Command = "vm info"
+	cmd.Columns = []string{"name", "type", "state"}
+
+	for _, vm := range runTabular(cmd) {
+		if vm["type"] != "kvm" || vm["state"] != "RUNNING" {
+			continue
+		}
+
+		cmd := NewCommand(r)
+		cmd.Command = fmt.Sprintf("vm screenshot %q file /dev/null", vm["name"])
+
+		for resps := range run(cmd) {
+			for _, resp := range resps.Resp {
+				if resp.Error != "" || resp.Data == nil {
+					continue
+				}
+
+				if s, ok := resp.Data.(string); ok {
+					data, _ := base64.StdEncoding.DecodeString(s)
+					add("screenshots/"+vm["name"]+".png", data)
+				}
+			}
+		}
+	}
+
+	// captures that landed in the files directory
+	cmd = NewCommand(r)
+	cmd.Command = "capture"
+
+	for _, row := range runTabular(cmd) {
+		path := row["path"]
+		if path == "" {
+			continue
+		}
+
+		// capture paths are absolute; we can only fetch the ones served from
+		// the files directory
+		if i := strings.Index(path, "/files/"); i >= 0 {
+			path = path[i+len("/files/"):]
+		} else if filepath.IsAbs(path) {
+			continue
+		}
+
+		add("captures/"+filepath.Base(path), streamFile(r, path))
+	}
+}
+
+// streamFile fetches a file from the files directory over a dedicated
+// connection, like filesHandler does for downloads.
+func streamFile(r *http.Request, path string) []byte {
+	cmd := NewCommand(r)
+	cmd.Command = fmt.Sprintf("file stream %q", path)
+
+	mm, err := miniclient.Dial(*f_base)
+	if err != nil {
+		log.Error("unable to dial: %v", err)
+		return nil
+	}
+	defer mm.Close()
+
+	var res []byte
+
+	for resps := range mm.Run(cmd.String()) {
+		for _, resp := range resps.Resp {
+			if resp.Error != "" {
+				log.Errorln(resp.Error)
+				continue
+			}
+
+			if s, ok := resp.Data.(string); ok {
+				data, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					log.Errorln(err)
+					continue
+				}
+
+				res = append(res, data...)
+			}
+		}
+	}
+
+	return res
+}
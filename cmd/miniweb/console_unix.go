@@ -0,0 +1,122 @@
+// Copyright 2017-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+
+	"github.com/kr/pty"
+	"golang.org/x/net/websocket"
+)
+
+// consoleHandler handles the following URLs:
+//
+//	/console
+//	/console/<pid>/ws
+//	/console/<pid>/size
+//
+// Must have -console=true to enable.
+func consoleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/console" {
+		// create a new console
+		cmd := exec.Command(*f_console, "-attach")
+
+		tty, err := pty.Start(cmd)
+		if err != nil {
+			log.Error("start failed: %v", err)
+			return
+		}
+
+		pid := cmd.Process.Pid
+
+		log.Info("spawned new minimega console, pid = %v", pid)
+
+		ptyMu.Lock()
+		defer ptyMu.Unlock()
+		ptys[pid] = tty
+
+		data := struct{ Pid int }{
+			Pid: pid,
+		}
+		renderTemplate(w, r, "console.tmpl", &data)
+		return
+	}
+
+	path := strings.Split(r.URL.Path, "/")
+
+	if len(path) != 4 {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+
+	pid, err := strconv.Atoi(path[2])
+	if err != nil {
+		http.Error(w, "invalid pid", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+
+	ptyMu.Lock()
+	tty, ok := ptys[pid]
+	if !ok {
+		http.Error(w, "pty not found", http.StatusNotFound)
+		return
+	}
+	ptyMu.Unlock()
+
+	switch path[3] {
+	case "size":
+		rows, err := strconv.ParseUint(r.FormValue("rows"), 10, 16)
+		cols, err2 := strconv.ParseUint(r.FormValue("cols"), 10, 16)
+		if err != nil || err2 != nil {
+			http.Error(w, "invalid rows/cols", http.StatusBadRequest)
+			return
+		}
+
+		log.Info("resize %v to %vx%x", pid, cols, rows)
+
+		ws := struct {
+			R, C, X, Y uint16
+		}{
+			R: uint16(rows), C: uint16(cols),
+		}
+		_, _, errno := syscall.Syscall(
+			syscall.SYS_IOCTL,
+			tty.Fd(),
+			syscall.TIOCSWINSZ,
+			uintptr(unsafe.Pointer(&ws)),
+		)
+		if errno != 0 {
+			log.Error("unable to set winsize: %v", syscall.Errno(errno))
+			http.Error(w, "set winsize failed", http.StatusInternalServerError)
+		}
+
+		// make sure winsize gets processed, hopefully the user isn't typing...
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(tty, "\n")
+		return
+	case "ws":
+		// run this in a separate goroutine so that we unlock ptyMu
+		websocket.Handler(consoleWsHandler(tty, pid)).ServeHTTP(w, r)
+
+		return
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright 2017-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"net/http"
+)
+
+// consoleHandler requires a pty which Windows does not have. The rest of
+// miniweb works against a remote minimega mesh, see -console.
+func consoleHandler(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "console not supported on windows", http.StatusNotImplemented)
+}
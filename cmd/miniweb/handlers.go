@@ -13,20 +13,15 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
-	"time"
-	"unsafe"
 
 	"github.com/sandia-minimega/minimega/v2/pkg/minicli"
 	"github.com/sandia-minimega/minimega/v2/pkg/miniclient"
 	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
 
-	"github.com/kr/pty"
 	"golang.org/x/net/websocket"
 )
 
@@ -258,6 +253,60 @@ func screenshotHandler(w http.ResponseWriter, r *http.Request, name string) {
 	}
 }
 
+// ccHandler handles the following URLs via vmHandler:
+//
+//	/vm/<name>/cc.json
+//
+// It returns the cc client entry for the VM along with the namespace's cc
+// command history so the VM page can show which commands applied to it.
+func ccHandler(w http.ResponseWriter, r *http.Request, name string) {
+	log.Info("cc handler: %v", r.URL.Path)
+
+	client := NewCommand(r)
+	client.Command = "cc clients"
+	client.Filters = []string{fmt.Sprintf("name=%q", name)}
+
+	commands := NewCommand(r)
+	commands.Command = "cc commands"
+
+	respondJSON(w, map[string]interface{}{
+		"client":   runTabular(client),
+		"commands": runTabular(commands),
+	})
+}
+
+// qmpHandler handles the following URLs via vmHandler:
+//
+//	/vm/<name>/qmp.json
+//
+// It polls the VM over QMP for its current status so the VM page can render
+// a live view without holding a QMP connection open.
+func qmpHandler(w http.ResponseWriter, r *http.Request, name string) {
+	log.Info("qmp handler: %v", r.URL.Path)
+
+	res := map[string]interface{}{}
+
+	for _, query := range []string{"query-status", "query-balloon", "query-block"} {
+		cmd := NewCommand(r)
+		cmd.Command = fmt.Sprintf("vm qmp %q '{ \"execute\": %q }'", name, query)
+
+		for resps := range run(cmd) {
+			for _, resp := range resps.Resp {
+				if resp.Error != "" {
+					log.Errorln(resp.Error)
+					continue
+				}
+
+				if resp.Response != "" {
+					res[query] = json.RawMessage(resp.Response)
+				}
+			}
+		}
+	}
+
+	respondJSON(w, res)
+}
+
 // connectHandler handles the following URLs via vmHandler:
 //
 //	/vm/<name>/connect/
@@ -346,6 +395,39 @@ func vmHandler(w http.ResponseWriter, r *http.Request) {
 			screenshotHandler(w, r, name)
 			return
 		}
+	case "detail":
+		if len(fields) == 3 {
+			renderTemplate(w, r, "vm.tmpl", name)
+			return
+		}
+	case "info.json":
+		if len(fields) == 3 {
+			cmd := NewCommand(r)
+			cmd.Command = "vm info"
+			cmd.Filters = []string{fmt.Sprintf("name=%q", name)}
+
+			respondJSON(w, runTabular(cmd))
+			return
+		}
+	case "top.json":
+		if len(fields) == 3 {
+			cmd := NewCommand(r)
+			cmd.Command = "vm top"
+			cmd.Filters = []string{fmt.Sprintf("name=%q", name)}
+
+			respondJSON(w, runTabular(cmd))
+			return
+		}
+	case "cc.json":
+		if len(fields) == 3 {
+			ccHandler(w, r, name)
+			return
+		}
+	case "qmp.json":
+		if len(fields) == 3 {
+			qmpHandler(w, r, name)
+			return
+		}
 	case "start", "stop", "kill":
 		if r.Method == http.MethodPost && len(fields) == 3 {
 			cmd := NewCommand(r)
@@ -466,104 +548,6 @@ func tabularHandler(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, runTabular(cmd))
 }
 
-// consoleHandler handles the following URLs:
-//
-//	/console
-//	/console/<pid>/ws
-//	/console/<pid>/size
-//
-// Must have -console=true to enable.
-func consoleHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path == "/console" {
-		// create a new console
-		cmd := exec.Command(*f_console, "-attach")
-
-		tty, err := pty.Start(cmd)
-		if err != nil {
-			log.Error("start failed: %v", err)
-			return
-		}
-
-		pid := cmd.Process.Pid
-
-		log.Info("spawned new minimega console, pid = %v", pid)
-
-		ptyMu.Lock()
-		defer ptyMu.Unlock()
-		ptys[pid] = tty
-
-		data := struct{ Pid int }{
-			Pid: pid,
-		}
-		renderTemplate(w, r, "console.tmpl", &data)
-		return
-	}
-
-	path := strings.Split(r.URL.Path, "/")
-
-	if len(path) != 4 {
-		http.Error(w, "invalid path", http.StatusBadRequest)
-		return
-	}
-
-	pid, err := strconv.Atoi(path[2])
-	if err != nil {
-		http.Error(w, "invalid pid", http.StatusBadRequest)
-		return
-	}
-
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "bad form", http.StatusBadRequest)
-		return
-	}
-
-	ptyMu.Lock()
-	tty, ok := ptys[pid]
-	if !ok {
-		http.Error(w, "pty not found", http.StatusNotFound)
-		return
-	}
-	ptyMu.Unlock()
-
-	switch path[3] {
-	case "size":
-		rows, err := strconv.ParseUint(r.FormValue("rows"), 10, 16)
-		cols, err2 := strconv.ParseUint(r.FormValue("cols"), 10, 16)
-		if err != nil || err2 != nil {
-			http.Error(w, "invalid rows/cols", http.StatusBadRequest)
-			return
-		}
-
-		log.Info("resize %v to %vx%x", pid, cols, rows)
-
-		ws := struct {
-			R, C, X, Y uint16
-		}{
-			R: uint16(rows), C: uint16(cols),
-		}
-		_, _, errno := syscall.Syscall(
-			syscall.SYS_IOCTL,
-			tty.Fd(),
-			syscall.TIOCSWINSZ,
-			uintptr(unsafe.Pointer(&ws)),
-		)
-		if errno != 0 {
-			log.Error("unable to set winsize: %v", syscall.Errno(errno))
-			http.Error(w, "set winsize failed", http.StatusInternalServerError)
-		}
-
-		// make sure winsize gets processed, hopefully the user isn't typing...
-		time.Sleep(100 * time.Millisecond)
-		io.WriteString(tty, "\n")
-		return
-	case "ws":
-		// run this in a separate goroutine so that we unlock ptyMu
-		websocket.Handler(consoleWsHandler(tty, pid)).ServeHTTP(w, r)
-
-		return
-	}
-}
-
 // commandHandler handles the following URLs:
 //
 //	/command
@@ -614,6 +598,31 @@ func minibuilderHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch lp {
 	case "export":
+		// translate the drawn topology into a minimega script
+		data, err := url.QueryUnescape(r.FormValue("xml"))
+		if err != nil || data == "" {
+			http.Error(w, "invalid xml", http.StatusBadRequest)
+			return
+		}
+
+		script, err := graphToScript(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fname := r.FormValue("filename")
+		if fname == "" {
+			fname = "topology.mm"
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+
+		dis := fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s", fname, fname)
+		w.Header().Set("Content-Disposition", dis)
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprint(w, script)
 	case "save":
 		// target filename
 		fname := r.FormValue("filename")
@@ -643,6 +652,36 @@ func minibuilderHandler(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, data)
 
 	case "open":
+		// echo an uploaded drawing back into the editor or, with no upload,
+		// build a drawing of the namespace's current topology
+		var data string
+
+		if f, _, err := r.FormFile("upload"); err == nil {
+			b, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				http.Error(w, "unable to read upload", http.StatusBadRequest)
+				return
+			}
+
+			data = string(b)
+		} else {
+			cmd := NewCommand(r)
+			cmd.Command = "vm info"
+			cmd.Columns = []string{"name", "vlan"}
+
+			vms := runTabular(cmd)
+			sortVMs(vms)
+
+			data = namespaceGraph(vms)
+		}
+
+		// respond in the format that the editor's open dialog expects,
+		// escaping the XML so that it survives the round trip as a JS string
+		data = strings.Replace(url.QueryEscape(data), "+", "%20", -1)
+
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><body><script type=\"text/javascript\">window.parent.openFile.setData(decodeURIComponent('%s'), null);</script></body></html>", data)
 	}
 }
 
@@ -34,6 +34,8 @@ var (
 	f_key       = flag.String("key", "", "key file for TLS in PEM format")
 	f_cert      = flag.String("cert", "", "cert file for TLS in PEM format")
 	f_namespace = flag.String("namespace", "", "limit miniweb to a namespace")
+	f_views     = flag.String("views", "", "path to store saved views (default <base>/miniweb.views)")
+	f_readonly  = flag.Bool("readonly", false, "rate-limited read-only mode: status pages and screenshots only")
 )
 
 func usage() {
@@ -70,6 +72,16 @@ func main() {
 		}
 	}
 
+	path := *f_views
+	if path == "" {
+		path = filepath.Join(*f_base, "miniweb.views")
+	}
+
+	views, err = newViewStore(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	files, err := ioutil.ReadDir(*f_root)
 	if err != nil {
 		log.Fatalln(err)
@@ -102,6 +114,13 @@ func main() {
 	mux.HandleFunc("/files/", mustAuth(filesHandler))
 	mux.HandleFunc("/files.json", mustAuth(tabularHandler))
 
+	mux.HandleFunc("/bundle.tar.gz", mustAuth(bundleHandler))
+
+	mux.HandleFunc("/views.json", mustAuth(viewsHandler))
+
+	mux.HandleFunc("/pcap", mustAuth(templateHandler))
+	mux.HandleFunc("/pcap.json", mustAuth(pcapHandler))
+
 	mux.HandleFunc("/minibuilder/", mustAuth(minibuilderHandler))
 
 	mux.HandleFunc("/vm/", mustAuth(vmHandler))
@@ -131,9 +150,15 @@ func main() {
 		mux.HandleFunc("/commands", disabled)
 	}
 
+	var handler http.Handler = mux
+	if *f_readonly {
+		log.Info("running in read-only mode")
+		handler = readOnlyHandler(mux)
+	}
+
 	server := &http.Server{
 		Addr:    *f_addr,
-		Handler: mux,
+		Handler: handler,
 	}
 
 	if *f_cert != "" && *f_key != "" {
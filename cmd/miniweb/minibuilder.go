@@ -0,0 +1,244 @@
+// Copyright 2017-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// mxCell is the subset of the grapheditor's cell attributes that we need to
+// recover a topology from a drawing. Cells drawn from the switch stencils
+// become networks, all other vertices become VMs, and edges connect VMs to
+// networks.
+type mxCell struct {
+	ID     string
+	Value  string
+	Style  string
+	Vertex bool
+	Edge   bool
+	Source string
+	Target string
+}
+
+// parseGraph extracts the cells from the XML that the grapheditor posts.
+// Cells may be wrapped in <object> elements when the user attaches metadata
+// so we take the label and id from the wrapper when the cell lacks them.
+func parseGraph(data string) ([]mxCell, error) {
+	dec := xml.NewDecoder(strings.NewReader(data))
+
+	var cells []mxCell
+
+	// from the enclosing <object>, if any
+	var label, id string
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		attr := func(name string) string {
+			for _, a := range el.Attr {
+				if a.Name.Local == name {
+					return a.Value
+				}
+			}
+			return ""
+		}
+
+		switch el.Name.Local {
+		case "object", "UserObject":
+			label, id = attr("label"), attr("id")
+		case "mxCell":
+			c := mxCell{
+				ID:     attr("id"),
+				Value:  attr("value"),
+				Style:  attr("style"),
+				Vertex: attr("vertex") == "1",
+				Edge:   attr("edge") == "1",
+				Source: attr("source"),
+				Target: attr("target"),
+			}
+
+			if c.ID == "" {
+				c.ID = id
+			}
+			if c.Value == "" {
+				c.Value = label
+			}
+
+			cells = append(cells, c)
+
+			label, id = "", ""
+		}
+	}
+
+	return cells, nil
+}
+
+// isNetwork tests whether a vertex represents a network rather than a VM.
+func isNetwork(c mxCell) bool {
+	style := strings.ToLower(c.Style)
+	value := strings.ToLower(c.Value)
+
+	return strings.Contains(style, "switch") || strings.HasPrefix(value, "vlan")
+}
+
+// graphToScript translates a drawing into a minimega script that recreates
+// the topology, one "vm config networks"/"vm launch" pair per VM vertex.
+// Network names become VLAN aliases so the script works in any namespace.
+func graphToScript(data string) (string, error) {
+	cells, err := parseGraph(data)
+	if err != nil {
+		return "", err
+	}
+
+	type vmCell struct {
+		name, kind string
+		networks   []string
+	}
+
+	networks := map[string]string{} // cell ID -> VLAN alias
+	vms := map[string]*vmCell{}     // cell ID -> VM
+	var order []string              // VM cell IDs in drawing order
+
+	for _, c := range cells {
+		if !c.Vertex {
+			continue
+		}
+
+		name := strings.TrimSpace(c.Value)
+
+		if isNetwork(c) {
+			if name == "" {
+				name = "vlan-" + c.ID
+			}
+			networks[c.ID] = name
+			continue
+		}
+
+		if name == "" {
+			name = "vm-" + c.ID
+		}
+
+		kind := "kvm"
+		if strings.Contains(strings.ToLower(c.Style), "container") {
+			kind = "container"
+		}
+
+		vms[c.ID] = &vmCell{name: name, kind: kind}
+		order = append(order, c.ID)
+	}
+
+	if len(vms) == 0 {
+		return "", fmt.Errorf("no VMs in drawing")
+	}
+
+	// attach VMs to networks, in either edge direction
+	for _, c := range cells {
+		if !c.Edge {
+			continue
+		}
+
+		if vm, ok := vms[c.Source]; ok && networks[c.Target] != "" {
+			vm.networks = append(vm.networks, networks[c.Target])
+		} else if vm, ok := vms[c.Target]; ok && networks[c.Source] != "" {
+			vm.networks = append(vm.networks, networks[c.Source])
+		}
+	}
+
+	var b bytes.Buffer
+
+	for _, id := range order {
+		vm := vms[id]
+
+		if len(vm.networks) > 0 {
+			fmt.Fprintf(&b, "vm config networks %v\n", strings.Join(vm.networks, " "))
+		} else {
+			fmt.Fprintf(&b, "clear vm config networks\n")
+		}
+		fmt.Fprintf(&b, "vm launch %v %v\n", vm.kind, vm.name)
+	}
+
+	fmt.Fprintf(&b, "vm start all\n")
+
+	return b.String(), nil
+}
+
+// namespaceGraph builds a drawing of the VMs in the namespace and the VLANs
+// connecting them so that an existing experiment can be opened in the
+// designer. vms is the output of "vm info" with name and vlan columns.
+func namespaceGraph(vms []map[string]string) string {
+	var b bytes.Buffer
+
+	b.WriteString(`<mxGraphModel><root><mxCell id="0"/><mxCell id="1" parent="0"/>`)
+
+	id := 2
+
+	cell := func(value, style string, x, y int) int {
+		fmt.Fprintf(&b, `<mxCell id="%v" value="%v" style="%v" vertex="1" parent="1">`, id, value, style)
+		fmt.Fprintf(&b, `<mxGeometry x="%v" y="%v" width="80" height="40" as="geometry"/></mxCell>`, x, y)
+		id++
+		return id - 1
+	}
+
+	// collect the VLANs first so that they draw in a stable order
+	vlanIDs := map[string]int{}
+	var vlans []string
+
+	for _, vm := range vms {
+		for _, v := range splitVLANs(vm["vlan"]) {
+			if _, ok := vlanIDs[v]; !ok {
+				vlanIDs[v] = 0
+				vlans = append(vlans, v)
+			}
+		}
+	}
+	sort.Strings(vlans)
+
+	for i, v := range vlans {
+		vlanIDs[v] = cell(v, "switch;shape=ellipse;fillColor=#dae8fc;", 40+120*i, 40)
+	}
+
+	for i, vm := range vms {
+		vmID := cell(vm["name"], "rounded=0;whiteSpace=wrap;html=1;", 40+120*i, 200)
+
+		for _, v := range splitVLANs(vm["vlan"]) {
+			fmt.Fprintf(&b, `<mxCell id="%v" edge="1" source="%v" target="%v" parent="1">`, id, vmID, vlanIDs[v])
+			b.WriteString(`<mxGeometry relative="1" as="geometry"/></mxCell>`)
+			id++
+		}
+	}
+
+	b.WriteString(`</root></mxGraphModel>`)
+
+	return b.String()
+}
+
+// splitVLANs splits the "vlan" column from "vm info" (e.g. "[foo bar]") into
+// individual VLANs, dropping disconnected interfaces.
+func splitVLANs(s string) []string {
+	var res []string
+
+	for _, v := range strings.Fields(strings.Trim(s, "[]")) {
+		if v != "" && v != "disconnected" {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
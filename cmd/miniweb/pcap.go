@@ -0,0 +1,175 @@
+// Copyright 2017-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// limits for the summary lists so that huge captures still render quickly
+const (
+	pcapTopTalkers       = 10
+	pcapTopConversations = 20
+)
+
+type pcapCounter struct {
+	Key     string `json:"key"`
+	Packets int    `json:"packets"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// pcapSummary is what the pcap preview page renders: totals, a protocol
+// breakdown, top talkers by source address, and the busiest conversations.
+type pcapSummary struct {
+	Packets int    `json:"packets"`
+	Bytes   int64  `json:"bytes"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+
+	Protocols     map[string]int `json:"protocols"`
+	Talkers       []pcapCounter  `json:"talkers"`
+	Conversations []pcapCounter  `json:"conversations"`
+}
+
+// pcapHandler handles the following URLs:
+//
+//	/pcap.json?path=<path in files directory>
+//
+// It fetches the capture through the files API and returns a summary so that
+// analysts can triage captures without downloading them.
+func pcapHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("pcap request: %v", r.URL)
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "must specify path", http.StatusBadRequest)
+		return
+	}
+
+	data := streamFile(r, path)
+	if len(data) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	summary, err := summarizePcap(bytes.NewReader(data))
+	if err != nil {
+		log.Error("unable to parse %v: %v", path, err)
+		http.Error(w, "unable to parse pcap", http.StatusBadRequest)
+		return
+	}
+
+	respondJSON(w, summary)
+}
+
+func summarizePcap(r io.Reader) (*pcapSummary, error) {
+	pr, err := pcapgo.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pcapSummary{
+		Protocols: map[string]int{},
+	}
+
+	var start, end time.Time
+
+	talkers := map[string]*pcapCounter{}
+	conversations := map[string]*pcapCounter{}
+
+	count := func(m map[string]*pcapCounter, key string, size int) {
+		if m[key] == nil {
+			m[key] = &pcapCounter{Key: key}
+		}
+		m[key].Packets++
+		m[key].Bytes += int64(size)
+	}
+
+	for {
+		data, ci, err := pr.ReadPacketData()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		res.Packets++
+		res.Bytes += int64(ci.Length)
+
+		if start.IsZero() || ci.Timestamp.Before(start) {
+			start = ci.Timestamp
+		}
+		if ci.Timestamp.After(end) {
+			end = ci.Timestamp
+		}
+
+		pkt := gopacket.NewPacket(data, pr.LinkType(), gopacket.Lazy)
+
+		// innermost of transport then network then link protocol
+		proto := pr.LinkType().String()
+		var src, dst string
+
+		if layer := pkt.NetworkLayer(); layer != nil {
+			proto = layer.LayerType().String()
+			src = layer.NetworkFlow().Src().String()
+			dst = layer.NetworkFlow().Dst().String()
+		}
+
+		if layer := pkt.TransportLayer(); layer != nil {
+			proto = layer.LayerType().String()
+			dst = fmt.Sprintf("%v:%v", dst, layer.TransportFlow().Dst())
+		} else if layer := pkt.Layer(layers.LayerTypeICMPv4); layer != nil {
+			proto = "ICMPv4"
+		} else if layer := pkt.Layer(layers.LayerTypeARP); layer != nil {
+			proto = "ARP"
+		}
+
+		res.Protocols[proto]++
+
+		if src != "" {
+			count(talkers, src, ci.Length)
+			count(conversations, fmt.Sprintf("%v -> %v (%v)", src, dst, proto), ci.Length)
+		}
+	}
+
+	if !start.IsZero() {
+		res.Start = start.Format(time.RFC3339)
+		res.End = end.Format(time.RFC3339)
+	}
+
+	res.Talkers = topCounters(talkers, pcapTopTalkers)
+	res.Conversations = topCounters(conversations, pcapTopConversations)
+
+	return res, nil
+}
+
+// topCounters returns the n counters with the most bytes, busiest first.
+func topCounters(m map[string]*pcapCounter, n int) []pcapCounter {
+	res := []pcapCounter{}
+	for _, v := range m {
+		res = append(res, *v)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Bytes > res[j].Bytes
+	})
+
+	if len(res) > n {
+		res = res[:n]
+	}
+
+	return res
+}
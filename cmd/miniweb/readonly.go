@@ -0,0 +1,135 @@
+// Copyright 2017-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// rate limit for read-only mode, per client address
+const (
+	readOnlyRate  = 10 // requests per second
+	readOnlyBurst = 50
+)
+
+// status pages and feeds that are safe to project on a public display. Paths
+// are matched by suffix so that namespace-prefixed URLs work too.
+var readOnlyPaths = []string{
+	"/vms",
+	"/hosts",
+	"/vlans",
+	"/namespaces",
+	"/tilevnc",
+	"/montage",
+	"/vms/info.json",
+	"/vms/top.json",
+	"/hosts.json",
+	"/vlans.json",
+	"/namespaces.json",
+}
+
+// rateLimiter is a simple per-address token bucket.
+type rateLimiter struct {
+	mu sync.Mutex
+
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (l *rateLimiter) allow(addr string) bool {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	b := l.buckets[addr]
+	if b == nil {
+		b = &bucket{tokens: readOnlyBurst, last: now}
+		l.buckets[addr] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * readOnlyRate
+	if b.tokens > readOnlyBurst {
+		b.tokens = readOnlyBurst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// readOnlyAllowed reports whether the request may be served in read-only
+// mode: GETs of the status pages, screenshots, and static assets only.
+func readOnlyAllowed(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+
+	path := r.URL.Path
+
+	if path == "/" {
+		return true
+	}
+
+	// static assets
+	for _, prefix := range []string{"/css/", "/fonts/", "/images/", "/js/", "/libs/"} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	// VM screenshots
+	if strings.HasSuffix(path, "/screenshot.png") {
+		return true
+	}
+
+	for _, p := range readOnlyPaths {
+		if path == p || strings.HasSuffix(path, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readOnlyHandler wraps the mux for public displays: it rate limits clients
+// and rejects everything except the read-only status view, regardless of any
+// authentication.
+func readOnlyHandler(h http.Handler) http.Handler {
+	limiter := &rateLimiter{buckets: make(map[string]*bucket)}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !limiter.allow(r.RemoteAddr) {
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		if !readOnlyAllowed(r) {
+			log.Info("rejecting %v %v in read-only mode", r.Method, r.URL.Path)
+			http.Error(w, "disabled, see -readonly flag", http.StatusForbidden)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
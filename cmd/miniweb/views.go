@@ -0,0 +1,148 @@
+// Copyright 2017-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// viewStore holds named views of the VM table -- filters, column sets, and
+// sort order as a DataTables state blob -- keyed by namespace and then by
+// view name. Views are stored server-side so that everyone pointed at the
+// same miniweb shares them.
+type viewStore struct {
+	mu sync.Mutex
+
+	// path that the views are persisted to
+	path string
+
+	// namespace -> view name -> DataTables state
+	views map[string]map[string]json.RawMessage
+}
+
+var views *viewStore
+
+func newViewStore(path string) (*viewStore, error) {
+	v := &viewStore{
+		path:  path,
+		views: make(map[string]map[string]json.RawMessage),
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return v, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &v.views); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// save persists the views. Must be called with the lock held.
+func (v *viewStore) save() error {
+	b, err := json.Marshal(v.views)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(v.path, b, 0644)
+}
+
+func (v *viewStore) List(namespace string) map[string]json.RawMessage {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	res := map[string]json.RawMessage{}
+	for name, state := range v.views[namespace] {
+		res[name] = state
+	}
+
+	return res
+}
+
+func (v *viewStore) Set(namespace, name string, state json.RawMessage) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.views[namespace] == nil {
+		v.views[namespace] = make(map[string]json.RawMessage)
+	}
+	v.views[namespace][name] = state
+
+	return v.save()
+}
+
+func (v *viewStore) Delete(namespace, name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	delete(v.views[namespace], name)
+
+	return v.save()
+}
+
+// viewsHandler handles the following URLs:
+//
+//	/views.json
+//
+// GET lists the namespace's views, POST saves a view from a JSON body with
+// name and state fields, and DELETE removes the view named by the name query
+// parameter.
+func viewsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("views request: %v", r.URL)
+
+	namespace := NewCommand(r).Namespace
+
+	switch r.Method {
+	case http.MethodGet:
+		respondJSON(w, views.List(namespace))
+	case http.MethodPost:
+		var view struct {
+			Name  string          `json:"name"`
+			State json.RawMessage `json:"state"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&view); err != nil {
+			log.Error("unable to parse body: %v", err)
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+
+		if view.Name == "" || len(view.State) == 0 {
+			http.Error(w, "must specify name and state", http.StatusBadRequest)
+			return
+		}
+
+		if err := views.Set(namespace, view.Name, view.State); err != nil {
+			log.Error("unable to save views: %v", err)
+			http.Error(w, "unable to save", http.StatusInternalServerError)
+			return
+		}
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			http.Error(w, "must specify name", http.StatusBadRequest)
+			return
+		}
+
+		if err := views.Delete(namespace, name); err != nil {
+			log.Error("unable to save views: %v", err)
+			http.Error(w, "unable to save", http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+	}
+}
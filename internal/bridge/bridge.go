@@ -26,6 +26,11 @@ type Bridge struct {
 	Name     string
 	preExist bool
 
+	// dpdk is set when the bridge uses the userspace (DPDK) datapath, see
+	// SetDPDK. VM interfaces on a DPDK bridge are vhost-user ports rather
+	// than kernel taps.
+	dpdk bool
+
 	// mirrors records the mirror tap names used by captures
 	mirrors map[string]bool
 
@@ -38,7 +43,14 @@ type Bridge struct {
 	// we want to stop a capture.
 	captures map[int]capture
 
-	trunks  map[string]bool
+	// replays records the "stop" flags that are set to non-zero values when
+	// we want to stop a replay.
+	replays map[int]replay
+
+	// trunks maps trunk port names to the VLANs they are limited to, nil
+	// when the port trunks all VLANs
+	trunks map[string][]int
+
 	tunnels map[string]bool
 
 	taps map[string]*Tap
@@ -82,6 +94,7 @@ type Tap struct {
 	MAC       string // MAC address
 	Host      bool   // Set when created as a host tap (and, thus, promiscuous)
 	Container bool   // Set when created via CreateContainerTap
+	Vhost     bool   // Set when created as a vhost-user port (no kernel interface)
 	Bond      string // Bond that the tap belongs to
 	Defunct   bool   // Set when Tap should be reaped
 
@@ -99,6 +112,11 @@ type capture struct {
 	// isstopped is set to non-zero when stopped
 	isstopped *uint64
 
+	// written and dropped count packets written to the PCAP and dropped by
+	// the kernel, accessed atomically
+	written *uint64
+	dropped *uint64
+
 	// ack is closed when the goroutine doing the capture closes
 	ack chan bool
 
@@ -152,6 +170,9 @@ func (b *Bridge) destroy() error {
 	for v := range b.captures {
 		b.stopCapture(v)
 	}
+	for v := range b.replays {
+		b.stopReplay(v)
+	}
 
 	if b.nf != nil {
 		if err := b.destroyNetflow(); err != nil {
@@ -99,11 +99,12 @@ func (b Bridges) newBridge(name string) error {
 	br := &Bridge{
 		Name:     name,
 		taps:     make(map[string]*Tap),
-		trunks:   make(map[string]bool),
+		trunks:   make(map[string][]int),
 		tunnels:  make(map[string]bool),
 		mirrors:  make(map[string]bool),
 		bonds:    make(map[string]map[string]int),
 		captures: make(map[int]capture),
+		replays:  make(map[int]replay),
 		tapChan:  b.tapChan,
 		bondChan: b.bondChan,
 		config:   make(map[string]string),
@@ -215,6 +216,24 @@ func (b Bridges) HostTaps() []Tap {
 	return res
 }
 
+// TapCount returns the number of non-defunct taps across all managed bridges.
+func (b Bridges) TapCount() int {
+	bridgeLock.Lock()
+	defer bridgeLock.Unlock()
+
+	var res int
+
+	for _, br := range b.bridges {
+		for _, tap := range br.taps {
+			if !tap.Defunct {
+				res += 1
+			}
+		}
+	}
+
+	return res
+}
+
 // Info collects `BridgeInfo` for all managed bridges.
 func (b Bridges) Info() []BridgeInfo {
 	bridgeLock.Lock()
@@ -231,7 +250,11 @@ func (b Bridges) Info() []BridgeInfo {
 		}
 
 		// Populate trunks
-		for k := range br.trunks {
+		for k, vlans := range br.trunks {
+			if len(vlans) > 0 {
+				k = fmt.Sprintf("%v %v", k, vlans)
+			}
+
 			info.Trunks = append(info.Trunks, k)
 		}
 		sort.Strings(info.Trunks)
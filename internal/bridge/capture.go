@@ -12,6 +12,7 @@ import (
 
 	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
 
+	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 	"github.com/google/gopacket/pcapgo"
@@ -29,6 +30,116 @@ type CaptureConfig struct {
 	// networks. If you only need headers, you can set it much lower (i.e.
 	// 200). When zero, we use DefaultSnapLen.
 	SnapLen uint32
+
+	// RotateSize rotates the output file once it exceeds this many bytes.
+	// Rotated files keep the capture's filename with a numeric suffix. When
+	// zero, size-based rotation is disabled.
+	RotateSize int64
+
+	// RotateAge rotates the output file once it has been open this long.
+	// When zero, age-based rotation is disabled.
+	RotateAge time.Duration
+
+	// RingFiles caps how many rotated files are kept, deleting the oldest,
+	// so that long captures can't fill the disk. When zero, all rotated
+	// files are kept.
+	RingFiles int
+}
+
+// pcapWriter writes packets to fname in PCAP format, rotating the file by
+// size and age and pruning the oldest rotations in ring mode.
+type pcapWriter struct {
+	fname  string
+	config CaptureConfig
+
+	f    *os.File
+	w    *pcapgo.Writer
+	size int64
+	born time.Time
+	seq  int
+}
+
+func newPcapWriter(fname string, config CaptureConfig) (*pcapWriter, error) {
+	p := &pcapWriter{
+		fname:  fname,
+		config: config,
+	}
+
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *pcapWriter) open() error {
+	f, err := os.Create(p.fname)
+	if err != nil {
+		return err
+	}
+
+	w := pcapgo.NewWriter(f)
+
+	// write the file header
+	if err := w.WriteFileHeader(p.config.SnapLen, layers.LinkTypeEthernet); err != nil {
+		f.Close()
+		return err
+	}
+
+	p.f = f
+	p.w = w
+	p.size = 24 // global PCAP header
+	p.born = time.Now()
+
+	return nil
+}
+
+func (p *pcapWriter) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
+	if err := p.rotate(); err != nil {
+		return err
+	}
+
+	if err := p.w.WritePacket(ci, data); err != nil {
+		return err
+	}
+
+	// per-packet PCAP header is 16 bytes
+	p.size += 16 + int64(len(data))
+
+	return nil
+}
+
+// rotate closes and renames the file if it exceeds the configured size or
+// age, deleting the rotation that fell out of the ring, if any.
+func (p *pcapWriter) rotate() error {
+	c := p.config
+
+	if c.RotateSize == 0 || p.size < c.RotateSize {
+		if c.RotateAge == 0 || time.Since(p.born) < c.RotateAge {
+			return nil
+		}
+	}
+
+	if err := p.f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(p.fname, fmt.Sprintf("%v.%v", p.fname, p.seq)); err != nil {
+		return err
+	}
+	p.seq += 1
+
+	if old := p.seq - 1 - c.RingFiles; c.RingFiles > 0 && old >= 0 {
+		if err := os.Remove(fmt.Sprintf("%v.%v", p.fname, old)); err != nil {
+			log.Error("pruning rotated capture: %v", err)
+		}
+	}
+
+	return p.open()
+}
+
+func (p *pcapWriter) Close() error {
+	return p.f.Close()
 }
 
 // stopCapture stops a capture by ID which is assumed to exist
@@ -65,15 +176,12 @@ func (b *Bridge) captureTap(tap, fname string, config ...CaptureConfig) (int, er
 
 	// if there are configs, only process the first one
 	if len(config) > 0 {
-		c2 := config[0]
-		log.Debug("using config: %v", c2)
+		c = config[0]
+		log.Debug("using config: %v", c)
 
-		if c2.SnapLen == 0 {
-			c2.SnapLen = DefaultSnapLen
+		if c.SnapLen == 0 {
+			c.SnapLen = DefaultSnapLen
 		}
-
-		c.Filter = c2.Filter
-		c.SnapLen = c2.SnapLen
 	}
 
 	handle, err := pcap.OpenLive(tap, int32(c.SnapLen), true, time.Second)
@@ -88,38 +196,40 @@ func (b *Bridge) captureTap(tap, fname string, config ...CaptureConfig) (int, er
 		}
 	}
 
-	f, err := os.Create(fname)
+	w, err := newPcapWriter(fname, c)
 	if err != nil {
 		handle.Close()
 		return 0, err
 	}
 
-	w := pcapgo.NewWriter(f)
-
-	// write the file header
-	if err := w.WriteFileHeader(c.SnapLen, layers.LinkTypeEthernet); err != nil {
-		handle.Close()
-		f.Close()
-		return 0, err
-	}
-
 	id := len(b.captures)
 	stopped := uint64(0)
+	written := uint64(0)
+	dropped := uint64(0)
 	ack := make(chan bool)
 
 	b.captures[id] = capture{
 		tap:       tap,
 		isstopped: &stopped,
+		written:   &written,
+		dropped:   &dropped,
 		ack:       ack,
 		handle:    handle,
 	}
 
+	// refresh the kernel drop counter, only called from the capture goroutine
+	updateDropped := func() {
+		if s, err := handle.Stats(); err == nil {
+			atomic.StoreUint64(&dropped, uint64(s.PacketsDropped))
+		}
+	}
+
 	// start a goroutine to do the capture, runs until it encounters an error
-	// and then closes the f and cleans up.
+	// and then closes the writer and cleans up.
 	go func() {
 		defer close(ack)
 		defer handle.Close()
-		defer f.Close()
+		defer w.Close()
 
 		var err error
 
@@ -127,14 +237,23 @@ func (b *Bridge) captureTap(tap, fname string, config ...CaptureConfig) (int, er
 			data, ci, err2 := handle.ReadPacketData()
 
 			if err2 == pcap.NextErrorTimeoutExpired {
+				// idle -- still advance age-based rotation and drop stats
+				err = w.rotate()
+				updateDropped()
 				continue
 			} else if err2 == nil {
 				err = w.WritePacket(ci, data)
+
+				if atomic.AddUint64(&written, 1)%1024 == 0 {
+					updateDropped()
+				}
 			} else {
 				err = err2
 			}
 		}
 
+		updateDropped()
+
 		// only report error if the capture isn't stopped
 		if err != nil && atomic.LoadUint64(&stopped) == 0 {
 			log.Error("packet copier for %v: %v", tap, err)
@@ -202,6 +321,20 @@ func (b *Bridge) CaptureTap(tap, fname string, config ...CaptureConfig) (int, er
 	return b.captureTap(tap, fname, config...)
 }
 
+// CaptureStats returns how many packets the capture has written to its PCAP
+// and how many the kernel dropped because the writer couldn't keep up.
+func (b *Bridge) CaptureStats(id int) (written, dropped uint64, err error) {
+	bridgeLock.Lock()
+	defer bridgeLock.Unlock()
+
+	c, ok := b.captures[id]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown capture ID: %v", id)
+	}
+
+	return atomic.LoadUint64(c.written), atomic.LoadUint64(c.dropped), nil
+}
+
 func (b *Bridge) StopCapture(id int) error {
 	bridgeLock.Lock()
 	defer bridgeLock.Unlock()
@@ -0,0 +1,122 @@
+// Copyright 2016-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package bridge
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// SetDPDK switches the bridge to the userspace (DPDK) datapath. VM interfaces
+// added to the bridge after this are vhost-user ports rather than kernel
+// taps, see CreateVhostUserPort. OVS must have been built with DPDK support
+// and initialized with other_config:dpdk-init=true for the datapath to
+// actually forward packets.
+func (b *Bridge) SetDPDK() error {
+	bridgeLock.Lock()
+	defer bridgeLock.Unlock()
+
+	log.Info("enabling DPDK datapath on bridge: %v", b.Name)
+
+	if len(b.taps) > 0 {
+		return errors.New("cannot change datapath, bridge has interfaces")
+	}
+
+	args := []string{
+		"set",
+		"bridge",
+		b.Name,
+		"datapath_type=netdev",
+	}
+
+	if _, err := ovsCmdWrapper(args); err != nil {
+		return fmt.Errorf("set datapath failed: %v", err)
+	}
+
+	b.dpdk = true
+
+	// record in config so that it shows up in the bridge listing
+	b.config["datapath_type"] = "netdev"
+
+	return nil
+}
+
+// DPDK returns true if the bridge uses the userspace (DPDK) datapath.
+func (b *Bridge) DPDK() bool {
+	bridgeLock.Lock()
+	defer bridgeLock.Unlock()
+
+	return b.dpdk
+}
+
+// CreateVhostUserPort adds a vhost-user client port to the bridge. socket is
+// the path to the vhost-user socket that the VM serves -- OVS connects to it
+// and reconnects if the VM restarts. If a name is not provided, one will be
+// automatically generated. There is no kernel interface behind the port,
+// packets move between the vswitch and the VM over shared memory.
+func (b *Bridge) CreateVhostUserPort(port, socket string, vlan int) (string, error) {
+	bridgeLock.Lock()
+	defer bridgeLock.Unlock()
+
+	log.Info("creating vhost-user port on bridge %v: %v %v", b.Name, socket, vlan)
+
+	// reap taps before creating to avoid someone killing/restarting a vm
+	// faster than the periodic tap reaper
+	b.reapTaps()
+
+	if port == "" {
+		port = <-b.tapChan
+	}
+
+	if _, ok := b.taps[port]; ok {
+		return "", fmt.Errorf("tap already on bridge")
+	}
+
+	args := []string{
+		"add-port",
+		b.Name,
+		port,
+	}
+
+	if vlan != 0 {
+		args = append(args, fmt.Sprintf("tag=%v", vlan))
+	}
+
+	args = append(args,
+		"--",
+		"set",
+		"Interface",
+		port,
+		"type=dpdkvhostuserclient",
+		fmt.Sprintf("options:vhost-server-path=%v", socket),
+	)
+
+	if _, err := ovsCmdWrapper(args); err == errAlreadyExists {
+		// Special case -- port left over from a previous run... try to remove
+		// it first and then add it again.
+		log.Info("port %v is already on bridge, adding again", port)
+
+		if err := ovsDelPort(b.Name, port); err != nil {
+			return "", err
+		}
+
+		if _, err := ovsCmdWrapper(args); err != nil {
+			return "", fmt.Errorf("add vhost-user port failed: %v", err)
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("add vhost-user port failed: %v", err)
+	}
+
+	b.taps[port] = &Tap{
+		Name:   port,
+		Bridge: b.Name,
+		VLAN:   vlan,
+		Vhost:  true,
+	}
+
+	return port, nil
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
@@ -130,6 +131,25 @@ func ovsSetPortQinQ(tap string, outer int) error {
 	return nil
 }
 
+// ovsSetPortTrunks limits the VLANs that an OVS trunk port carries.
+func ovsSetPortTrunks(tap string, vlans []int) error {
+	trunks := []string{}
+	for _, v := range vlans {
+		trunks = append(trunks, strconv.Itoa(v))
+	}
+
+	args := []string{
+		"set", "port", tap,
+		fmt.Sprintf("trunks=%v", strings.Join(trunks, ",")),
+	}
+
+	if _, err := ovsCmdWrapper(args); err != nil {
+		return fmt.Errorf("configuring trunks on port %v: %v", tap, err)
+	}
+
+	return nil
+}
+
 // ovsAddMirror creates a mirror on bridge from src to dst. If src is empty,
 // mirrors the entire bridge.
 func ovsAddMirror(bridge, src, dst string) error {
@@ -217,7 +237,9 @@ func ovsDelMirror(bridge, tap string) error {
 // ovsCmdWrapper wraps `ovs-vsctl` commands, returning stdout, stderr, and any
 // error produced running the command.
 func ovsCmdWrapper(args []string) (string, error) {
-	cmd := exec.Command("ovs-vsctl", args...)
+	full := wrapHelper(append([]string{"ovs-vsctl"}, args...))
+
+	cmd := exec.Command(full[0], full[1:]...)
 	log.Debug("running ovs cmd: %v", cmd)
 
 	out, err := cmd.CombinedOutput()
@@ -20,6 +20,27 @@ var ExternalDependencies = []string{
 	"tc",
 }
 
+// helper, if set, is prepended to every external command that the bridge
+// package runs. See SetHelper.
+var helper []string
+
+// SetHelper sets a command prefix, such as "sudo -n" or a setuid helper
+// binary, that is prepended to every external command the bridge package
+// runs. This allows tap and bridge operations to be delegated when minimega
+// itself is not running as root.
+func SetHelper(args []string) {
+	helper = args
+}
+
+// wrapHelper prepends the helper prefix, if any, to the given arg list.
+func wrapHelper(args []string) []string {
+	if len(helper) == 0 {
+		return args
+	}
+
+	return append(append([]string{}, helper...), args...)
+}
+
 // processWrapper executes the given arg list and returns a combined
 // stdout/stderr and any errors. processWrapper blocks until the process exits.
 func processWrapper(args ...string) (string, error) {
@@ -27,6 +48,8 @@ func processWrapper(args ...string) (string, error) {
 		return "", fmt.Errorf("empty argument list")
 	}
 
+	args = wrapHelper(args)
+
 	start := time.Now()
 	out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
 	stop := time.Now()
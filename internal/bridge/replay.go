@@ -0,0 +1,194 @@
+// Copyright 2016-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package bridge
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+
+	"github.com/google/gopacket/pcap"
+)
+
+type ReplayConfig struct {
+	// Rate is a multiplier applied to the recorded inter-packet timing. 1.0
+	// replays the pcap in real time, 2.0 at double speed, and so on. When
+	// zero, packets are injected as fast as possible.
+	Rate float64
+
+	// Loop controls whether the replay restarts from the beginning of the
+	// pcap when it reaches the end.
+	Loop bool
+}
+
+type replay struct {
+	tap string
+
+	// isstopped is set to non-zero when stopped
+	isstopped *uint64
+
+	// ack is closed when the goroutine doing the replay closes
+	ack chan bool
+
+	// injection handle, needed so that we can close it in stopReplay
+	handle *pcap.Handle
+}
+
+// Replay creates a host tap on the specified VLAN and starts injecting the
+// packets from fname onto it. Returns an ID which can be passed to StopReplay
+// and the name of the tap that was created.
+func (b *Bridge) Replay(fname string, lan int, c ReplayConfig) (int, string, error) {
+	bridgeLock.Lock()
+	defer bridgeLock.Unlock()
+
+	tap := <-b.tapChan
+	if err := b.createHostTap(tap, lan); err != nil {
+		return 0, "", err
+	}
+
+	id, err := b.replayTap(tap, fname, c)
+	if err != nil {
+		// Clean up the tap we just created
+		if err := b.destroyTap(tap); err != nil {
+			log.Error("zombie tap -- %v %v", tap, err)
+		}
+
+		return 0, "", err
+	}
+
+	return id, tap, nil
+}
+
+// replayTap starts the goroutine that copies packets from fname to tap,
+// assumes that bridgeLock is held.
+func (b *Bridge) replayTap(tap, fname string, c ReplayConfig) (int, error) {
+	log.Info("replay %v to %v", fname, tap)
+
+	handle, err := pcap.OpenLive(tap, DefaultSnapLen, false, time.Second)
+	if err != nil {
+		return 0, err
+	}
+
+	src, err := pcap.OpenOffline(fname)
+	if err != nil {
+		handle.Close()
+		return 0, err
+	}
+
+	id := len(b.replays)
+	stopped := uint64(0)
+	ack := make(chan bool)
+
+	b.replays[id] = replay{
+		tap:       tap,
+		isstopped: &stopped,
+		ack:       ack,
+		handle:    handle,
+	}
+
+	// start a goroutine to do the replay, runs until it encounters an error,
+	// it runs out of packets (and looping is disabled), or it is stopped.
+	go func() {
+		defer close(ack)
+		defer handle.Close()
+
+		var err error
+
+		// timestamp of the previous packet so that we can recreate the
+		// recorded inter-packet gaps
+		var prev time.Time
+
+		for err == nil && atomic.LoadUint64(&stopped) == 0 {
+			data, ci, err2 := src.ReadPacketData()
+
+			if err2 == pcap.NextErrorNoMorePackets {
+				if !c.Loop {
+					break
+				}
+
+				// rewind by reopening the pcap
+				src.Close()
+				if src, err2 = pcap.OpenOffline(fname); err2 != nil {
+					err = err2
+					break
+				}
+
+				prev = time.Time{}
+				continue
+			} else if err2 != nil {
+				err = err2
+				break
+			}
+
+			if !prev.IsZero() && c.Rate > 0 {
+				gap := time.Duration(float64(ci.Timestamp.Sub(prev)) / c.Rate)
+
+				// sleep in small increments so that stopping a replay does
+				// not block for an arbitrarily long recorded gap
+				for gap > 0 && atomic.LoadUint64(&stopped) == 0 {
+					d := gap
+					if d > time.Second {
+						d = time.Second
+					}
+
+					time.Sleep(d)
+					gap -= d
+				}
+			}
+			prev = ci.Timestamp
+
+			err = handle.WritePacketData(data)
+		}
+
+		src.Close()
+
+		// only report error if the replay isn't stopped
+		if err != nil && atomic.LoadUint64(&stopped) == 0 {
+			log.Error("packet injector for %v: %v", tap, err)
+		}
+
+		log.Info("replay finished: %v %v", tap, id)
+	}()
+
+	return id, nil
+}
+
+// stopReplay stops a replay by ID which is assumed to exist, assumes that
+// bridgeLock is held.
+func (b *Bridge) stopReplay(id int) {
+	tap := b.replays[id].tap
+
+	log.Info("stopping replay: %v %v", tap, id)
+
+	atomic.StoreUint64(b.replays[id].isstopped, 1)
+
+	// do this after setting isstopped to prevent error in packet injector
+	b.replays[id].handle.Close()
+
+	// wait until the replay is closed before destroying the tap
+	<-b.replays[id].ack
+	delete(b.replays, id)
+
+	if err := b.destroyTap(tap); err != nil {
+		log.Error("stop replay %v %v: %v", tap, id, err)
+	}
+
+	log.Info("stopped replay: %v %v", tap, id)
+}
+
+func (b *Bridge) StopReplay(id int) error {
+	bridgeLock.Lock()
+	defer bridgeLock.Unlock()
+
+	if _, ok := b.replays[id]; !ok {
+		return fmt.Errorf("unknown replay ID: %v", id)
+	}
+
+	b.stopReplay(id)
+
+	return nil
+}
@@ -197,7 +197,7 @@ func (b *Bridge) destroyTap(t string) error {
 
 	tap.Defunct = true
 
-	if tap.Host {
+	if tap.Host || tap.Vhost {
 		// Tap is managed by OVS -- calling del-port will delete it for us.
 		return nil
 	}
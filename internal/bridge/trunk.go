@@ -10,23 +10,54 @@ import (
 	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
 )
 
-// AddTrunk add an existing interface as a trunk port to the bridge.
-func (b *Bridge) AddTrunk(iface string) error {
+// AddTrunk adds an existing interface as a trunk port to the named bridge,
+// optionally limited to the given VLANs. An empty VLAN list trunks all VLANs.
+// The interface must not already be in use as a trunk or tap on any managed
+// bridge, so that experiments can't silently steal each other's uplinks.
+func (b *Bridges) AddTrunk(bridge, iface string, vlans []int) error {
 	bridgeLock.Lock()
 	defer bridgeLock.Unlock()
 
-	log.Info("adding trunk port on bridge %v: %v", b.Name, iface)
+	for _, br := range b.bridges {
+		if _, ok := br.trunks[iface]; ok {
+			return fmt.Errorf("%v is already a trunk on bridge %v", iface, br.Name)
+		}
 
-	if b.trunks[iface] {
-		return fmt.Errorf("bridge already trunking to %v", iface)
+		if _, ok := br.taps[iface]; ok {
+			return fmt.Errorf("%v is already a tap on bridge %v", iface, br.Name)
+		}
 	}
 
-	err := ovsAddPort(b.Name, iface, 0, false)
-	if err == nil {
-		b.trunks[iface] = true
+	br, ok := b.bridges[bridge]
+	if !ok {
+		return fmt.Errorf("bridge not found: %v", bridge)
 	}
 
-	return err
+	return br.addTrunk(iface, vlans)
+}
+
+// addTrunk adds a trunk port to the bridge, assumes that bridgeLock is held.
+func (b *Bridge) addTrunk(iface string, vlans []int) error {
+	log.Info("adding trunk port on bridge %v: %v %v", b.Name, iface, vlans)
+
+	if err := ovsAddPort(b.Name, iface, 0, false); err != nil {
+		return err
+	}
+
+	if len(vlans) > 0 {
+		if err := ovsSetPortTrunks(iface, vlans); err != nil {
+			// unwind
+			if err := ovsDelPort(b.Name, iface); err != nil {
+				log.Error("zombie trunk -- %v %v", iface, err)
+			}
+
+			return err
+		}
+	}
+
+	b.trunks[iface] = vlans
+
+	return nil
 }
 
 // RemoveTrunk removes a trunk port from the bridge.
@@ -40,7 +71,7 @@ func (b *Bridge) RemoveTrunk(iface string) error {
 func (b *Bridge) removeTrunk(iface string) error {
 	log.Info("removing trunk from bridge %v: %v", b.Name, iface)
 
-	if !b.trunks[iface] {
+	if _, ok := b.trunks[iface]; !ok {
 		return fmt.Errorf("unknown trunk: %v", iface)
 	}
 
@@ -29,8 +29,9 @@ func (t TunnelType) String() string {
 	return "invalid"
 }
 
-// AddTunnel adds a new vxlan or GRE tunnel to a bridge.
-func (b *Bridge) AddTunnel(typ TunnelType, remoteIP, key string) error {
+// AddTunnel adds a new vxlan or GRE tunnel to a bridge, returning the name
+// of the created tunnel port.
+func (b *Bridge) AddTunnel(typ TunnelType, remoteIP, key string) (string, error) {
 	bridgeLock.Lock()
 	defer bridgeLock.Unlock()
 
@@ -53,12 +54,12 @@ func (b *Bridge) AddTunnel(typ TunnelType, remoteIP, key string) error {
 		args = append(args, fmt.Sprintf("options:key=%v", key))
 	}
 	if _, err := ovsCmdWrapper(args); err != nil {
-		return fmt.Errorf("add tunnel failed: %v", err)
+		return "", fmt.Errorf("add tunnel failed: %v", err)
 	}
 
 	b.tunnels[tap] = true
 
-	return nil
+	return tap, nil
 }
 
 // RemoveTunnel removes a tunnel from the bridge.
@@ -39,6 +39,8 @@ func (n *Node) clientSend(host string, m *Message) error {
 		return err
 	}
 
+	m = n.compressBody(m)
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
@@ -105,6 +107,10 @@ func (n *Node) clientHandler(host string) {
 				}
 				break
 			}
+			if err := decompressBody(&m); err != nil {
+				log.Error("client %v decompress: %v", host, err)
+				break
+			}
 			n.messagePump <- &m
 		}
 	}
@@ -0,0 +1,115 @@
+// Copyright 2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package meshage
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/gob"
+	"io/ioutil"
+)
+
+// compressThreshold is the minimum encoded body size, in bytes, worth
+// compressing. Small bodies are sent as-is since the compression overhead
+// outweighs any savings.
+const compressThreshold = 4 << 10
+
+// compressedBody replaces a Message body that has been gob-encoded and
+// flate-compressed. Decompression is always supported so that a node with
+// compression disabled can still receive from one with it enabled.
+type compressedBody struct {
+	Data []byte
+}
+
+// bodyWrapper carries the wrapped body through gob so that its dynamic type
+// survives the inner encode.
+type bodyWrapper struct {
+	Body interface{}
+}
+
+// SetCompression enables or disables compression of message bodies sent by
+// this node. Only bodies larger than the compression threshold are
+// compressed. All peers must be running a version that understands
+// compressed messages.
+func (n *Node) SetCompression(enabled bool) {
+	n.compressLock.Lock()
+	defer n.compressLock.Unlock()
+
+	n.compress = enabled
+}
+
+// GetCompression returns whether compression is enabled on this node.
+func (n *Node) GetCompression() bool {
+	n.compressLock.Lock()
+	defer n.compressLock.Unlock()
+
+	return n.compress
+}
+
+// compressBody returns a copy of m with its body compressed, if compression
+// is enabled and the body is large enough to be worth it. Otherwise m is
+// returned unchanged.
+func (n *Node) compressBody(m *Message) *Message {
+	if !n.GetCompression() || m.Command != MESSAGE || m.Body == nil {
+		return m
+	}
+
+	// don't compress twice, see flood
+	if _, ok := m.Body.(compressedBody); ok {
+		return m
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(bodyWrapper{m.Body}); err != nil {
+		// fall back to sending the body as-is
+		return m
+	}
+
+	if buf.Len() < compressThreshold {
+		return m
+	}
+
+	var compressed bytes.Buffer
+
+	w, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		return m
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return m
+	}
+	if err := w.Close(); err != nil {
+		return m
+	}
+
+	// copy the message so that we don't mutate one shared with other sends
+	m2 := *m
+	m2.Body = compressedBody{compressed.Bytes()}
+	return &m2
+}
+
+// decompressBody replaces a compressed body in m, if any, with the original.
+func decompressBody(m *Message) error {
+	cb, ok := m.Body.(compressedBody)
+	if !ok {
+		return nil
+	}
+
+	r := flate.NewReader(bytes.NewReader(cb.Data))
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var w bodyWrapper
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&w); err != nil {
+		return err
+	}
+
+	m.Body = w.Body
+	return nil
+}
@@ -84,10 +84,13 @@ type Node struct {
 	updateNetwork    bool
 	Snoop            func(m *Message)
 	version          string
+	compress         bool // compress large message bodies, see SetCompression
+	compressLock     sync.Mutex
 }
 
 func init() {
 	gob.Register(mesh{})
+	gob.Register(compressedBody{})
 }
 
 // NewNode returns a new node, receiver channel, and error channel with a given name
@@ -458,6 +458,15 @@ func (q *Conn) USBDeviceAdd(id, bus, serial string) (string, error) {
 	return resp, err
 }
 
+func (q *Conn) USBHostDeviceAdd(id, bus, options string) (string, error) {
+	if !q.ready {
+		return "", ERR_READY
+	}
+	arg := fmt.Sprintf("device_add usb-host,id=%v,bus=%v,%v", id, bus, options)
+	resp, err := q.HumanMonitorCommand(arg)
+	return resp, err
+}
+
 func (q *Conn) NetDevAdd(devType, id, ifname string) (string, error) {
 	if !q.ready {
 		return "", ERR_READY
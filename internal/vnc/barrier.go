@@ -0,0 +1,76 @@
+// Copyright 2019-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package vnc
+
+import (
+	"sync"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// barriers tracks the named synchronization barriers shared by the playbacks
+// of a single Player. A playback that reaches a barrier blocks until the
+// expected number of playbacks have reached a barrier with the same name,
+// allowing recordings on separate VMs to proceed in lockstep.
+type barriers struct {
+	mu sync.Mutex // guards below
+
+	m map[string]*barrier
+}
+
+type barrier struct {
+	count   int       // number of playbacks expected at the barrier
+	arrived int       // number of playbacks that have arrived so far
+	c       chan bool // closed once all expected playbacks arrive
+}
+
+func newBarriers() *barriers {
+	return &barriers{
+		m: make(map[string]*barrier),
+	}
+}
+
+// arrive records that a playback has reached the named barrier and returns a
+// channel that is closed once count playbacks have arrived. The count is
+// taken from the first arrival -- mismatched counts across playback files are
+// logged since they almost certainly indicate a recording bug.
+func (b *barriers) arrive(name string, count int) chan bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w, ok := b.m[name]
+	if !ok {
+		w = &barrier{
+			count: count,
+			c:     make(chan bool),
+		}
+		b.m[name] = w
+	}
+
+	if count != w.count {
+		log.Warn("mismatched counts for vnc barrier %v: %v != %v", name, count, w.count)
+	}
+
+	w.arrived += 1
+	if w.arrived >= w.count {
+		close(w.c)
+
+		// barrier released -- forget it so that the name can be reused
+		delete(b.m, name)
+	}
+
+	return w.c
+}
+
+// reset forgets all barriers, releasing any playbacks waiting on them.
+func (b *barriers) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for name, w := range b.m {
+		close(w.c)
+		delete(b.m, name)
+	}
+}
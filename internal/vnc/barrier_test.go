@@ -0,0 +1,47 @@
+// Copyright 2019-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package vnc
+
+import "testing"
+
+func released(c chan bool) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestBarrier(t *testing.T) {
+	b := newBarriers()
+
+	c := b.arrive("start", 2)
+	if released(c) {
+		t.Error("barrier released before all playbacks arrived")
+	}
+
+	c2 := b.arrive("start", 2)
+	if !released(c) || !released(c2) {
+		t.Error("barrier not released after all playbacks arrived")
+	}
+
+	// name should be reusable after the barrier is released
+	c = b.arrive("start", 1)
+	if !released(c) {
+		t.Error("reused barrier not released")
+	}
+}
+
+func TestBarrierReset(t *testing.T) {
+	b := newBarriers()
+
+	c := b.arrive("start", 2)
+
+	b.reset()
+	if !released(c) {
+		t.Error("barrier not released by reset")
+	}
+}
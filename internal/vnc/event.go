@@ -46,6 +46,13 @@ type LoadFileEvent struct {
 	File string
 }
 
+// BarrierEvent is a pseudo event indicating that we should wait until Count
+// playbacks have reached a barrier with the same name before proceeding.
+type BarrierEvent struct {
+	Name  string
+	Count int
+}
+
 const (
 	keyEventFmt     = "KeyEvent,%t,%s"
 	pointerEventFmt = "PointerEvent,%d,%d,%d"
@@ -115,6 +122,22 @@ func parseEvent(cmd string) (interface{}, error) {
 			File: fields[1],
 		}
 
+		return e, nil
+	case "Barrier":
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("expected 2 values for Barrier, got %v", len(fields)-1)
+		}
+
+		count, err := strconv.Atoi(fields[2])
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("invalid count for Barrier: `%s`", fields[2])
+		}
+
+		e := &BarrierEvent{
+			Name:  fields[1],
+			Count: count,
+		}
+
 		return e, nil
 	case "WaitForIt", "ClickItEvent":
 		if len(fields) != 3 {
@@ -178,6 +201,27 @@ func parseDuration(s string) (time.Duration, error) {
 	return time.ParseDuration(s)
 }
 
+// getEventCount returns the number of events in a given playback file
+func getEventCount(f *os.File) int {
+	// go back to the beginning of the file
+	defer f.Seek(0, 0)
+
+	n := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s := strings.SplitN(scanner.Text(), ":", 2)
+		// Ignore blank, malformed, and comment lines
+		if len(s) != 2 || strings.HasPrefix(s[0], "#") {
+			continue
+		}
+
+		n += 1
+	}
+
+	return n
+}
+
 // getDuration returns the duration of a given playback file
 func getDuration(f *os.File) time.Duration {
 	// go back to the beginning of the file
@@ -0,0 +1,153 @@
+// Copyright 2019-2021 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+package vnc
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// Macros are named sequences of playback events that are stored server-side
+// so that common input sequences (login sequences, browsing loops, etc.) can
+// be replayed by name without shipping playback files around. Macros use the
+// same line format as playback files and are played back the same way, except
+// that an optional jitter can be added to the recorded delays so that the
+// input better resembles a human operator.
+
+// AddMacroEvent appends an event to the named macro, creating the macro if it
+// does not exist. The event is in the playback file format with an optional
+// leading delay, e.g. "500ms:KeyEvent,true,a". Events without a delay are
+// played immediately after the previous event.
+func (p *Player) AddMacroEvent(name, s string) error {
+	var delay time.Duration
+	event := s
+
+	if fields := strings.SplitN(s, ":", 2); len(fields) == 2 {
+		if d, err := parseDuration(fields[0]); err == nil {
+			delay = d
+			event = fields[1]
+		}
+	}
+
+	// check that we have a valid event before storing it
+	if _, err := parseEvent(event); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.macros[name] = append(p.macros[name], fmt.Sprintf("%d:%s", delay.Nanoseconds(), event))
+
+	return nil
+}
+
+// DeleteMacros deletes the named macro. An empty name deletes all macros.
+func (p *Player) DeleteMacros(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if name == "" {
+		p.macros = make(map[string][]string)
+		return nil
+	}
+
+	if _, ok := p.macros[name]; !ok {
+		return fmt.Errorf("vnc macro not found: %v", name)
+	}
+
+	delete(p.macros, name)
+
+	return nil
+}
+
+// MacroInfo returns a row per macro for "vnc macro".
+func (p *Player) MacroInfo() [][]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	res := [][]string{}
+
+	for name, lines := range p.macros {
+		var d time.Duration
+
+		for _, line := range lines {
+			s := strings.SplitN(line, ":", 2)
+			if i, err := strconv.Atoi(s[0]); err == nil {
+				d += time.Duration(i) * time.Nanosecond
+			}
+		}
+
+		res = append(res, []string{
+			name,
+			strconv.Itoa(len(lines)),
+			d.String(),
+		})
+	}
+
+	return res
+}
+
+// PlayMacro plays the named macro on a VM. If jitter is non-zero, a random
+// delay of up to jitter is added before each event so that the replayed input
+// does not have the machine-perfect timing of the recording. The delays are
+// resampled on every play.
+func (p *Player) PlayMacro(id, rhost, name string, jitter time.Duration) error {
+	p.mu.RLock()
+	lines, ok := p.macros[name]
+	p.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("vnc macro not found: %v", name)
+	}
+
+	f, err := os.CreateTemp("", "mm_vnc_")
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		s := strings.SplitN(line, ":", 2)
+
+		delay, err := strconv.Atoi(s[0])
+		if err != nil {
+			// shouldn't happen -- we wrote the delay ourselves
+			log.Error("invalid delay in vnc macro %v: `%s`", name, s[0])
+			continue
+		}
+
+		if jitter > 0 {
+			delay += int(rand.Int63n(int64(jitter)))
+		}
+
+		fmt.Fprintf(f, "%d:%s\n", delay, s[1])
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	pb, err := p.playback(id, rhost, f.Name())
+	if pb == nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	// remove file when playback is done
+	go func() {
+		<-pb.done
+		if err := os.Remove(f.Name()); err != nil {
+			log.Warn("Failed to delete temp file %s used for playback", f.Name())
+		}
+	}()
+
+	return err
+}
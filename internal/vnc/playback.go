@@ -30,10 +30,14 @@ type playback struct {
 	signal      chan signal
 	done        chan bool        // teardown playback
 	screenshots chan *image.RGBA // screenshots of the VM
+	barriers    *barriers        // barriers shared with the other playbacks
 
 	sync.Mutex               // guards below
 	depth      int           // how nested we are in LoadFiles
 	duration   time.Duration // total playback duration
+	skip       time.Duration // pending seek, consumed as we fast-forward
+	played     int           // events played so far
+	total      int           // total events across all loaded files
 	e          string        // current event
 	state      Control       // playback state, only Play or Pause
 	closed     bool          // set after playback closed
@@ -47,7 +51,7 @@ type signal struct {
 }
 
 // newPlayback creates a new playback with given id.
-func newPlayback(id, rhost string) (*playback, error) {
+func newPlayback(id, rhost string, barriers *barriers) (*playback, error) {
 	conn, err := Dial(rhost)
 	if err != nil {
 		return nil, err
@@ -60,6 +64,7 @@ func newPlayback(id, rhost string) (*playback, error) {
 		signal:      make(chan signal),
 		done:        make(chan bool),
 		screenshots: make(chan *image.RGBA),
+		barriers:    barriers,
 		state:       Play,
 	}, nil
 }
@@ -87,9 +92,15 @@ func (p *playback) Info() []string {
 	if p.state == Pause {
 		res = append(res, "PAUSED")
 	} else {
-		res = append(res, fmt.Sprintf("%v remaining", p.duration))
+		res = append(res, "PLAYING")
 	}
 
+	res = append(res,
+		fmt.Sprintf("%v/%v", p.played, p.total),
+		time.Since(p.start).Truncate(time.Second).String(),
+		p.duration.Truncate(time.Second).String(),
+	)
+
 	if p.file != nil {
 		res = append(res, p.file.Name())
 	} else {
@@ -204,6 +215,23 @@ func (p *playback) Continue() error {
 	return nil
 }
 
+// Seek fast-forwards the playback by d. Events inside the skipped window are
+// played immediately rather than dropped so that the input stream -- key
+// releases in particular -- stays intact. Seeking while paused takes effect
+// once the playback is continued.
+func (p *playback) Seek(d time.Duration) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.closed {
+		return errors.New("playback not seekable")
+	}
+
+	p.signal <- signal{kind: Seek, data: d}
+
+	return nil
+}
+
 func (p *playback) Stop() error {
 	p.Lock()
 	defer p.Unlock()
@@ -248,6 +276,8 @@ func (p *playback) InjectEvent(e interface{}) error {
 		p.signal <- signal{kind: LoadFile, data: e}
 	case *WaitForItEvent:
 		p.signal <- signal{kind: WaitForIt, data: e}
+	case *BarrierEvent:
+		p.signal <- signal{kind: Barrier, data: e}
 	default:
 		return fmt.Errorf("unknown event: %v", e)
 	}
@@ -280,7 +310,9 @@ func (v *playback) playFile(parent *os.File, filename string) error {
 	log.Info("Start playback of %v on %v", f.Name(), v.ID)
 
 	// record that we're reading a new file and update the remaining duration
+	// and event count
 	v.addDuration(getDuration(f))
+	v.addTotal(getEventCount(f))
 
 	old, err := v.setFile(f)
 	if err != nil {
@@ -324,6 +356,9 @@ func (v *playback) playFile(parent *os.File, filename string) error {
 		}
 
 		for {
+			// consume any pending seek, fast-forwarding this event
+			duration = v.takeSkip(duration)
+
 			start := time.Now()
 
 			select {
@@ -346,19 +381,29 @@ func (v *playback) playFile(parent *os.File, filename string) error {
 
 				switch sig.kind {
 				case Pause:
-					sig, ok := <-v.signal
-					if !ok {
-						// signal channel closed -- bail
-						log.Info("abort playback of %v due to signal", f.Name())
-						return nil
-					}
-
-					switch sig.kind {
-					case Play:
-						// do nothing except keep playing
-					default:
-						log.Error("unexpected signal: %v", sig)
+					paused := true
+					for paused {
+						sig, ok := <-v.signal
+						if !ok {
+							// signal channel closed -- bail
+							log.Info("abort playback of %v due to signal", f.Name())
+							return nil
+						}
+
+						switch sig.kind {
+						case Play:
+							// do nothing except keep playing
+							paused = false
+						case Seek:
+							// applied once we start playing again
+							v.addSkip(sig.data.(time.Duration))
+						default:
+							log.Error("unexpected signal: %v", sig)
+							paused = false
+						}
 					}
+				case Seek:
+					v.addSkip(sig.data.(time.Duration))
 				case Step:
 					// decrease by the remaining
 					v.addDuration(-duration)
@@ -379,6 +424,12 @@ func (v *playback) playFile(parent *os.File, filename string) error {
 					} else if e.Click {
 						v.out <- e2
 					}
+				case Barrier:
+					e := sig.data.(*BarrierEvent)
+
+					if err := v.waitBarrier(e); err != nil {
+						return err
+					}
 				default:
 					log.Error("unexpected signal: %v", sig)
 				}
@@ -387,6 +438,8 @@ func (v *playback) playFile(parent *os.File, filename string) error {
 
 		// waited so process the event
 	Event:
+		v.addPlayed()
+
 		switch e := res.(type) {
 		case Event:
 			v.out <- e
@@ -401,12 +454,32 @@ func (v *playback) playFile(parent *os.File, filename string) error {
 			} else if e.Click {
 				v.out <- e2
 			}
+		case *BarrierEvent:
+			if err := v.waitBarrier(e); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// waitBarrier blocks until the named barrier has been reached by the expected
+// number of playbacks, or until this playback is stopped.
+func (p *playback) waitBarrier(e *BarrierEvent) error {
+	log.Info("playback %v waiting at barrier %v for %v playbacks", p.ID, e.Name, e.Count)
+
+	c := p.barriers.arrive(e.Name, e.Count)
+
+	select {
+	case <-c:
+		log.Info("playback %v passed barrier %v", p.ID, e.Name)
+		return nil
+	case <-p.done:
+		return fmt.Errorf("playback stopped waiting at barrier %v", e.Name)
+	}
+}
+
 // waitForIt waits until the template image is displayed. If it is detected
 // within the timeout, returns a PointerEvent to click on the center of the
 // template image.
@@ -489,6 +562,48 @@ func (p *playback) addDuration(d time.Duration) {
 	p.duration += d
 }
 
+func (p *playback) addTotal(n int) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.total += n
+}
+
+func (p *playback) addPlayed() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.played += 1
+}
+
+func (p *playback) addSkip(d time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.skip += d
+}
+
+// takeSkip consumes up to d from any pending seek and returns how long the
+// caller should actually wait before playing the next event.
+func (p *playback) takeSkip(d time.Duration) time.Duration {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.skip <= 0 {
+		return d
+	}
+
+	skipped := d
+	if p.skip < skipped {
+		skipped = p.skip
+	}
+
+	p.skip -= skipped
+	p.duration -= skipped
+
+	return d - skipped
+}
+
 // writeEvents reads events from the out channel and write them to the vnc
 // connection. Closes the connection when it drains the channel.
 func (p *playback) writeEvents() {
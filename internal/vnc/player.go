@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
 )
@@ -17,11 +18,19 @@ type Player struct {
 	mu sync.RWMutex // guards below
 
 	m map[string]*playback
+
+	// named macros that can be played back on any VM, see macro.go
+	macros map[string][]string
+
+	// barriers shared by all the playbacks so that they can synchronize
+	barriers *barriers
 }
 
 func NewPlayer() *Player {
 	return &Player{
-		m: make(map[string]*playback),
+		m:        make(map[string]*playback),
+		macros:   make(map[string][]string),
+		barriers: newBarriers(),
 	}
 }
 
@@ -43,6 +52,12 @@ func (p *Player) Continue(id string) error {
 	})
 }
 
+func (p *Player) Seek(id string, d time.Duration) error {
+	return p.apply(id, func(p *playback) error {
+		return p.Seek(d)
+	})
+}
+
 func (p *Player) Step(id string) error {
 	return p.apply(id, func(p *playback) error {
 		return p.Step()
@@ -87,6 +102,11 @@ func (p *Player) Clear() {
 
 		delete(p.m, k)
 	}
+
+	p.macros = make(map[string][]string)
+
+	// release anything still waiting at a barrier
+	p.barriers.reset()
 }
 
 func (p *Player) reap() {
@@ -157,7 +177,7 @@ func (p *Player) playback(id, rhost, filename string) (*playback, error) {
 		return nil, fmt.Errorf("kb playback %v already playing", id)
 	}
 
-	pb, err := newPlayback(id, rhost)
+	pb, err := newPlayback(id, rhost, p.barriers)
 	if err != nil {
 		return nil, err
 	}
@@ -36,7 +36,8 @@ type recorder struct {
 type kbRecorder struct {
 	*recorder // embed
 
-	last time.Time
+	last   time.Time
+	events int // number of events recorded so far
 }
 
 type fbRecorder struct {
@@ -209,7 +210,10 @@ func (r *Recorder) Info() [][]string {
 		res = append(res, []string{
 			id,
 			"record kb",
-			time.Since(kb.start).String(),
+			"RECORDING",
+			fmt.Sprintf("%v events", kb.events),
+			time.Since(kb.start).Truncate(time.Second).String(),
+			"N/A",
 			kb.file.Name(),
 		})
 	}
@@ -218,7 +222,10 @@ func (r *Recorder) Info() [][]string {
 		res = append(res, []string{
 			id,
 			"record fb",
-			time.Since(fb.start).String(),
+			"RECORDING",
+			"N/A",
+			time.Since(fb.start).Truncate(time.Second).String(),
+			"N/A",
 			fb.file.Name(),
 		})
 	}
@@ -239,6 +246,7 @@ func (kb *kbRecorder) Record(msg interface{}) {
 	case *KeyEvent, *PointerEvent:
 		fmt.Fprintf(kb.file, "%d:%s\n", delta, msg)
 		kb.last = time.Now()
+		kb.events += 1
 	default:
 		log.Info("unexpected  client-to-server message: %#v\n", msg)
 	}
@@ -44,6 +44,30 @@ Enable or disable JSON mode. Enabling JSON mode disables CSV mode, if enabled.`,
 			cliModeHelper(c, out, jsonMode)
 		},
 	},
+	{ // format
+		HelpShort: "set the output format",
+		HelpLong: `
+Set the output format for tabular data. Available formats:
+
+- table : tab-aligned tables (default)
+- csv   : comma-separated values
+- json  : one object per row, keyed by column name
+
+Unlike ".json true", which marshals responses with rows as positional arrays,
+the json format has a stable schema: renaming or reordering columns does not
+break scripts that look fields up by name. Cell values that are themselves
+JSON, such as tags, are embedded as objects rather than double-encoded
+strings, and commands that attach structured data to their responses, such as
+"vm info", include it under the "data" key with nested objects for networks
+and tags.
+
+Run with no arguments to display the current format.`,
+		Patterns: []string{
+			".format [table,json,csv]",
+			".format <table,json,csv> (command)",
+		},
+		Call: cliFormat,
+	},
 	{ // headers
 		HelpShort: "enable or disable headers for tabular data",
 		HelpLong: `
@@ -488,6 +512,56 @@ func cliModeHelper(c *Command, out chan<- Responses, newMode int) {
 	}
 }
 
+func cliFormat(c *Command, out chan<- Responses) {
+	mode := defaultMode
+	if c.BoolArgs["json"] {
+		mode = jsonObjectMode
+	} else if c.BoolArgs["csv"] {
+		mode = csvMode
+	}
+
+	if c.Subcommand == nil {
+		resp := &Response{
+			Host: hostname,
+		}
+
+		flagsLock.Lock()
+		defer flagsLock.Unlock()
+
+		if c.BoolArgs["table"] || c.BoolArgs["json"] || c.BoolArgs["csv"] {
+			defaultFlags.Mode = mode
+		} else {
+			switch defaultFlags.Mode {
+			case jsonObjectMode:
+				resp.Response = "json"
+			case csvMode:
+				resp.Response = "csv"
+			case jsonMode:
+				resp.Response = "json (legacy)"
+			default:
+				resp.Response = "table"
+			}
+		}
+
+		out <- Responses{resp}
+		return
+	}
+
+	c.Subcommand.SetRecord(false)
+
+	for r := range ProcessCommand(c.Subcommand) {
+		if len(r) > 0 {
+			if r[0].Flags == nil {
+				r[0].Flags = copyFlags()
+			}
+
+			r[0].Mode = mode
+
+			out <- r
+		}
+	}
+}
+
 func cliFlagHelper(c *Command, out chan<- Responses, get func(*Flags) *bool) {
 	if c.Subcommand == nil {
 		resp := &Response{
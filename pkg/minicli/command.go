@@ -32,21 +32,39 @@ type Command struct {
 	// from. Setting and using this is entirely up to developers using minicli.
 	Source string
 
+	// ReadOnly is copied from the matched Handler when the command is
+	// compiled, see Handler.ReadOnly.
+	ReadOnly bool
+
 	// exact tracks whether the command was formed from prefixes or not, can be
 	// used to break ties if there is ambiguity.
 	exact bool
 }
 
-func newCommand(call CLIFunc) *Command {
+func newCommand(h *Handler) *Command {
 	return &Command{
 		StringArgs: make(map[string]string),
 		BoolArgs:   make(map[string]bool),
 		ListArgs:   make(map[string][]string),
-		Call:       call,
+		Call:       h.Call,
+		ReadOnly:   h.ReadOnly,
 		exact:      true,
 	}
 }
 
+// IsReadOnly returns true when the command only reads state, based on the
+// ReadOnly field of the matched handlers. For commands that wrap a
+// subcommand, the innermost command decides.
+func (c *Command) IsReadOnly() bool {
+	for ; c != nil; c = c.Subcommand {
+		if c.Subcommand == nil && !c.ReadOnly {
+			return false
+		}
+	}
+
+	return true
+}
+
 // SetSource sets the Source field for a command and all nested subcommands.
 func (c *Command) SetSource(source string) {
 	c.Source = source
@@ -19,6 +19,11 @@ type Handler struct {
 	// Call to invoke when the raw input matches the pattern
 	Call CLIFunc `json:"-"`
 
+	// ReadOnly marks a handler as only reporting state, never modifying it.
+	// Frontends can use this to reject mutating commands, see
+	// Command.IsReadOnly.
+	ReadOnly bool `json:"read_only"`
+
 	// SharedPrefix is a prefix shared by all patterns. Populated by minicli
 	// when the Handler is registered.
 	SharedPrefix string `json:"shared_prefix"`
@@ -19,6 +19,10 @@ const (
 	defaultMode = iota
 	jsonMode
 	csvMode
+	// jsonObjectMode renders tabular data as one object per row, keyed by
+	// column name, so that scripts do not depend on column order. See the
+	// ".format" builtin.
+	jsonObjectMode
 )
 
 const (
@@ -74,6 +78,17 @@ var firstHistoryTruncate = true
 type Responses []*Response
 
 // A response as populated by handler functions.
+// Column describes one column of Tabular data so that clients can sort and
+// render values without guessing from the header text. ID is a stable
+// identifier for the column that will not change even if the displayed Header
+// does. Type is one of "string", "int", "float", "bool", or "duration". Unit,
+// when set, names the unit of the values, e.g. "MB" or "MB/s".
+type Column struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Unit string `json:"unit,omitempty"`
+}
+
 type Response struct {
 	Host     string      // Host this response was created on
 	Response string      // Simple response
@@ -82,6 +97,9 @@ type Response struct {
 	Error    string      // Because you can't gob/json encode an error type
 	Data     interface{} //`json:"-"` // Optional user data
 
+	// Optional column metadata for Tabular data, parallel to Header
+	Columns []Column `json:",omitempty"`
+
 	// Embedded output flags, overrides defaults if set for first response
 	*Flags `json:"-"`
 }
@@ -360,6 +378,26 @@ func Doc() (string, error) {
 	return string(bytes), err
 }
 
+// Search returns the help short of all handlers whose patterns or help text
+// contain the keyword, case insensitively.
+func Search(keyword string) string {
+	keyword = strings.ToLower(keyword)
+
+	matches := []*Handler{}
+	for _, h := range handlers {
+		text := strings.Join(h.Patterns, " ") + " " + h.HelpShort + " " + h.HelpLong
+		if strings.Contains(strings.ToLower(text), keyword) {
+			matches = append(matches, h)
+		}
+	}
+
+	if len(matches) == 0 {
+		return fmt.Sprintf("no help entries match `%s`", keyword)
+	}
+
+	return printHelpShort(matches)
+}
+
 // copyFlags returns a copy of the default flags
 func copyFlags() *Flags {
 	flagsLock.Lock()
@@ -68,6 +68,10 @@ func (r Responses) String() string {
 		return string(bytes)
 	}
 
+	if r.jsonObject() {
+		return r.jsonObjectString()
+	}
+
 	header, err := r.getHeader()
 	if err != nil {
 		return err.Error()
@@ -108,22 +112,107 @@ func (r Responses) String() string {
 	return strings.TrimSpace(resp)
 }
 
-// Error returns a string containing all the errors in the responses
-func (r Responses) Error() string {
-	var buf bytes.Buffer
+// jsonObjectString marshals the responses with a stable schema: tabular data
+// becomes one object per row, keyed by column name, so scripts are not broken
+// by columns being added or reordered. Cell values that are themselves JSON,
+// such as tags, are embedded rather than double-encoded. Any structured data
+// attached to a response is included under the "data" key.
+func (r Responses) jsonObjectString() string {
+	type response struct {
+		Host     string                   `json:"host"`
+		Response string                   `json:"response,omitempty"`
+		Rows     []map[string]interface{} `json:"rows,omitempty"`
+		Data     interface{}              `json:"data,omitempty"`
+		Error    string                   `json:"error,omitempty"`
+	}
 
-	// Append errors from hosts
-	for i, v := range r {
-		if v.Error != "" {
-			fmt.Fprintf(&buf, "Error (%s): %s", v.Host, v.Error)
+	res := []response{}
+
+	for _, v := range r {
+		v2 := response{
+			Host:     v.Host,
+			Response: v.Response,
+			Data:     v.Data,
+			Error:    v.Error,
+		}
+
+		for _, row := range v.Tabular {
+			obj := map[string]interface{}{}
 
-			// add a newline unless this is our last iteration
-			if i != len(r)-1 {
-				io.WriteString(&buf, "\n")
+			for i, val := range row {
+				key := strconv.Itoa(i)
+				if i < len(v.Header) {
+					key = v.Header[i]
+				}
+
+				// embed cells that contain JSON objects or arrays so that
+				// consumers get nested structures instead of strings
+				if len(val) > 0 && (val[0] == '{' || val[0] == '[') {
+					var nested interface{}
+					if err := json.Unmarshal([]byte(val), &nested); err == nil {
+						obj[key] = nested
+						continue
+					}
+				}
+
+				obj[key] = val
 			}
+
+			v2.Rows = append(v2.Rows, obj)
+		}
+
+		res = append(res, v2)
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(bytes)
+}
+
+// Error returns a string containing all the errors in the responses. Hosts
+// that fail with identical errors are compressed into a range and reported
+// once, and when only some hosts fail a summary of ok/failed counts is
+// appended so partial failures across a mesh are easy to spot.
+func (r Responses) Error() string {
+	// bucket the failing hosts by identical error
+	buckets := map[string][]string{}
+	var failed int
+
+	for _, v := range r {
+		if v.Error != "" {
+			failed += 1
+			buckets[v.Error] = append(buckets[v.Error], v.Host)
 		}
 	}
 
+	if failed == 0 {
+		return ""
+	}
+
+	// sort the errors for stable output
+	errs := []string{}
+	for e := range buckets {
+		errs = append(errs, e)
+	}
+	sort.Strings(errs)
+
+	var buf bytes.Buffer
+
+	for _, e := range errs {
+		hosts := buckets[e]
+		sort.Strings(hosts)
+
+		fmt.Fprintf(&buf, "Error (%s): %s\n", ranges.UnsplitList(hosts), e)
+	}
+
+	// summarize partial failures across multiple hosts
+	if len(r) > 1 {
+		fmt.Fprintf(&buf, "%v/%v hosts failed", failed, len(r))
+	}
+
 	return strings.TrimSpace(buf.String())
 }
 
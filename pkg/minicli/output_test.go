@@ -5,8 +5,10 @@
 package minicli
 
 import (
+	"encoding/json"
 	"sort"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -57,3 +59,56 @@ func TestSortInts(t *testing.T) {
 		}
 	}
 }
+
+func TestJSONObjectString(t *testing.T) {
+	r := Responses{
+		&Response{
+			Host:   "mm",
+			Header: []string{"name", "tags"},
+			Tabular: [][]string{
+				[]string{"vm-0", `{"foo":"bar"}`},
+			},
+		},
+	}
+
+	var res []struct {
+		Host string
+		Rows []map[string]interface{}
+	}
+
+	if err := json.Unmarshal([]byte(r.jsonObjectString()), &res); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+
+	if len(res) != 1 || len(res[0].Rows) != 1 {
+		t.Fatalf("wrong shape: %v", res)
+	}
+
+	row := res[0].Rows[0]
+	if row["name"] != "vm-0" {
+		t.Errorf("wrong name: %v", row["name"])
+	}
+
+	tags, ok := row["tags"].(map[string]interface{})
+	if !ok || tags["foo"] != "bar" {
+		t.Errorf("tags not embedded: %v", row["tags"])
+	}
+}
+
+func TestErrorGrouping(t *testing.T) {
+	r := Responses{
+		&Response{Host: "mm-0", Error: "boom"},
+		&Response{Host: "mm-1", Error: "boom"},
+		&Response{Host: "mm-2"},
+	}
+
+	got := r.Error()
+
+	if !strings.Contains(got, "Error (mm-[0-1]): boom") {
+		t.Errorf("errors not grouped: %v", got)
+	}
+
+	if !strings.Contains(got, "2/3 hosts failed") {
+		t.Errorf("missing summary: %v", got)
+	}
+}
@@ -94,6 +94,10 @@ func (r Responses) json() bool {
 	return len(r) > 0 && r[0].Mode == jsonMode
 }
 
+func (r Responses) jsonObject() bool {
+	return len(r) > 0 && r[0].Mode == jsonObjectMode
+}
+
 func (r Responses) csv() bool {
 	return len(r) > 0 && r[0].Mode == csvMode
 }
@@ -99,7 +99,7 @@ func (p *patternTrie) compile(input inputItems) *Command {
 		// reached the end of the input... return a new command if there is a
 		// handler at this depth
 		if p.Handler != nil {
-			return newCommand(p.Handler.Call)
+			return newCommand(p.Handler)
 		}
 
 		return nil
@@ -147,7 +147,7 @@ func (p *patternTrie) compile(input inputItems) *Command {
 			}
 
 			// remaining items become ListArgs
-			c = newCommand(p2.Handler.Call)
+			c = newCommand(p2.Handler)
 			c.ListArgs[k.Value] = make([]string, len(input))
 			for i, v := range input {
 				c.ListArgs[k.Value][i] = v.Value
@@ -159,7 +159,7 @@ func (p *patternTrie) compile(input inputItems) *Command {
 			}
 
 			// remaining items are compiled as a nested command
-			c = newCommand(p2.Handler.Call)
+			c = newCommand(p2.Handler)
 			if c.Subcommand = trie.compile(input); c.Subcommand == nil {
 				c = nil
 			}
@@ -34,6 +34,10 @@ type Request struct {
 	Command   string
 	Suggest   string
 	PlumbPipe string
+
+	// ReadOnly asks the server to reject the command unless it only reads
+	// state, see `minimega -attach -readonly`.
+	ReadOnly bool
 }
 
 type Response struct {
@@ -66,6 +70,10 @@ type Conn struct {
 
 	// Set the Pager to use for long output messages
 	Pager minipager.Pager
+
+	// ReadOnly is set on every Request so that the server rejects commands
+	// that modify state
+	ReadOnly bool
 }
 
 func Dial(base string) (*Conn, error) {
@@ -193,7 +201,7 @@ func (mm *Conn) Run(cmd string) chan *Response {
 
 	mm.lock.Lock()
 
-	err := mm.enc.Encode(Request{Command: cmd})
+	err := mm.enc.Encode(Request{Command: cmd, ReadOnly: mm.ReadOnly})
 	if err != nil {
 		mm.err = fmt.Errorf("local command gob encode: %v", err)
 
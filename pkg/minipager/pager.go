@@ -8,9 +8,8 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
-	"syscall"
-	"unsafe"
 
 	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
 )
@@ -54,6 +53,9 @@ func (_ defaultPager) Page(output string) {
 	pager := os.Getenv("PAGER")
 	if pager == "" {
 		pager = "less"
+		if runtime.GOOS == "windows" {
+			pager = "more"
+		}
 	}
 
 	cmd := exec.Command(pager)
@@ -64,18 +66,3 @@ func (_ defaultPager) Page(output string) {
 		log.Error("problem paging: %s", err)
 	}
 }
-
-func termSize() *winsize {
-	ws := &winsize{}
-	res, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
-		uintptr(syscall.Stdout),
-		uintptr(syscall.TIOCGWINSZ),
-		uintptr(unsafe.Pointer(ws)))
-
-	if int(res) == -1 {
-		log.Error("unable to determine terminal size (errno: %d)", errno)
-		return nil
-	}
-
-	return ws
-}
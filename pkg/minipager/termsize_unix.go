@@ -0,0 +1,30 @@
+// Copyright 2015-2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+//go:build !windows
+// +build !windows
+
+package minipager
+
+import (
+	"syscall"
+	"unsafe"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+func termSize() *winsize {
+	ws := &winsize{}
+	res, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
+		uintptr(syscall.Stdout),
+		uintptr(syscall.TIOCGWINSZ),
+		uintptr(unsafe.Pointer(ws)))
+
+	if int(res) == -1 {
+		log.Error("unable to determine terminal size (errno: %d)", errno)
+		return nil
+	}
+
+	return ws
+}
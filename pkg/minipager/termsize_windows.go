@@ -0,0 +1,54 @@
+// Copyright 2015-2023 National Technology & Engineering Solutions of Sandia, LLC (NTESS).
+// Under the terms of Contract DE-NA0003525 with NTESS, the U.S. Government retains certain
+// rights in this software.
+
+//go:build windows
+// +build windows
+
+package minipager
+
+import (
+	"syscall"
+	"unsafe"
+
+	log "github.com/sandia-minimega/minimega/v2/pkg/minilog"
+)
+
+// see CONSOLE_SCREEN_BUFFER_INFO in wincon.h
+type coord struct {
+	X, Y int16
+}
+
+type smallRect struct {
+	Left, Top, Right, Bottom int16
+}
+
+type consoleScreenBufferInfo struct {
+	Size              coord
+	CursorPosition    coord
+	Attributes        uint16
+	Window            smallRect
+	MaximumWindowSize coord
+}
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+)
+
+func termSize() *winsize {
+	info := &consoleScreenBufferInfo{}
+	res, _, err := procGetConsoleScreenBufferInfo.Call(
+		uintptr(syscall.Stdout),
+		uintptr(unsafe.Pointer(info)))
+
+	if res == 0 {
+		log.Error("unable to determine terminal size: %v", err)
+		return nil
+	}
+
+	return &winsize{
+		Row: uint16(info.Window.Bottom - info.Window.Top + 1),
+		Col: uint16(info.Window.Right - info.Window.Left + 1),
+	}
+}
@@ -68,7 +68,9 @@ function initVMInfoDataTable() {
         "pageLength": 500,
         "columns": [
             { "title": "Host", "data": "host" },
-            { "title": "Name", "data": "name" },
+            { "title": "Name", "data": "name", render:  function ( data, type, full, meta ) {
+                return '<a href="vm/'+data+'/detail">'+data+'</a>';
+            }},
             { "title": "State", "data": "state" , render:  function ( data, type, full, meta ) {
 				var res = "<span>"+data+"</span>";
 				if (data == "BUILDING" || data == "PAUSED") {
@@ -335,6 +337,9 @@ function initNamespacesDataTable() {
                 return '<a href="/'+full["namespace"]+'/vlans">'+data+'</a>';
             } },
             { "title": "Active", "data": "active" },
+            { "title": "Artifacts", "data": null, render:  function ( data, type, full, meta ) {
+                return '<a href="/'+full["namespace"]+'/bundle.tar.gz">Download</a>';
+            } },
         ],
         "order": [[ 0, 'asc' ]],
         "stateSave": true,
@@ -452,7 +457,12 @@ function initFilesDataTable() {
                 if (full["dir"] != "") {
                     base += "/";
                 }
-                return '<a href="'+p+base+'">'+base+'</a>';
+                var link = '<a href="'+p+base+'">'+base+'</a>';
+                if (base.endsWith(".pcap")) {
+                    var root = p.substr(0, p.indexOf("/files"));
+                    link += ' (<a href="'+root+'/pcap?path='+encodeURIComponent(data)+'">preview</a>)';
+                }
+                return link;
             } },
             { "title": "Size", "data": "size", render:  function ( data, type, full, meta ) {
                 // From https://stackoverflow.com/a/22023833